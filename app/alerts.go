@@ -0,0 +1,471 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/render"
+)
+
+const (
+	alertInterval       = 1 * time.Second  // how often we check for new reports to evaluate rules against
+	alertWebhookTimeout = 10 * time.Second // how long we wait for webhooks to accept a notification
+	alertQueueLength    = 100              // notifications waiting to be sent before we drop them
+)
+
+// Alert states. Alerts are pending while their rule holds for less than its
+// For duration, and then firing. Sinks are notified when alerts start firing,
+// and again when they are resolved.
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertRule is a condition on the nodes of a rendered topology. Metric rules
+// compare the latest value of a metric on each node with a threshold, e.g.
+//
+//	{"name": "container-memory", "topology": "containers",
+//	 "metric": "memory_usage", "op": ">", "threshold": 0.9,
+//	 "of": "memory_limit", "for": "2m"}
+//
+// Edge rules hold when a new edge appears between matching nodes, i.e. one
+// which wasn't there when the rule was first evaluated, and hasn't been
+// alerted on before, e.g.
+//
+//	{"name": "internet-egress", "topology": "pods-by-service",
+//	 "edge": {"from": "X", "to": "theinternet"}}
+type AlertRule struct {
+	Name     string            `json:"name"`
+	Topology string            `json:"topology"`          // a topology ID, as in /api/topology/{topology}
+	Options  map[string]string `json:"options,omitempty"` // topology options, as for /api/topology/{topology}
+	Node     string            `json:"node,omitempty"`    // if set, only nodes with this ID or label
+
+	Metric    string  `json:"metric,omitempty"`
+	Op        string  `json:"op,omitempty"` // one of >, >=, <, <=, ==, !=
+	Threshold float64 `json:"threshold,omitempty"`
+	Of        string  `json:"of,omitempty"`  // if set, compare metric as a fraction of this metric or metadata value
+	For       string  `json:"for,omitempty"` // duration the condition must hold before the alert fires
+
+	Edge *AlertEdge `json:"edge,omitempty"`
+
+	duration time.Duration
+}
+
+// AlertEdge matches edges between nodes with the given IDs or labels.
+type AlertEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Alert is an instance of a rule holding for a node (or an edge).
+type Alert struct {
+	Rule        string    `json:"rule"`
+	Topology    string    `json:"topology"`
+	Node        string    `json:"node"`
+	Label       string    `json:"label"`
+	Peer        string    `json:"peer,omitempty"` // for edge rules, the other end of the edge
+	Value       float64   `json:"value,omitempty"`
+	State       string    `json:"state"`
+	ActiveSince time.Time `json:"active_since"`
+}
+
+func (a Alert) key() string {
+	return a.Rule + "\x00" + a.Node + "\x00" + a.Peer
+}
+
+type alertsByKey []Alert
+
+func (a alertsByKey) Len() int           { return len(a) }
+func (a alertsByKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a alertsByKey) Less(i, j int) bool { return a[i].key() < a[j].key() }
+
+// LoadAlertRules reads a JSON list of rules from a file.
+func LoadAlertRules(filename string) ([]AlertRule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := []AlertRule{}
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, err
+	}
+	names := map[string]struct{}{}
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := names[rules[i].Name]; ok {
+			return nil, fmt.Errorf("duplicate alert rule %q", rules[i].Name)
+		}
+		names[rules[i].Name] = struct{}{}
+	}
+	return rules, nil
+}
+
+// validate checks the rule, and parses its duration.
+func (r *AlertRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("alert rule without a name")
+	}
+	if !knownTopology(r.Topology) {
+		return fmt.Errorf("alert rule %q: unknown topology %q", r.Name, r.Topology)
+	}
+	switch {
+	case r.Metric != "" && r.Edge != nil:
+		return fmt.Errorf("alert rule %q: rules have either a metric or an edge, not both", r.Name)
+	case r.Metric != "":
+		if _, ok := alertOps[r.Op]; !ok {
+			return fmt.Errorf("alert rule %q: unknown op %q", r.Name, r.Op)
+		}
+	case r.Edge != nil:
+		if r.Edge.From == "" && r.Edge.To == "" {
+			return fmt.Errorf("alert rule %q: edges need a from or a to", r.Name)
+		}
+	default:
+		return fmt.Errorf("alert rule %q: rules need a metric or an edge", r.Name)
+	}
+	if r.For != "" {
+		duration, err := time.ParseDuration(r.For)
+		if err != nil {
+			return fmt.Errorf("alert rule %q: %v", r.Name, err)
+		}
+		r.duration = duration
+	}
+	return nil
+}
+
+// knownTopology is true for the topologies in the registry, and those which
+// are added to it once we see reports from Kubernetes.
+func knownTopology(id string) bool {
+	if _, ok := topologyRegistry.get(id); ok {
+		return true
+	}
+	for _, t := range kubernetesTopologies {
+		if t.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+var alertOps = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// matches returns an alert for each of the nodes (or edges) the rule holds
+// for. For edge rules, that's all the matching edges, new or not.
+func (r AlertRule) matches(nodes render.RenderableNodes) []Alert {
+	alerts := []Alert{}
+	for _, node := range nodes {
+		if r.Edge != nil {
+			if !matchesNode(node, r.Edge.From) {
+				continue
+			}
+			for _, peer := range node.Adjacency {
+				if other, ok := nodes[peer]; ok && matchesNode(other, r.Edge.To) {
+					alerts = append(alerts, r.alert(node, peer, 0))
+				}
+			}
+			continue
+		}
+
+		if !matchesNode(node, r.Node) {
+			continue
+		}
+		value, ok := latestValue(node, r.Metric)
+		if !ok {
+			continue
+		}
+		compared := value
+		if r.Of != "" {
+			of, ok := latestValue(node, r.Of)
+			if !ok || of <= 0 {
+				continue
+			}
+			compared = value / of
+		}
+		if alertOps[r.Op](compared, r.Threshold) {
+			alerts = append(alerts, r.alert(node, "", value))
+		}
+	}
+	return alerts
+}
+
+func (r AlertRule) alert(node render.RenderableNode, peer string, value float64) Alert {
+	return Alert{
+		Rule:     r.Name,
+		Topology: r.Topology,
+		Node:     node.ID,
+		Label:    node.LabelMajor,
+		Peer:     peer,
+		Value:    value,
+	}
+}
+
+// matchesNode is true if the node has the given ID or label, or if no ID or
+// label is given.
+func matchesNode(node render.RenderableNode, idOrLabel string) bool {
+	return idOrLabel == "" || node.ID == idOrLabel || node.LabelMajor == idOrLabel
+}
+
+// latestValue returns the latest sample of a metric on the node, or failing
+// that, a numeric metadata value (e.g. the memory_limit of containers).
+func latestValue(node render.RenderableNode, key string) (float64, bool) {
+	if metric, ok := node.Metrics[key]; ok {
+		if sample := metric.LastSample(); sample != nil {
+			return sample.Value, true
+		}
+	}
+	if value, ok := node.Metadata[key]; ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// AlertSink is notified of alerts starting to fire, and being resolved.
+type AlertSink interface {
+	Notify(Alert) error
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink makes an AlertSink which POSTs each alert, as JSON, to url.
+func NewWebhookSink(url string) AlertSink {
+	return webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: alertWebhookTimeout},
+	}
+}
+
+func (s webhookSink) Notify(alert Alert) error {
+	buf, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// Alerter evaluates alert rules against the rendered topologies of each new
+// report, and notifies its sinks as alerts fire and resolve.
+type Alerter struct {
+	mtx     sync.Mutex
+	rep     VersionedReporter
+	cache   *renderCache
+	rules   []AlertRule
+	sinks   []AlertSink
+	version uint64
+	active  map[string]Alert // alert key -> alert
+
+	// For edge rules: the rules which have been evaluated, and the edges
+	// (as alert keys) they've seen.
+	seeded     map[string]struct{}
+	knownEdges map[string]struct{}
+
+	notifications chan Alert
+	wait          sync.WaitGroup
+	quit          chan struct{}
+}
+
+// RegisterAlertRoutes registers the alerts route, and starts evaluating the
+// rules whenever the report from rep changes.
+func RegisterAlertRoutes(rep VersionedReporter, rules []AlertRule, sinks []AlertSink, router *mux.Router) *Alerter {
+	alerter := newAlerter(rep, rules, sinks)
+	alerter.wait.Add(2)
+	go alerter.loop()
+	go alerter.notifyLoop()
+	router.Methods("GET").
+		Path("/api/alerts").
+		HandlerFunc(alerter.handleAlerts)
+	return alerter
+}
+
+func newAlerter(rep VersionedReporter, rules []AlertRule, sinks []AlertSink) *Alerter {
+	return &Alerter{
+		rep:           rep,
+		cache:         renderCacheFor(rep),
+		rules:         rules,
+		sinks:         sinks,
+		active:        map[string]Alert{},
+		seeded:        map[string]struct{}{},
+		knownEdges:    map[string]struct{}{},
+		notifications: make(chan Alert, alertQueueLength),
+		quit:          make(chan struct{}),
+	}
+}
+
+// Stop stops the Alerter.
+func (a *Alerter) Stop() {
+	close(a.quit)
+	a.wait.Wait()
+}
+
+func (a *Alerter) loop() {
+	defer a.wait.Done()
+	if len(a.rules) == 0 {
+		return
+	}
+	ticker := time.NewTicker(alertInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if version := a.rep.Version(); version != a.version {
+				a.version = version
+				a.evaluate()
+			}
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+func (a *Alerter) notifyLoop() {
+	defer a.wait.Done()
+	for {
+		select {
+		case alert := <-a.notifications:
+			for _, sink := range a.sinks {
+				if err := sink.Notify(alert); err != nil {
+					log.Printf("Error sending alert %s: %v", alert.Rule, err)
+				}
+			}
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+func (a *Alerter) notify(alert Alert) {
+	if len(a.sinks) == 0 {
+		return
+	}
+	select {
+	case a.notifications <- alert:
+	default:
+		log.Printf("Dropping alert %s: too many alerts queued", alert.Rule)
+	}
+}
+
+// evaluate checks all the rules against the current report. Rules for
+// topologies which aren't registered (yet) hold for no nodes. Topologies are
+// rendered through the render cache, so they're shared with the UI.
+func (a *Alerter) evaluate() {
+	var (
+		timestamp = now()
+		current   = map[string]struct{}{}
+	)
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for _, rule := range a.rules {
+		topology, ok := topologyRegistry.get(rule.Topology)
+		if !ok {
+			continue
+		}
+		options := rule.Options
+		option := func(param string) string { return options[param] }
+		nodes := a.cache.render(a.rep, renderCacheKeyForOptions(topology, option), renderedForOptions(topology, option))
+
+		alerts := rule.matches(nodes)
+		if rule.Edge != nil {
+			alerts = a.newEdges(rule, alerts)
+		}
+		for _, alert := range alerts {
+			key := alert.key()
+			current[key] = struct{}{}
+			if prev, ok := a.active[key]; ok {
+				alert.State, alert.ActiveSince = prev.State, prev.ActiveSince
+			} else {
+				alert.State, alert.ActiveSince = AlertPending, timestamp
+			}
+			if alert.State == AlertPending && timestamp.Sub(alert.ActiveSince) >= rule.duration {
+				alert.State = AlertFiring
+				a.notify(alert)
+			}
+			a.active[key] = alert
+		}
+	}
+
+	for key, alert := range a.active {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		delete(a.active, key)
+		if alert.State == AlertFiring {
+			alert.State = AlertResolved
+			a.notify(alert)
+		}
+	}
+}
+
+// newEdges returns the alerts of the edges which are new, or were already
+// being alerted on. The edges which are there when the rule is first
+// evaluated aren't new. Must be called with the lock held.
+func (a *Alerter) newEdges(rule AlertRule, alerts []Alert) []Alert {
+	_, seeded := a.seeded[rule.Name]
+	a.seeded[rule.Name] = struct{}{}
+
+	result := []Alert{}
+	for _, alert := range alerts {
+		key := alert.key()
+		if _, ok := a.active[key]; ok {
+			result = append(result, alert)
+			continue
+		}
+		if _, ok := a.knownEdges[key]; ok {
+			continue
+		}
+		a.knownEdges[key] = struct{}{}
+		if seeded {
+			result = append(result, alert)
+		}
+	}
+	return result
+}
+
+// Alerts returns the pending and firing alerts.
+func (a *Alerter) Alerts() []Alert {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	alerts := make([]Alert, 0, len(a.active))
+	for _, alert := range a.active {
+		alerts = append(alerts, alert)
+	}
+	sort.Sort(alertsByKey(alerts))
+	return alerts
+}
+
+func (a *Alerter) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	respondWith(w, http.StatusOK, a.Alerts())
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestLoadAlertRules(t *testing.T) {
+	for _, c := range []struct {
+		rules string
+		ok    bool
+	}{
+		{`[{"name": "load", "topology": "hosts", "metric": "load1", "op": ">", "threshold": 8, "for": "2m"}]`, true},
+		{`[{"name": "egress", "topology": "pods-by-service", "edge": {"from": "X", "to": "theinternet"}}]`, true},
+		{`[{"name": "load", "topology": "foo", "metric": "load1", "op": ">", "threshold": 8}]`, false},
+		{`[{"name": "load", "topology": "hosts", "metric": "load1", "op": "=~", "threshold": 8}]`, false},
+		{`[{"name": "load", "topology": "hosts", "metric": "load1", "op": ">", "for": "soon"}]`, false},
+		{`[{"name": "load", "topology": "hosts"}]`, false},
+		{`[{"topology": "hosts", "metric": "load1", "op": ">"}]`, false},
+		{`[{"name": "a", "topology": "hosts", "metric": "load1", "op": ">"},
+		   {"name": "a", "topology": "hosts", "metric": "load5", "op": ">"}]`, false},
+	} {
+		f, err := ioutil.TempFile("", "alert-rules")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(c.rules); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		rules, err := LoadAlertRules(f.Name())
+		if c.ok && err != nil {
+			t.Errorf("%s: %v", c.rules, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%s: expected error", c.rules)
+		}
+		if c.ok && rules[0].Name == "load" && rules[0].duration != 2*time.Minute {
+			t.Errorf("%s: want duration 2m, have %v", c.rules, rules[0].duration)
+		}
+	}
+}
+
+func TestAlerterMetricRule(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	timestamp := time.Now()
+	now = func() time.Time { return timestamp }
+
+	rule := AlertRule{
+		Name:      "load",
+		Topology:  "hosts",
+		Metric:    host.Load1,
+		Op:        ">",
+		Threshold: 0.001,
+		Node:      render.MakeHostID(fixture.ClientHostID),
+		For:       "2m",
+	}
+	if err := rule.validate(); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		rep     = &versionedReporter{fixedReporter{fixture.Report}, 1}
+		alerter = newAlerter(rep, []AlertRule{rule}, []AlertSink{nil})
+	)
+
+	check := func(state string, notified ...string) {
+		alerts := alerter.Alerts()
+		if state == "" && len(alerts) != 0 {
+			t.Fatalf("want no alerts, have %v", alerts)
+		}
+		if state != "" && (len(alerts) != 1 || alerts[0].State != state) {
+			t.Fatalf("want one %s alert, have %v", state, alerts)
+		}
+		have := []string{}
+		for len(alerter.notifications) > 0 {
+			have = append(have, (<-alerter.notifications).State)
+		}
+		if len(notified) == 0 {
+			notified = []string{}
+		}
+		if !reflect.DeepEqual(notified, have) {
+			t.Fatalf("want notifications %v, have %v", notified, have)
+		}
+	}
+
+	alerter.evaluate()
+	check(AlertPending)
+
+	timestamp = timestamp.Add(time.Minute)
+	alerter.evaluate()
+	check(AlertPending)
+
+	timestamp = timestamp.Add(time.Minute)
+	alerter.evaluate()
+	check(AlertFiring, AlertFiring)
+
+	alerter.evaluate()
+	check(AlertFiring)
+
+	rep.rpt = report.MakeReport()
+	rep.version++
+	alerter.evaluate()
+	check("", AlertResolved)
+}
+
+func TestAlerterEdgeRule(t *testing.T) {
+	rule := AlertRule{
+		Name:     "egress",
+		Topology: "applications",
+		Edge:     &AlertEdge{To: render.TheInternetID},
+	}
+	if err := rule.validate(); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		rep     = &versionedReporter{fixedReporter{fixture.Report}, 1}
+		alerter = newAlerter(rep, []AlertRule{rule}, nil)
+		update  = func(rpt report.Report) {
+			rep.rpt = rpt
+			rep.version++
+			alerter.evaluate()
+		}
+	)
+
+	// The edges there when we start aren't new.
+	alerter.evaluate()
+	if alerts := alerter.Alerts(); len(alerts) != 0 {
+		t.Fatalf("want no alerts for existing edges, have %v", alerts)
+	}
+
+	rep.rpt = report.MakeReport()
+	alerter = newAlerter(rep, []AlertRule{rule}, nil)
+	alerter.evaluate()
+	update(fixture.Report)
+	alerts := alerter.Alerts()
+	if len(alerts) == 0 {
+		t.Fatal("want alerts for new edges to the internet, have none")
+	}
+	for _, alert := range alerts {
+		if alert.State != AlertFiring || alert.Peer != render.TheInternetID {
+			t.Errorf("unexpected alert %v", alert)
+		}
+	}
+	update(fixture.Report)
+	if have := alerter.Alerts(); len(have) != len(alerts) {
+		t.Errorf("want the alerts to keep firing, have %v", have)
+	}
+
+	// Once they've gone, the same edges aren't new again.
+	update(report.MakeReport())
+	update(fixture.Report)
+	if alerts := alerter.Alerts(); len(alerts) != 0 {
+		t.Errorf("want no alerts for edges seen before, have %v", alerts)
+	}
+}
+
+func TestAlerterSharesRenders(t *testing.T) {
+	rule := AlertRule{Name: "load", Topology: "hosts", Metric: host.Load1, Op: ">"}
+	if err := rule.validate(); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector(time.Minute).(*collector)
+	c.Add(fixture.Report)
+	alerter := newAlerter(c, []AlertRule{rule}, nil)
+	alerter.evaluate()
+
+	// The topology routes would find the render of the hosts, with their
+	// default options, in the collector's cache.
+	topology, _ := topologyRegistry.get("hosts")
+	req, _ := http.NewRequest("GET", "/api/topology/hosts", nil)
+	if _, ok := c.cache.entries[renderCacheKey(topology, req)]; !ok || len(c.cache.entries) != 1 {
+		t.Errorf("want the hosts render cached, have %v", c.cache.entries)
+	}
+}
@@ -1,9 +1,12 @@
 package app
 
 import (
+	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -187,18 +190,21 @@ func (r *registry) walk(f func(APITopologyDesc)) {
 }
 
 // makeTopologyList returns a handler that yields an APITopologyList.
-func (r *registry) makeTopologyList(rep Reporter) func(w http.ResponseWriter, r *http.Request) {
+func (r *registry) makeTopologyList(rep Reporter, cache *renderCache) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var (
 			rpt        = rep.Report()
 			topologies = []APITopologyDesc{}
 		)
-		r.walk(func(desc APITopologyDesc) {
+		decorate := func(desc APITopologyDesc) topologyStats {
 			renderer := renderedForRequest(req, desc)
-			desc.Stats = decorateWithStats(rpt, renderer)
+			nodes := cache.render(rep, renderCacheKey(desc, req), renderer)
+			return decorateWithStats(rpt, nodes, renderer)
+		}
+		r.walk(func(desc APITopologyDesc) {
+			desc.Stats = decorate(desc)
 			for i := range desc.SubTopologies {
-				renderer := renderedForRequest(req, desc.SubTopologies[i])
-				desc.SubTopologies[i].Stats = decorateWithStats(rpt, renderer)
+				desc.SubTopologies[i].Stats = decorate(desc.SubTopologies[i])
 			}
 			topologies = append(topologies, desc)
 		})
@@ -206,13 +212,13 @@ func (r *registry) makeTopologyList(rep Reporter) func(w http.ResponseWriter, r
 	}
 }
 
-func decorateWithStats(rpt report.Report, renderer render.Renderer) topologyStats {
+func decorateWithStats(rpt report.Report, rendered render.RenderableNodes, renderer render.Renderer) topologyStats {
 	var (
 		nodes     int
 		realNodes int
 		edges     int
 	)
-	for _, n := range renderer.Render(rpt) {
+	for _, n := range rendered {
 		nodes++
 		if !n.Pseudo {
 			realNodes++
@@ -233,9 +239,16 @@ func (r *registry) enableKubernetesTopologies() {
 }
 
 func renderedForRequest(r *http.Request, topology APITopologyDesc) render.Renderer {
+	return renderedForOptions(topology, r.FormValue)
+}
+
+// renderedForOptions returns the renderer for the topology, decorated
+// according to the values of its options (or their defaults, where the value
+// is empty).
+func renderedForOptions(topology APITopologyDesc, option func(param string) string) render.Renderer {
 	renderer := topology.renderer
 	for param, opts := range topology.Options {
-		value := r.FormValue(param)
+		value := option(param)
 		for _, opt := range opts {
 			if (value == "" && opt.Default) || (opt.Value != "" && opt.Value == value) {
 				renderer = opt.decorator(renderer)
@@ -245,27 +258,90 @@ func renderedForRequest(r *http.Request, topology APITopologyDesc) render.Render
 	return renderer
 }
 
-type reportRenderHandler func(Reporter, render.Renderer, http.ResponseWriter, *http.Request)
+// fixedReporter is a Reporter which always yields the same report; it is
+// used to serve historic reports to handlers expecting a Reporter.
+type fixedReporter struct {
+	rpt report.Report
+}
+
+func (r fixedReporter) Report() report.Report { return r.rpt }
+func (r fixedReporter) WaitOn(chan struct{})  {}
+func (r fixedReporter) UnWait(chan struct{})  {}
+
+// parseTimestamp accepts either an RFC3339 time, or a number of seconds since
+// the unix epoch.
+func parseTimestamp(s string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// reporterForRequest returns rep, unless the request has a timestamp
+// parameter, in which case it returns a Reporter for the report of the
+// window ending at that timestamp.
+func reporterForRequest(rep Reporter, req *http.Request) (Reporter, error) {
+	value := req.FormValue("timestamp")
+	if value == "" {
+		return rep, nil
+	}
+	timestamp, err := parseTimestamp(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q", value)
+	}
+	historic, ok := rep.(HistoricReporter)
+	if !ok {
+		return nil, fmt.Errorf("historic reports not supported")
+	}
+	rpt, err := historic.ReportAt(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return fixedReporter{rpt}, nil
+}
+
+// topologyRenderer renders the current report for the topology (and
+// options) of a request.
+type topologyRenderer func() render.RenderableNodes
+
+type reportRenderHandler func(Reporter, topologyRenderer, http.ResponseWriter, *http.Request)
 
-func (r *registry) captureRenderer(rep Reporter, f reportRenderHandler) http.HandlerFunc {
+func (r *registry) captureRenderer(rep Reporter, cache *renderCache, f reportRenderHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		topology, ok := r.get(mux.Vars(req)["topology"])
 		if !ok {
 			http.NotFound(w, req)
 			return
 		}
-		renderer := renderedForRequest(req, topology)
-		f(rep, renderer, w, req)
+		rep, err := reporterForRequest(rep, req)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var (
+			renderer = renderedForRequest(req, topology)
+			key      = renderCacheKey(topology, req)
+		)
+		f(rep, func() render.RenderableNodes {
+			return cache.render(rep, key, renderer)
+		}, w, req)
 	}
 }
 
-func (r *registry) captureRendererWithoutFilters(rep Reporter, f reportRenderHandler) http.HandlerFunc {
+func (r *registry) captureRendererWithoutFilters(rep Reporter, cache *renderCache, f reportRenderHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		topology, ok := r.get(mux.Vars(req)["topology"])
 		if !ok {
 			http.NotFound(w, req)
 			return
 		}
-		f(rep, topology.renderer, w, req)
+		rep, err := reporterForRequest(rep, req)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f(rep, func() render.RenderableNodes {
+			return cache.render(rep, topology.id, topology.renderer)
+		}, w, req)
 	}
 }
@@ -54,7 +54,7 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 	router := mux.NewRouter()
 	c := app.NewCollector(1 * time.Minute)
 	app.RegisterTopologyRoutes(c, router)
-	app.RegisterReportPostHandler(c, router)
+	app.RegisterReportPostHandler(c, app.NewProbeAuth(nil), router)
 	ts := httptest.NewServer(router)
 	defer ts.Close()
 
@@ -1,13 +1,17 @@
 package app
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 
 	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
 )
 
 const (
@@ -25,15 +29,20 @@ type APINode struct {
 	Node render.DetailedNode `json:"node"`
 }
 
+// APIEdge is returned by the /api/topology/{name}/{src}/{dst} handler.
+type APIEdge struct {
+	Edge render.DetailedEdge `json:"edge"`
+}
+
 // Full topology.
-func handleTopology(rep Reporter, renderer render.Renderer, w http.ResponseWriter, r *http.Request) {
+func handleTopology(rep Reporter, renderTopology topologyRenderer, w http.ResponseWriter, r *http.Request) {
 	respondWith(w, http.StatusOK, APITopology{
-		Nodes: renderer.Render(rep.Report()).Prune(),
+		Nodes: renderTopology().Prune(),
 	})
 }
 
 // Websocket for the full topology. This route overlaps with the next.
-func handleWs(rep Reporter, renderer render.Renderer, w http.ResponseWriter, r *http.Request) {
+func handleWs(rep Reporter, renderTopology topologyRenderer, w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		respondWith(w, http.StatusInternalServerError, err.Error())
 		return
@@ -46,16 +55,16 @@ func handleWs(rep Reporter, renderer render.Renderer, w http.ResponseWriter, r *
 			return
 		}
 	}
-	handleWebsocket(w, r, rep, renderer, loop)
+	handleWebsocket(w, r, rep, renderTopology, loop)
 }
 
 // Individual nodes.
-func handleNode(rep Reporter, renderer render.Renderer, w http.ResponseWriter, r *http.Request) {
+func handleNode(rep Reporter, renderTopology topologyRenderer, w http.ResponseWriter, r *http.Request) {
 	var (
 		vars     = mux.Vars(r)
 		nodeID   = vars["id"]
 		rpt      = rep.Report()
-		node, ok = renderer.Render(rep.Report())[nodeID]
+		node, ok = renderTopology()[nodeID]
 	)
 	if !ok {
 		http.NotFound(w, r)
@@ -64,6 +73,117 @@ func handleNode(rep Reporter, renderer render.Renderer, w http.ResponseWriter, r
 	respondWith(w, http.StatusOK, APINode{Node: render.MakeDetailedNode(rpt, node)})
 }
 
+// Individual edges.
+func handleEdge(rep Reporter, renderTopology topologyRenderer, w http.ResponseWriter, r *http.Request) {
+	var (
+		vars  = mux.Vars(r)
+		srcID = vars["src"]
+		dstID = vars["dst"]
+		rpt   = rep.Report()
+		nodes = renderTopology()
+	)
+	src, ok := nodes[srcID]
+	if !ok || !src.Adjacency.Contains(dstID) {
+		http.NotFound(w, r)
+		return
+	}
+	dst, ok := nodes[dstID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	respondWith(w, http.StatusOK, APIEdge{Edge: render.MakeDetailedEdge(rpt, src, dst)})
+}
+
+// Metric history of individual nodes, averaged into steps if a step is
+// given. History is kept by the topologies and IDs of the rendered nodes,
+// independently of the reports, so this doesn't render anything, and works
+// for nodes which are gone from the current report. It's only kept for the
+// topologies whose nodes aren't aggregates (see metricTopologies).
+func makeNodeMetricHandler(rep Reporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			vars   = mux.Vars(r)
+			nodeID = vars["id"]
+			metric = vars["metric"]
+		)
+		topologyID := vars["topology"]
+		if _, ok := topologyRegistry.get(topologyID); !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if !hasMetricHistory(topologyID) {
+			respondWith(w, http.StatusBadRequest, fmt.Sprintf("no metric history for topology %s", topologyID))
+			return
+		}
+		history, ok := rep.(MetricHistory)
+		if !ok {
+			respondWith(w, http.StatusBadRequest, "metric history not supported")
+			return
+		}
+		from, to, step, err := parseMetricRange(r)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		samples := history.MetricSamples(topologyID, nodeID, metric, from, to)
+		if len(samples) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if step > 0 {
+			samples = resample(samples, from, step)
+		}
+
+		result := report.WireMetrics{
+			Samples: samples,
+			Min:     samples[0].Value,
+			Max:     samples[0].Value,
+			First:   samples[0].Timestamp.Format(time.RFC3339Nano),
+			Last:    samples[len(samples)-1].Timestamp.Format(time.RFC3339Nano),
+		}
+		for _, sample := range samples {
+			result.Min = math.Min(result.Min, sample.Value)
+			result.Max = math.Max(result.Max, sample.Value)
+		}
+		respondWith(w, http.StatusOK, result)
+	}
+}
+
+// parseMetricRange reads the from, to and step parameters of a metric
+// request. Times are as for the timestamp parameter; steps are durations
+// (e.g. 15s), or numbers of seconds. By default, we return the last hour at
+// full resolution.
+func parseMetricRange(r *http.Request) (from, to time.Time, step time.Duration, err error) {
+	to = now()
+	if value := r.FormValue("to"); value != "" {
+		if to, err = parseTimestamp(value); err != nil {
+			return from, to, step, fmt.Errorf("invalid to %q", value)
+		}
+	}
+	from = to.Add(-1 * time.Hour)
+	if value := r.FormValue("from"); value != "" {
+		if from, err = parseTimestamp(value); err != nil {
+			return from, to, step, fmt.Errorf("invalid from %q", value)
+		}
+	}
+	if value := r.FormValue("step"); value != "" {
+		if secs, perr := strconv.ParseFloat(value, 64); perr == nil {
+			step = time.Duration(secs * float64(time.Second))
+		} else if step, err = time.ParseDuration(value); err != nil {
+			return from, to, step, fmt.Errorf("invalid step %q", value)
+		}
+		if step <= 0 {
+			return from, to, step, fmt.Errorf("invalid step %q", value)
+		}
+	}
+	if from.After(to) {
+		return from, to, step, fmt.Errorf("from is after to")
+	}
+	return from, to, step, nil
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
@@ -72,7 +192,7 @@ func handleWebsocket(
 	w http.ResponseWriter,
 	r *http.Request,
 	rep Reporter,
-	renderer render.Renderer,
+	renderTopology topologyRenderer,
 	loop time.Duration,
 ) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -101,7 +221,7 @@ func handleWebsocket(
 	defer rep.UnWait(wait)
 
 	for {
-		newTopo := renderer.Render(rep.Report()).Prune()
+		newTopo := renderTopology().Prune()
 		diff := render.TopoDiff(previousTopo, newTopo)
 		previousTopo = newTopo
 
@@ -3,15 +3,21 @@ package app_test
 import (
 	"encoding/json"
 	"fmt"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 	"github.com/weaveworks/scope/test/fixture"
 )
@@ -89,6 +95,24 @@ func TestAPITopologyApplications(t *testing.T) {
 	}
 }
 
+func TestAPITopologyEdge(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is404(t, ts, "/api/topology/applications/"+expected.ClientProcess1ID+"/foobar")
+	is404(t, ts, "/api/topology/applications/"+expected.ServerProcessID+"/"+expected.ClientProcess1ID)
+	{
+		body := getRawJSON(t, ts, "/api/topology/applications/"+expected.ClientProcess1ID+"/"+expected.ServerProcessID)
+		var edge app.APIEdge
+		if err := json.Unmarshal(body, &edge); err != nil {
+			t.Fatal(err)
+		}
+		equals(t, expected.ClientProcess1ID, edge.Edge.Source)
+		equals(t, expected.ServerProcessID, edge.Edge.Target)
+		equals(t, uint64(100), *edge.Edge.Metadata.EgressByteCount)
+		// Let's not unit-test the specific content of the detail tables
+	}
+}
+
 func TestAPITopologyHosts(t *testing.T) {
 	ts := topologyServer()
 	defer ts.Close()
@@ -153,3 +177,78 @@ func TestAPITopologyWebsocket(t *testing.T) {
 }
 
 func newu64(value uint64) *uint64 { return &value }
+
+func TestAPITopologyTimestamp(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	is400(t, ts, "/api/topology/containers?timestamp=yesterday")
+	for _, timestamp := range []string{"1449000000", "2015-12-01T20:00:00Z"} {
+		body := getRawJSON(t, ts, "/api/topology/containers?timestamp="+timestamp)
+		var topo app.APITopology
+		if err := json.Unmarshal(body, &topo); err != nil {
+			t.Fatal(err)
+		}
+		if len(topo.Nodes) == 0 {
+			t.Errorf("%s: want nodes, have none", timestamp)
+		}
+	}
+}
+
+func TestAPITopologyNodeMetric(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	c.Add(fixture.Report)
+	app.RegisterTopologyRoutes(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	nodeURL := "/api/topology/hosts/" + url.QueryEscape(render.MakeHostID(fixture.ClientHostID))
+	is404(t, ts, nodeURL+"/metrics/foo")
+	is404(t, ts, "/api/topology/hosts/foo/metrics/load1")
+	is400(t, ts, nodeURL+"/metrics/load1?step=never")
+	is400(t, ts, nodeURL+"/metrics/load1?from=tomorrow")
+
+	body := getRawJSON(t, ts, nodeURL+"/metrics/load1?step=1m")
+	var metric report.WireMetrics
+	if err := json.Unmarshal(body, &metric); err != nil {
+		t.Fatal(err)
+	}
+	if len(metric.Samples) != 1 || metric.Samples[0].Value != 0.01 {
+		t.Errorf("unexpected samples: %v", metric.Samples)
+	}
+
+	// Samples before the range aren't returned.
+	to := fmt.Sprintf("%d", fixture.Now.Add(-time.Minute).Unix())
+	is404(t, ts, nodeURL+"/metrics/load1?to="+to)
+
+	// History doesn't depend on the report being viewed.
+	getRawJSON(t, ts, nodeURL+"/metrics/load1?timestamp="+to)
+
+	// Nor on the node still being rendered.
+	rpt := report.MakeReport()
+	rpt.Container.AddNode(report.MakeContainerNodeID(fixture.ClientHostID, "gone"), report.MakeNodeWith(map[string]string{
+		docker.ContainerID: "gone",
+	}).WithLatest(docker.ContainerState, fixture.Now, docker.StateDeleted).WithMetrics(report.Metrics{
+		docker.CPUTotalUsage: fixture.LoadMetric,
+	}))
+	c.Add(rpt)
+	getRawJSON(t, ts, "/api/topology/containers/gone/metrics/"+docker.CPUTotalUsage)
+
+	// History is kept per topology, including applications, whose nodes are
+	// processes.
+	processID := render.MakeProcessID(fixture.ClientHostID, "4242")
+	rpt = report.MakeReport()
+	rpt.Process.AddNode(report.MakeProcessNodeID(fixture.ClientHostID, "4242"), report.MakeNodeWith(map[string]string{
+		report.HostNodeID: report.MakeHostNodeID(fixture.ClientHostID),
+		process.PID:       "4242",
+	}).WithMetrics(report.Metrics{
+		process.CPUUsage: fixture.LoadMetric,
+	}))
+	c.Add(rpt)
+	getRawJSON(t, ts, "/api/topology/applications/"+url.QueryEscape(processID)+"/metrics/"+process.CPUUsage)
+	is404(t, ts, "/api/topology/containers/"+url.QueryEscape(processID)+"/metrics/"+process.CPUUsage)
+
+	// But not for topologies whose nodes are aggregates.
+	is400(t, ts, "/api/topology/containers-by-image/"+url.QueryEscape(fixture.ClientContainerImageName)+"/metrics/"+docker.CPUTotalUsage)
+}
@@ -1,9 +1,11 @@
 package app
 
 import (
+	"log"
 	"sync"
 	"time"
 
+	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -21,9 +23,17 @@ type Adder interface {
 	Add(report.Report)
 }
 
+// HistoricReporter is something that can produce reports for points in the
+// past, as well as the present.
+type HistoricReporter interface {
+	ReportAt(time.Time) (report.Report, error)
+}
+
 // A Collector is a Reporter and an Adder
 type Collector interface {
-	Reporter
+	VersionedReporter
+	HistoricReporter
+	MetricHistory
 	Adder
 }
 
@@ -32,7 +42,12 @@ type Collector interface {
 type collector struct {
 	mtx     sync.Mutex
 	reports []timestampReport
+	version uint64
 	window  time.Duration
+	store   ReportStore
+	groups  render.ExternalGroups
+	metrics *metricStore
+	cache   *renderCache
 	waitableCondition
 }
 
@@ -67,8 +82,20 @@ func (wc *waitableCondition) Broadcast() {
 
 // NewCollector returns a collector ready for use.
 func NewCollector(window time.Duration) Collector {
+	return NewCollectorWithStore(window, nil, nil)
+}
+
+// NewCollectorWithStore returns a collector ready for use, which also
+// persists every report it is given to store. A nil store keeps no history
+// beyond the window. The reports it yields have their remote nodes tagged
+// with the external groups they're in.
+func NewCollectorWithStore(window time.Duration, store ReportStore, groups render.ExternalGroups) Collector {
 	return &collector{
-		window: window,
+		window:  window,
+		store:   store,
+		groups:  groups,
+		metrics: newMetricStore(),
+		cache:   newRenderCache(),
 		waitableCondition: waitableCondition{
 			waiters: map[chan struct{}]struct{}{},
 		},
@@ -79,13 +106,24 @@ var now = time.Now
 
 // Add adds a report to the collector's internal state. It implements Adder.
 func (c *collector) Add(rpt report.Report) {
+	timestamp := now()
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
-	c.reports = append(c.reports, timestampReport{now(), rpt})
-	c.reports = clean(c.reports, c.window)
+	c.reports = append(c.reports, timestampReport{timestamp, rpt})
+	c.version++
+	c.clean()
+	c.mtx.Unlock()
+
 	if rpt.Shortcut {
 		c.Broadcast()
 	}
+
+	c.metrics.add(rpt)
+
+	if c.store != nil {
+		if err := c.store.Put(timestamp, rpt); err != nil {
+			log.Printf("Error storing report: %v", err)
+		}
+	}
 }
 
 // Report returns a merged report over all added reports. It implements
@@ -94,13 +132,78 @@ func (c *collector) Report() report.Report {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	c.reports = clean(c.reports, c.window)
+	c.clean()
 
 	rpt := report.MakeReport()
 	for _, tr := range c.reports {
 		rpt = rpt.Merge(tr.report)
 	}
-	return rpt
+	// Counts in the reports (e.g. from packet capture) add up over the
+	// whole window, so that's what rates should be derived with. Probes
+	// leave it unset, as merging their windows would add them up too.
+	rpt.Window = c.window
+	return c.groups.Apply(rpt)
+}
+
+// Version returns the version of the merged report, which changes whenever a
+// report is added or expires. It implements VersionedReporter.
+func (c *collector) Version() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clean()
+	return c.version
+}
+
+// clean drops the reports which have fallen out of the window. Must be
+// called with the lock held.
+func (c *collector) clean() {
+	cleaned := clean(c.reports, c.window)
+	if len(cleaned) != len(c.reports) {
+		c.version++
+	}
+	c.reports = cleaned
+}
+
+// ReportAt returns a merged report over all reports added in the window
+// ending at t. It implements HistoricReporter. Without a store, only times
+// within the current window can be answered accurately.
+func (c *collector) ReportAt(t time.Time) (report.Report, error) {
+	var (
+		from    = t.Add(-c.window)
+		reports []report.Report
+	)
+	if c.store != nil {
+		var err error
+		if reports, err = c.store.Get(from, t); err != nil {
+			return report.MakeReport(), err
+		}
+	} else {
+		c.mtx.Lock()
+		for _, tr := range c.reports {
+			if !tr.timestamp.Before(from) && !tr.timestamp.After(t) {
+				reports = append(reports, tr.report)
+			}
+		}
+		c.mtx.Unlock()
+	}
+
+	rpt := report.MakeReport()
+	for _, r := range reports {
+		rpt = rpt.Merge(r)
+	}
+	rpt.Window = c.window
+	return c.groups.Apply(rpt), nil
+}
+
+// MetricSamples implements MetricHistory.
+func (c *collector) MetricSamples(topologyID, nodeID, metric string, from, through time.Time) []report.Sample {
+	return c.metrics.MetricSamples(topologyID, nodeID, metric, from, through)
+}
+
+// sharedRenderCache is the cache of renders of the collector's reports, shared
+// by the topology routes and the alerter.
+func (c *collector) sharedRenderCache() *renderCache {
+	return c.cache
 }
 
 type timestampReport struct {
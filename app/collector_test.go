@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 )
@@ -20,12 +22,17 @@ func TestCollector(t *testing.T) {
 	r2 := report.MakeReport()
 	r2.Endpoint.AddNode("bar", report.MakeNode())
 
-	if want, have := report.MakeReport(), c.Report(); !reflect.DeepEqual(want, have) {
+	// The merged report spans the collector's window.
+	want := report.MakeReport()
+	want.Window = window
+	if have := c.Report(); !reflect.DeepEqual(want, have) {
 		t.Error(test.Diff(want, have))
 	}
 
 	c.Add(r1)
-	if want, have := r1, c.Report(); !reflect.DeepEqual(want, have) {
+	want = r1
+	want.Window = window
+	if have := c.Report(); !reflect.DeepEqual(want, have) {
 		t.Error(test.Diff(want, have))
 	}
 
@@ -34,11 +41,43 @@ func TestCollector(t *testing.T) {
 	merged := report.MakeReport()
 	merged = merged.Merge(r1)
 	merged = merged.Merge(r2)
+	merged.Window = window
 	if want, have := merged, c.Report(); !reflect.DeepEqual(want, have) {
 		t.Error(test.Diff(want, have))
 	}
 }
 
+func TestCollectorExternalGroups(t *testing.T) {
+	groups, err := render.MakeExternalGroups([]render.ExternalGroup{
+		{Name: "partner API", CIDRs: []string{"203.0.113.0/24"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each collector (e.g. each tenant's) only tags its reports with its own
+	// groups.
+	var (
+		grouped   = app.NewCollectorWithStore(time.Minute, nil, groups)
+		ungrouped = app.NewCollector(time.Minute)
+		id        = report.MakeEndpointNodeID("", "203.0.113.7", "443")
+	)
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(id, report.MakeNodeWith(map[string]string{endpoint.Addr: "203.0.113.7"}))
+	grouped.Add(rpt)
+	ungrouped.Add(rpt)
+
+	if want, have := "partner API", grouped.Report().Endpoint.Nodes[id].Metadata[render.ExternalGroupName]; want != have {
+		t.Errorf("want group %q, have %q", want, have)
+	}
+	if have, ok := ungrouped.Report().Endpoint.Nodes[id].Metadata[render.ExternalGroupName]; ok {
+		t.Errorf("want no group, have %q", have)
+	}
+	if _, ok := rpt.Endpoint.Nodes[id].Metadata[render.ExternalGroupName]; ok {
+		t.Error("the added report was modified")
+	}
+}
+
 func TestCollectorWait(t *testing.T) {
 	window := time.Millisecond
 	c := app.NewCollector(window)
@@ -56,3 +95,51 @@ func TestCollectorWait(t *testing.T) {
 		t.Fatal("Didn't unblock")
 	}
 }
+
+func TestCollectorReportAt(t *testing.T) {
+	window := time.Minute
+	c := app.NewCollector(window)
+
+	r1 := report.MakeReport()
+	r1.Endpoint.AddNode("foo", report.MakeNode())
+	c.Add(r1)
+
+	have, err := c.ReportAt(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := r1
+	want.Window = window
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	have, err = c.ReportAt(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = report.MakeReport()
+	want.Window = window
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestCollectorVersion(t *testing.T) {
+	c := app.NewCollector(50 * time.Millisecond)
+
+	v0 := c.Version()
+	c.Add(report.MakeReport())
+	v1 := c.Version()
+	if v1 == v0 {
+		t.Fatal("version didn't change when a report was added")
+	}
+	if v := c.Version(); v != v1 {
+		t.Fatal("version changed without a new report")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if v := c.Version(); v == v1 {
+		t.Fatal("version didn't change when a report expired")
+	}
+}
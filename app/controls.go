@@ -1,10 +1,14 @@
 package app
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"net/rpc"
+	"strconv"
 	"sync"
 
 	"github.com/gorilla/mux"
@@ -13,11 +17,12 @@ import (
 )
 
 // RegisterControlRoutes registers the various control routes with a http mux.
-func RegisterControlRoutes(router *mux.Router) {
+// Probes without an accepted token cannot connect.
+func RegisterControlRoutes(auth *ProbeAuth, router *mux.Router) {
 	controlRouter := &controlRouter{
 		probes: map[string]controlHandler{},
 	}
-	router.Methods("GET").Path("/api/control/ws").HandlerFunc(controlRouter.handleProbeWS)
+	router.Methods("GET").Path("/api/control/ws").HandlerFunc(auth.Wrap(controlRouter.handleProbeWS))
 	router.Methods("POST").MatcherFunc(URLMatcher("/api/control/{probeID}/{nodeID}/{control}")).HandlerFunc(controlRouter.handleControl)
 }
 
@@ -78,10 +83,17 @@ func (cr *controlRouter) handleControl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	args, err := decodeControlArgs(r.Body)
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	result := handler.handle(xfer.Request{
 		AppID:   UniqueID,
 		NodeID:  nodeID,
 		Control: control,
+		Args:    args,
 	})
 	if result.Error != "" {
 		respondWith(w, http.StatusBadRequest, result.Error)
@@ -90,6 +102,35 @@ func (cr *controlRouter) handleControl(w http.ResponseWriter, r *http.Request) {
 	respondWith(w, http.StatusOK, result)
 }
 
+// decodeControlArgs decodes the arguments of a control request, if any,
+// from a JSON object of strings, numbers and bools, e.g. {"replicas": 3}.
+// They're passed on as strings; the probe validates them against the params
+// of the control.
+func decodeControlArgs(body io.Reader) (map[string]string, error) {
+	var in map[string]interface{}
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&in); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	args := make(map[string]string, len(in))
+	for name, value := range in {
+		switch v := value.(type) {
+		case string:
+			args[name] = v
+		case json.Number:
+			args[name] = v.String()
+		case bool:
+			args[name] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("argument %q: not a string, number or bool", name)
+		}
+	}
+	return args, nil
+}
+
 // handleProbeWS accepts websocket connections from the probe and registers
 // them in the control router, such that HandleControl calls can find them.
 func (cr *controlRouter) handleProbeWS(w http.ResponseWriter, r *http.Request) {
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -17,7 +18,7 @@ import (
 
 func TestControl(t *testing.T) {
 	router := mux.NewRouter()
-	app.RegisterControlRoutes(router)
+	app.RegisterControlRoutes(app.NewProbeAuth(nil), router)
 	server := httptest.NewServer(router)
 	defer server.Close()
 
@@ -38,6 +39,10 @@ func TestControl(t *testing.T) {
 			t.Fatalf("'%s' != 'control'", req.Control)
 		}
 
+		if want := map[string]string{"foo": "bar", "n": "3", "force": "true"}; !reflect.DeepEqual(want, req.Args) {
+			t.Fatalf("%v != %v", req.Args, want)
+		}
+
 		return xfer.Response{
 			Value: "foo",
 		}
@@ -54,7 +59,7 @@ func TestControl(t *testing.T) {
 	httpClient := http.Client{
 		Timeout: 1 * time.Second,
 	}
-	resp, err := httpClient.Post(server.URL+"/api/control/foo/nodeid/control", "", nil)
+	resp, err := httpClient.Post(server.URL+"/api/control/foo/nodeid/control", "application/json", strings.NewReader(`{"foo": "bar", "n": 3, "force": true}`))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -67,4 +72,12 @@ func TestControl(t *testing.T) {
 	if response.Value != "foo" {
 		t.Fatalf("'%s' != 'foo'", response.Value)
 	}
+
+	resp, err = httpClient.Post(server.URL+"/api/control/foo/nodeid/control", "application/json", strings.NewReader(`{"foo": ["bar"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("%d != %d", resp.StatusCode, http.StatusBadRequest)
+	}
 }
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// deltaStateTTL is how long we keep the last report of a probe which has
+// stopped publishing.
+const deltaStateTTL = 5 * time.Minute
+
+var (
+	errMissingProbeID = errors.New("deltas must carry a probe ID")
+	errMissingBase    = errors.New("missing the report this delta is based on; publish a full report")
+)
+
+// deltaTracker reconstructs the reports of probes which publish deltas, by
+// applying each delta to the last report from the same probe.
+type deltaTracker struct {
+	mtx    sync.Mutex
+	probes map[string]probeReport // probe ID -> last report
+}
+
+type probeReport struct {
+	seq      uint64
+	report   report.Report
+	lastSeen time.Time
+}
+
+func newDeltaTracker() *deltaTracker {
+	return &deltaTracker{
+		probes: map[string]probeReport{},
+	}
+}
+
+// apply returns the report the delta produces. If we don't have the report
+// the delta is based on, because we missed a delta or have restarted, it
+// returns errMissingBase; the probe should then send a full report.
+func (t *deltaTracker) apply(probeID string, d report.Delta) (report.Report, error) {
+	if probeID == "" {
+		return report.MakeReport(), errMissingProbeID
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timestamp := now()
+	for id, p := range t.probes {
+		if timestamp.Sub(p.lastSeen) > deltaStateTTL {
+			delete(t.probes, id)
+		}
+	}
+
+	p, ok := t.probes[probeID]
+	if !d.Full && (!ok || p.seq != d.BaseSeq) {
+		delete(t.probes, probeID)
+		return report.MakeReport(), errMissingBase
+	}
+	p = probeReport{
+		seq:      d.Seq,
+		report:   p.report.Apply(d),
+		lastSeen: timestamp,
+	}
+	t.probes[probeID] = p
+	return p.report, nil
+}
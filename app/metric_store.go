@@ -0,0 +1,269 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// We keep samples at full resolution for rawMetricRetention, and then
+	// downsample them to one (averaged) sample per metricDownsampleInterval,
+	// which we keep for metricRetention.
+	rawMetricRetention       = 1 * time.Hour
+	metricDownsampleInterval = 1 * time.Minute
+	metricRetention          = 24 * time.Hour
+)
+
+// MetricHistory is something that can produce the history of the metrics on
+// (rendered) nodes, beyond what's in the reports of the current window.
+type MetricHistory interface {
+	MetricSamples(topologyID, nodeID, metric string, from, through time.Time) []report.Sample
+}
+
+// metricTopologies are the topologies we keep metric history for, by their
+// IDs in the topology registry. Their nodes are each rendered from a single
+// report node, so the history can be recorded without rendering. Nodes of
+// other topologies, e.g. containers-by-image, aggregate many report nodes.
+var metricTopologies = []struct {
+	id         string
+	topology   func(report.Report) report.Topology
+	renderedID func(report.Node) (string, bool)
+}{
+	{"hosts", func(r report.Report) report.Topology { return r.Host }, renderedHostID},
+	{"containers", func(r report.Report) report.Topology { return r.Container }, renderedContainerID},
+	{"applications", func(r report.Report) report.Topology { return r.Process }, renderedProcessID},
+}
+
+// hasMetricHistory is true if we keep metric history for the topology.
+func hasMetricHistory(topologyID string) bool {
+	for _, t := range metricTopologies {
+		if t.id == topologyID {
+			return true
+		}
+	}
+	return false
+}
+
+// metricStore is an in-memory MetricHistory, fed with every report the
+// collector receives. Reports repeat samples for their whole window, so we
+// only record the samples newer than the ones we already have. Series are
+// keyed by the topologies and IDs of the rendered nodes, so history can be
+// looked up without rendering, even for nodes which are no longer in any
+// report.
+type metricStore struct {
+	mtx        sync.Mutex
+	series     map[metricNode]map[string]*metricSeries // metric -> series
+	lastExpire time.Time
+}
+
+// metricNode is a rendered node we keep metric history for.
+type metricNode struct {
+	topologyID, nodeID string
+}
+
+// metricSeries holds the samples of a single metric on a single node, oldest
+// first.
+type metricSeries struct {
+	raw         []metricPoint
+	downsampled []metricPoint
+}
+
+type metricPoint struct {
+	timestamp int64 // unix nanoseconds
+	value     float64
+	count     int // number of raw samples averaged into value
+}
+
+func newMetricStore() *metricStore {
+	return &metricStore{
+		series: map[metricNode]map[string]*metricSeries{},
+	}
+}
+
+// add records the metrics of the nodes of rpt in the metricTopologies.
+func (s *metricStore) add(rpt report.Report) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, t := range metricTopologies {
+		for _, node := range t.topology(rpt).Nodes {
+			nodeID, ok := t.renderedID(node)
+			if !ok {
+				continue
+			}
+			for name, metric := range node.Metrics {
+				s.addMetric(metricNode{t.id, nodeID}, name, metric)
+			}
+		}
+	}
+	if now().Sub(s.lastExpire) >= metricDownsampleInterval {
+		s.expire()
+	}
+}
+
+// renderedHostID, renderedContainerID and renderedProcessID return the IDs
+// of the nodes the given report nodes are rendered as, as in
+// render.MapHostIdentity, MapContainerIdentity and MapProcessIdentity.
+func renderedHostID(n report.Node) (string, bool) {
+	hostID := report.ExtractHostID(n)
+	return render.MakeHostID(hostID), hostID != ""
+}
+
+func renderedContainerID(n report.Node) (string, bool) {
+	id, ok := n.Metadata[docker.ContainerID]
+	return id, ok
+}
+
+func renderedProcessID(n report.Node) (string, bool) {
+	pid, ok := n.Metadata[process.PID]
+	return render.MakeProcessID(report.ExtractHostID(n), pid), ok
+}
+
+func (s *metricStore) addMetric(node metricNode, name string, metric report.Metric) {
+	if metric.Len() == 0 {
+		return
+	}
+	metrics, ok := s.series[node]
+	if !ok {
+		metrics = map[string]*metricSeries{}
+		s.series[node] = metrics
+	}
+	series, ok := metrics[name]
+	if !ok {
+		series = &metricSeries{}
+		metrics[name] = series
+	}
+
+	// Samples are stored newest first; collect the new ones, and then append
+	// them oldest first.
+	latest := series.latest()
+	points := []metricPoint{}
+	for curr := metric.Samples; !curr.IsNil(); curr = curr.Tail() {
+		sample := curr.Head().(report.Sample)
+		ts := sample.Timestamp.UnixNano()
+		if ts <= latest {
+			break
+		}
+		points = append(points, metricPoint{ts, sample.Value, 1})
+	}
+	for i := len(points) - 1; i >= 0; i-- {
+		series.raw = append(series.raw, points[i])
+	}
+}
+
+// expire downsamples old raw samples, and deletes series which have had no
+// samples for the whole retention. Must be called with the lock held.
+func (s *metricStore) expire() {
+	var (
+		timestamp  = now()
+		rawOldest  = timestamp.Add(-rawMetricRetention).UnixNano()
+		oldest     = timestamp.Add(-metricRetention).UnixNano()
+		interval   = int64(metricDownsampleInterval)
+		emptyNodes = []metricNode{}
+	)
+	s.lastExpire = timestamp
+	for node, metrics := range s.series {
+		for name, series := range metrics {
+			i := 0
+			for ; i < len(series.raw) && series.raw[i].timestamp < rawOldest; i++ {
+				series.downsample(series.raw[i], interval)
+			}
+			series.raw = series.raw[i:]
+
+			j := sort.Search(len(series.downsampled), func(j int) bool {
+				return series.downsampled[j].timestamp >= oldest
+			})
+			series.downsampled = series.downsampled[j:]
+
+			if len(series.raw) == 0 && len(series.downsampled) == 0 {
+				delete(metrics, name)
+			}
+		}
+		if len(metrics) == 0 {
+			emptyNodes = append(emptyNodes, node)
+		}
+	}
+	for _, node := range emptyNodes {
+		delete(s.series, node)
+	}
+}
+
+func (s *metricSeries) latest() int64 {
+	if len(s.raw) > 0 {
+		return s.raw[len(s.raw)-1].timestamp
+	}
+	if len(s.downsampled) > 0 {
+		return s.downsampled[len(s.downsampled)-1].timestamp
+	}
+	return 0
+}
+
+// downsample folds p into the average for its interval.
+func (s *metricSeries) downsample(p metricPoint, interval int64) {
+	bucket := p.timestamp - p.timestamp%interval
+	if n := len(s.downsampled); n > 0 && s.downsampled[n-1].timestamp == bucket {
+		last := &s.downsampled[n-1]
+		last.value = (last.value*float64(last.count) + p.value) / float64(last.count+1)
+		last.count++
+		return
+	}
+	s.downsampled = append(s.downsampled, metricPoint{bucket, p.value, 1})
+}
+
+// MetricSamples implements MetricHistory. It returns the samples of the
+// metric on the node of the topology between from and through (inclusive),
+// oldest first.
+func (s *metricStore) MetricSamples(topologyID, nodeID, metric string, from, through time.Time) []report.Sample {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	samples := []report.Sample{}
+	series, ok := s.series[metricNode{topologyID, nodeID}][metric]
+	if !ok {
+		return samples
+	}
+	start, end := from.UnixNano(), through.UnixNano()
+	for _, points := range [][]metricPoint{series.downsampled, series.raw} {
+		i := sort.Search(len(points), func(i int) bool { return points[i].timestamp >= start })
+		for ; i < len(points) && points[i].timestamp <= end; i++ {
+			samples = append(samples, report.Sample{
+				Timestamp: time.Unix(0, points[i].timestamp),
+				Value:     points[i].value,
+			})
+		}
+	}
+	return samples
+}
+
+// resample averages samples (sorted oldest first) into one sample per step,
+// starting at from. Steps without samples are omitted.
+func resample(samples []report.Sample, from time.Time, step time.Duration) []report.Sample {
+	result := []report.Sample{}
+	var (
+		bucket time.Time
+		sum    float64
+		count  int
+	)
+	flush := func() {
+		if count > 0 {
+			result = append(result, report.Sample{Timestamp: bucket, Value: sum / float64(count)})
+		}
+	}
+	for _, sample := range samples {
+		b := from.Add(sample.Timestamp.Sub(from) / step * step)
+		if !b.Equal(bucket) {
+			flush()
+			bucket, sum, count = b, 0, 0
+		}
+		sum += sample.Value
+		count++
+	}
+	flush()
+	return result
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestMetricStore(t *testing.T) {
+	var (
+		start  = time.Unix(1450000000, 0)
+		store  = newMetricStore()
+		hostID = render.MakeHostID("host1")
+		node   = func(m report.Metric) report.Report {
+			rpt := report.MakeReport()
+			rpt.Host.AddNode(report.MakeHostNodeID("host1"), report.MakeNodeWith(map[string]string{
+				report.HostNodeID: report.MakeHostNodeID("host1"),
+			}).WithMetrics(report.Metrics{"load1": m}))
+			return rpt
+		}
+	)
+	defer func() { now = time.Now }()
+	now = func() time.Time { return start }
+
+	// Reports repeat samples; we should only record each once.
+	m := report.MakeMetric().Add(start, 1).Add(start.Add(time.Second), 2)
+	store.add(node(m))
+	m = m.Add(start.Add(2*time.Second), 3)
+	store.add(node(m))
+
+	want := []report.Sample{
+		{Timestamp: time.Unix(0, start.UnixNano()), Value: 1},
+		{Timestamp: time.Unix(0, start.Add(time.Second).UnixNano()), Value: 2},
+		{Timestamp: time.Unix(0, start.Add(2*time.Second).UnixNano()), Value: 3},
+	}
+	have := store.MetricSamples("hosts", hostID, "load1", start, start.Add(time.Minute))
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+	if have := store.MetricSamples("hosts", hostID, "load1", start.Add(time.Second), start.Add(time.Second)); len(have) != 1 {
+		t.Errorf("want 1 sample, have %v", have)
+	}
+
+	// Once they're older than the raw retention, samples get averaged per
+	// interval.
+	now = func() time.Time { return start.Add(rawMetricRetention + time.Hour) }
+	store.add(report.MakeReport())
+	want = []report.Sample{
+		{Timestamp: time.Unix(0, start.Truncate(metricDownsampleInterval).UnixNano()), Value: 2},
+	}
+	have = store.MetricSamples("hosts", hostID, "load1", start.Add(-time.Hour), start.Add(time.Minute))
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+
+	// And are eventually forgotten.
+	now = func() time.Time { return start.Add(metricRetention + time.Hour) }
+	store.add(report.MakeReport())
+	if have := store.MetricSamples("hosts", hostID, "load1", start.Add(-time.Hour), start.Add(time.Minute)); len(have) != 0 {
+		t.Errorf("want no samples, have %v", have)
+	}
+	if len(store.series) != 0 {
+		t.Errorf("want no series, have %d", len(store.series))
+	}
+}
+
+func TestResample(t *testing.T) {
+	start := time.Unix(1450000000, 0)
+	samples := []report.Sample{
+		{Timestamp: start, Value: 1},
+		{Timestamp: start.Add(5 * time.Second), Value: 3},
+		{Timestamp: start.Add(25 * time.Second), Value: 4},
+	}
+	want := []report.Sample{
+		{Timestamp: start, Value: 2},
+		{Timestamp: start.Add(20 * time.Second), Value: 4},
+	}
+	if have := resample(samples, start, 10*time.Second); !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	metricsNamespace = "scope"
+	k8sPodNameLabel  = docker.LabelPrefix + "io.kubernetes.pod.name"
+)
+
+var invalidMetricChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// Prometheus label names for the node metadata we export.
+const (
+	hostLabel          = "host"
+	containerIDLabel   = "container_id"
+	containerNameLabel = "container_name"
+	imageLabel         = "image"
+	namespaceLabel     = "kubernetes_namespace"
+	podLabel           = "kubernetes_pod_name"
+	pidLabel           = "pid"
+	commLabel          = "comm"
+)
+
+// makeMetricsHandler returns a handler which exports the latest sample of
+// every metric in the merged report, in the Prometheus exposition format.
+func makeMetricsHandler(rep Reporter) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.Negotiate(r.Header)
+		w.Header().Set("Content-Type", string(format))
+		encoder := expfmt.NewEncoder(w, format)
+		for _, family := range metricFamilies(rep.Report()) {
+			if err := encoder.Encode(family); err != nil {
+				log.Printf("Error encoding metrics: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// metricFamilies turns the metrics on host, container and process nodes into
+// Prometheus gauges, one family per (topology, metric), sorted by name.
+func metricFamilies(rpt report.Report) []*dto.MetricFamily {
+	families := map[string]*dto.MetricFamily{}
+	for _, t := range []struct {
+		name     string
+		topology report.Topology
+	}{
+		{"host", rpt.Host},
+		{"container", rpt.Container},
+		{"process", rpt.Process},
+	} {
+		for _, node := range t.topology.Nodes {
+			if len(node.Metrics) == 0 {
+				continue
+			}
+			labels := labelPairs(nodeLabels(rpt, t.name, node))
+			for key, metric := range node.Metrics {
+				sample := metric.LastSample()
+				if sample == nil {
+					continue
+				}
+				name := metricName(t.name, key)
+				family, ok := families[name]
+				if !ok {
+					family = &dto.MetricFamily{
+						Name: proto.String(name),
+						Help: proto.String("Scope " + t.name + " metric " + key),
+						Type: dto.MetricType_GAUGE.Enum(),
+					}
+					families[name] = family
+				}
+				family.Metric = append(family.Metric, &dto.Metric{
+					Label:       labels,
+					Gauge:       &dto.Gauge{Value: proto.Float64(sample.Value)},
+					TimestampMs: proto.Int64(sample.Timestamp.UnixNano() / 1e6),
+				})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*dto.MetricFamily, 0, len(families))
+	for _, name := range names {
+		family := families[name]
+		sort.Sort(metricsByLabels(family.Metric))
+		result = append(result, family)
+	}
+	return result
+}
+
+func metricName(topology, key string) string {
+	return metricsNamespace + "_" + topology + "_" + invalidMetricChars.ReplaceAllString(key, "_")
+}
+
+// nodeLabels works out the labels for a node's series from its metadata, and
+// from the metadata of the container (and image) it belongs to.
+func nodeLabels(rpt report.Report, topology string, node report.Node) map[string]string {
+	hostID := report.ExtractHostID(node)
+	labels := map[string]string{
+		hostLabel: hostID,
+	}
+
+	switch topology {
+	case "host":
+		if hostName, ok := node.Metadata[host.HostName]; ok {
+			labels[hostLabel] = hostName
+		}
+		return labels
+	case "process":
+		labels[pidLabel] = node.Metadata[process.PID]
+		labels[commLabel] = node.Metadata[process.Comm]
+		containerID, ok := node.Metadata[docker.ContainerID]
+		if !ok {
+			return labels
+		}
+		container, ok := rpt.Container.Nodes[report.MakeContainerNodeID(hostID, containerID)]
+		if !ok {
+			labels[containerIDLabel] = containerID
+			return labels
+		}
+		node = container
+	}
+
+	labels[containerIDLabel] = node.Metadata[docker.ContainerID]
+	labels[containerNameLabel] = node.Metadata[docker.ContainerName]
+	if imageID, ok := node.Metadata[docker.ImageID]; ok {
+		labels[imageLabel] = imageID
+		if image, ok := rpt.ContainerImage.Nodes[report.MakeContainerNodeID(hostID, imageID)]; ok {
+			if imageName, ok := image.Metadata[docker.ImageName]; ok {
+				labels[imageLabel] = imageName
+			}
+		}
+	}
+	if pod, ok := node.Metadata[k8sPodNameLabel]; ok {
+		if s := strings.SplitN(pod, "/", 2); len(s) == 2 {
+			labels[namespaceLabel] = s[0]
+			labels[podLabel] = s[1]
+		}
+	}
+	return labels
+}
+
+// labelPairs turns labels into sorted label pairs, skipping empty values (as
+// Prometheus treats those as absent anyway).
+func labelPairs(labels map[string]string) []*dto.LabelPair {
+	result := []*dto.LabelPair{}
+	for name, value := range labels {
+		if value == "" {
+			continue
+		}
+		result = append(result, &dto.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value),
+		})
+	}
+	sort.Sort(labelPairsByName(result))
+	return result
+}
+
+type labelPairsByName []*dto.LabelPair
+
+func (a labelPairsByName) Len() int           { return len(a) }
+func (a labelPairsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a labelPairsByName) Less(i, j int) bool { return a[i].GetName() < a[j].GetName() }
+
+type metricsByLabels []*dto.Metric
+
+func (a metricsByLabels) Len() int      { return len(a) }
+func (a metricsByLabels) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a metricsByLabels) Less(i, j int) bool {
+	return labelString(a[i].Label) < labelString(a[j].Label)
+}
+
+func labelString(pairs []*dto.LabelPair) string {
+	parts := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		parts = append(parts, pair.GetName()+"="+pair.GetValue())
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,74 @@
+package app_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	body := string(is200(t, ts, "/metrics"))
+	for _, want := range []string{
+		"# TYPE scope_host_load1 gauge",
+		`scope_host_load1{host="` + fixture.ClientHostName + `"} 0.01`,
+		`scope_host_load15{host="` + fixture.ServerHostName + `"} 0.01`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsHandlerLabels(t *testing.T) {
+	var (
+		now    = time.Now()
+		hostID = "host1"
+		rpt    = report.MakeReport()
+	)
+	rpt.Container.AddNode(report.MakeContainerNodeID(hostID, "c1"), report.MakeNodeWith(map[string]string{
+		docker.ContainerID:                            "c1",
+		docker.ContainerName:                          "web",
+		docker.ImageID:                                "i1",
+		docker.LabelPrefix + "io.kubernetes.pod.name": "prod/web-1",
+		report.HostNodeID:                             report.MakeHostNodeID(hostID),
+	}).WithMetric(docker.MemoryUsage, report.MakeMetric().Add(now, 1024)))
+	rpt.ContainerImage.AddNode(report.MakeContainerNodeID(hostID, "i1"), report.MakeNodeWith(map[string]string{
+		docker.ImageID:   "i1",
+		docker.ImageName: "nginx:latest",
+	}))
+	rpt.Process.AddNode(report.MakeProcessNodeID(hostID, "42"), report.MakeNodeWith(map[string]string{
+		process.PID:        "42",
+		process.Comm:       "nginx",
+		docker.ContainerID: "c1",
+		report.HostNodeID:  report.MakeHostNodeID(hostID),
+	}).WithMetric(process.CPUUsage, report.MakeMetric().Add(now, 12.5)))
+
+	c := app.NewCollector(time.Minute)
+	c.Add(rpt)
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	body := string(is200(t, ts, "/metrics"))
+	for _, want := range []string{
+		`scope_container_memory_usage{container_id="c1",container_name="web",host="host1",image="nginx:latest",kubernetes_namespace="prod",kubernetes_pod_name="web-1"} 1024`,
+		`scope_process_cpu_usage_percent{comm="nginx",container_id="c1",container_name="web",host="host1",image="nginx:latest",kubernetes_namespace="prod",kubernetes_pod_name="web-1",pid="42"} 12.5`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want %q in:\n%s", want, body)
+		}
+	}
+}
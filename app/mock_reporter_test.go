@@ -1,6 +1,8 @@
 package app_test
 
 import (
+	"time"
+
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
@@ -12,3 +14,5 @@ func (s StaticReport) Report() report.Report { return fixture.Report }
 func (s StaticReport) Add(report.Report)     {}
 func (s StaticReport) WaitOn(chan struct{})  {}
 func (s StaticReport) UnWait(chan struct{})  {}
+
+func (s StaticReport) ReportAt(time.Time) (report.Report, error) { return fixture.Report, nil }
@@ -41,8 +41,9 @@ type pipe struct {
 	xfer.Pipe
 }
 
-// RegisterPipeRoutes registers the pipe routes
-func RegisterPipeRoutes(router *mux.Router) *PipeRouter {
+// RegisterPipeRoutes registers the pipe routes. Probes without an accepted
+// token cannot connect to their end of a pipe.
+func RegisterPipeRoutes(auth *ProbeAuth, router *mux.Router) *PipeRouter {
 	pipeRouter := &PipeRouter{
 		quit:  make(chan struct{}),
 		pipes: map[string]*pipe{},
@@ -57,10 +58,10 @@ func RegisterPipeRoutes(router *mux.Router) *PipeRouter {
 	}))
 	router.Methods("GET").
 		Path("/api/pipe/{pipeID}/probe").
-		HandlerFunc(pipeRouter.handleWs(func(p *pipe) (*end, io.ReadWriter) {
+		HandlerFunc(auth.Wrap(pipeRouter.handleWs(func(p *pipe) (*end, io.ReadWriter) {
 		_, probeEnd := p.Ends()
 		return &p.probe, probeEnd
-	}))
+	})))
 	router.Methods("DELETE", "POST").
 		Path("/api/pipe/{pipeID}").
 		HandlerFunc(pipeRouter.delete)
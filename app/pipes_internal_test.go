@@ -21,7 +21,7 @@ import (
 
 func TestPipeTimeout(t *testing.T) {
 	router := mux.NewRouter()
-	pr := RegisterPipeRoutes(router)
+	pr := RegisterPipeRoutes(NewProbeAuth(nil), router)
 	pr.Stop() // we don't want the loop running in the background
 
 	mtime.NowForce(time.Now())
@@ -64,7 +64,7 @@ func (a adapter) PipeClose(_, pipeID string) error {
 
 func TestPipeClose(t *testing.T) {
 	router := mux.NewRouter()
-	pr := RegisterPipeRoutes(router)
+	pr := RegisterPipeRoutes(NewProbeAuth(nil), router)
 	defer pr.Stop()
 
 	server := httptest.NewServer(router)
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"bufio"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/weaveworks/scope/xfer"
+)
+
+// ProbeAuth checks the tokens probes present when talking to the app, and
+// records which token each probe used.
+type ProbeAuth struct {
+	mtx    sync.Mutex
+	tokens map[string]string // token -> tenant
+	probes map[string]string // probe ID -> token
+}
+
+// NewProbeAuth makes a new ProbeAuth, accepting the given tokens. Each token
+// is of the form "token" or "token:tenant"; without an explicit tenant, the
+// token is its own tenant. If no tokens are given, any token is accepted.
+func NewProbeAuth(tokens []string) *ProbeAuth {
+	auth := &ProbeAuth{
+		tokens: map[string]string{},
+		probes: map[string]string{},
+	}
+	for _, token := range tokens {
+		tenant := token
+		if i := strings.Index(token, ":"); i >= 0 {
+			token, tenant = token[:i], token[i+1:]
+		}
+		auth.tokens[token] = tenant
+	}
+	return auth
+}
+
+// LoadProbeTokens reads a list of probe tokens from a file, one per line, in
+// the form accepted by NewProbeAuth. Blank lines and lines starting with #
+// are ignored.
+func LoadProbeTokens(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tokens := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	return tokens, scanner.Err()
+}
+
+// authorized decides whether to accept a request. Without any tokens
+// configured, we accept all requests, even those lacking a token.
+func (a *ProbeAuth) authorized(token string, present bool) bool {
+	if len(a.tokens) == 0 {
+		return true
+	}
+	if !present {
+		return false
+	}
+	_, ok := a.tokens[token]
+	return ok
+}
+
+func (a *ProbeAuth) record(probeID, token string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if previous, ok := a.probes[probeID]; ok && previous != token {
+		log.Printf("Probe %s changed its token", probeID)
+	}
+	a.probes[probeID] = token
+}
+
+// Tenant returns the tenant an accepted probe token belongs to.
+func (a *ProbeAuth) Tenant(token string) (string, bool) {
+	tenant, ok := a.tokens[token]
+	return tenant, ok
+}
+
+// AcceptsAnyToken is true if no tokens are configured, in which case all
+// requests are accepted.
+func (a *ProbeAuth) AcceptsAnyToken() bool {
+	return len(a.tokens) == 0
+}
+
+// Token returns the token last used by the given probe.
+func (a *ProbeAuth) Token(probeID string) (string, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	token, ok := a.probes[probeID]
+	return token, ok
+}
+
+// Wrap returns a handler which rejects requests without an accepted probe
+// token with a 401, and otherwise passes them on to h.
+func (a *ProbeAuth) Wrap(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := xfer.ProbeToken(r)
+		if !a.authorized(token, ok) {
+			log.Printf("Rejecting request from %s for %s: unknown probe token", r.RemoteAddr, r.URL.Path)
+			respondWith(w, http.StatusUnauthorized, "unknown probe token")
+			return
+		}
+		if probeID := r.Header.Get(xfer.ScopeProbeIDHeader); ok && probeID != "" {
+			a.record(probeID, token)
+		}
+		h(w, r)
+	}
+}
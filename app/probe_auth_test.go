@@ -0,0 +1,81 @@
+package app_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/xfer"
+)
+
+func TestProbeAuth(t *testing.T) {
+	auth := app.NewProbeAuth([]string{"good"})
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, auth, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(report.MakeReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(authorization string) int {
+		req, err := http.NewRequest("POST", ts.URL+"/api/report", bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		req.Header.Set(xfer.ScopeProbeIDHeader, "probe1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	equals(t, http.StatusUnauthorized, post(""))
+	equals(t, http.StatusUnauthorized, post("Scope-Probe token=bad"))
+	if _, ok := auth.Token("probe1"); ok {
+		t.Error("recorded token for rejected probe")
+	}
+
+	equals(t, http.StatusOK, post("Scope-Probe token=good"))
+	token, ok := auth.Token("probe1")
+	equals(t, true, ok)
+	equals(t, "good", token)
+}
+
+func TestLoadProbeTokens(t *testing.T) {
+	f, err := ioutil.TempFile("", "scope-tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("# comment\nfoo\n\n  bar  \n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tokens, err := app.LoadProbeTokens(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"foo", "bar"}; !reflect.DeepEqual(want, tokens) {
+		t.Errorf("want %v, have %v", want, tokens)
+	}
+}
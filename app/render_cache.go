@@ -0,0 +1,95 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/weaveworks/scope/render"
+)
+
+// VersionedReporter is a Reporter whose reports have a version, which changes
+// whenever the report does.
+type VersionedReporter interface {
+	Reporter
+	Version() uint64
+}
+
+// renderCache memoizes rendered topologies, such that all the clients looking
+// at the same topology, with the same options, share a single render of each
+// version of the report.
+type renderCache struct {
+	mtx     sync.Mutex
+	version uint64
+	entries map[string]*renderCacheEntry
+}
+
+type renderCacheEntry struct {
+	once  sync.Once
+	nodes render.RenderableNodes
+}
+
+func newRenderCache() *renderCache {
+	return &renderCache{
+		entries: map[string]*renderCacheEntry{},
+	}
+}
+
+// renderCacheFor returns the renderCache rep shares between everything which
+// renders its reports, if it has one, or else a new one.
+func renderCacheFor(rep Reporter) *renderCache {
+	if r, ok := rep.(interface {
+		sharedRenderCache() *renderCache
+	}); ok {
+		return r.sharedRenderCache()
+	}
+	return newRenderCache()
+}
+
+// render returns the nodes renderer produces for the current report of rep.
+// Renders are cached under key, until the version of the report changes.
+// Reporters without versions aren't cached. The result is shared, and must
+// not be modified.
+func (c *renderCache) render(rep Reporter, key string, renderer render.Renderer) render.RenderableNodes {
+	versioned, ok := rep.(VersionedReporter)
+	if !ok {
+		return renderer.Render(rep.Report())
+	}
+
+	version := versioned.Version()
+	c.mtx.Lock()
+	if version != c.version {
+		c.version = version
+		c.entries = map[string]*renderCacheEntry{}
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &renderCacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mtx.Unlock()
+
+	// Concurrent requests for the same entry wait for the first to render it.
+	// The report may be newer than version by now; that's fine, we'll just
+	// render it again for the next version.
+	entry.once.Do(func() {
+		entry.nodes = renderer.Render(rep.Report())
+	})
+	return entry.nodes
+}
+
+// renderCacheKey identifies a topology rendered with the options in a
+// request.
+func renderCacheKey(topology APITopologyDesc, req *http.Request) string {
+	return renderCacheKeyForOptions(topology, req.FormValue)
+}
+
+// renderCacheKeyForOptions identifies a topology rendered with the given
+// values of its options, as renderedForOptions renders it.
+func renderCacheKeyForOptions(topology APITopologyDesc, option func(param string) string) string {
+	values := url.Values{}
+	for param := range topology.Options {
+		values.Set(param, option(param))
+	}
+	return topology.id + "?" + values.Encode()
+}
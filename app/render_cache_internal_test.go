@@ -0,0 +1,59 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+type countingRenderer struct {
+	renders int
+}
+
+func (r *countingRenderer) Render(report.Report) render.RenderableNodes {
+	r.renders++
+	return render.RenderableNodes{}
+}
+
+func (r *countingRenderer) Stats(report.Report) render.Stats {
+	return render.Stats{}
+}
+
+type versionedReporter struct {
+	fixedReporter
+	version uint64
+}
+
+func (r *versionedReporter) Version() uint64 { return r.version }
+
+func TestRenderCache(t *testing.T) {
+	var (
+		cache    = newRenderCache()
+		renderer = &countingRenderer{}
+		rep      = &versionedReporter{fixedReporter{report.MakeReport()}, 1}
+	)
+
+	check := func(want int) {
+		if have := renderer.renders; want != have {
+			t.Fatalf("want %d renders, have %d", want, have)
+		}
+	}
+
+	cache.render(rep, "foo", renderer)
+	cache.render(rep, "foo", renderer)
+	check(1)
+
+	cache.render(rep, "bar", renderer)
+	check(2)
+
+	rep.version++
+	cache.render(rep, "foo", renderer)
+	cache.render(rep, "foo", renderer)
+	check(3)
+
+	// Reporters without versions aren't cached.
+	cache.render(fixedReporter{report.MakeReport()}, "foo", renderer)
+	cache.render(fixedReporter{report.MakeReport()}, "foo", renderer)
+	check(5)
+}
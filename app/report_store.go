@@ -0,0 +1,317 @@
+package app
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	reportFileSuffix = ".gob.gz"
+	tempFilePrefix   = ".report"       // reports being written
+	purgeInterval    = 1 * time.Minute // we purge expired reports at most once a minute
+	storeQueueLen    = 64              // reports waiting to be written, before Put blocks
+)
+
+// ReportStore is a pluggable storage backend for the collector. It keeps
+// reports around after they've aged out of the collector's window, such that
+// they can be used to answer queries about the past.
+type ReportStore interface {
+	Put(time.Time, report.Report) error
+	Get(from, through time.Time) ([]report.Report, error)
+	Stop()
+}
+
+// diskStore is a ReportStore which keeps gzipped, gob-encoded reports in a
+// local directory, one file per report. Filenames are the (zero-padded) unix
+// nanosecond timestamp the report was added at, and a sequence number, so
+// a directory listing is in chronological order.
+//
+// Reports are written in the background, so as not to hold up the probes
+// publishing them, and the store keeps an index of the files in memory, so
+// queries don't need to list the directory. Should the disk fall behind,
+// Put waits for the queue of reports to be written, rather than leave holes
+// in the history.
+type diskStore struct {
+	mtx       sync.Mutex
+	dir       string
+	retention time.Duration
+	lastPurge time.Time
+	seq       uint64
+	index     []*storeEntry // chronological
+	blocked   bool          // Put has had to wait since the queue was last free
+	queue     chan *storeEntry
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// storeEntry is a report in the index. Until it's been written, the entry
+// holds the report itself.
+type storeEntry struct {
+	timestamp time.Time
+	name      string
+	rpt       *report.Report
+}
+
+// NewDiskStore returns a ReportStore which persists reports in dir, and
+// deletes them once they are older than retention.
+func NewDiskStore(dir string, retention time.Duration) (ReportStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &diskStore{
+		dir:       dir,
+		retention: retention,
+		index:     []*storeEntry{},
+		queue:     make(chan *storeEntry, storeQueueLen),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), tempFilePrefix) {
+			// Left over from a report which was being written when we stopped.
+			if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+				log.Printf("Error removing partial report %s: %v", file.Name(), err)
+			}
+			continue
+		}
+		if t, ok := parseReportFilename(file.Name()); ok {
+			s.insert(&storeEntry{timestamp: t, name: file.Name()})
+		}
+	}
+	go s.loop()
+	return s, nil
+}
+
+func reportFilename(t time.Time, seq uint64) string {
+	return fmt.Sprintf("%019d-%d%s", t.UnixNano(), seq, reportFileSuffix)
+}
+
+func parseReportFilename(name string) (time.Time, bool) {
+	if !strings.HasSuffix(name, reportFileSuffix) {
+		return time.Time{}, false
+	}
+	name = strings.TrimSuffix(name, reportFileSuffix)
+	if i := strings.Index(name, "-"); i >= 0 {
+		name = name[:i] // the sequence number
+	}
+	ns, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// Put implements ReportStore. The report is queued to be written; if the
+// queue is full, Put waits for room in it.
+func (s *diskStore) Put(t time.Time, rpt report.Report) error {
+	s.mtx.Lock()
+	s.seq++
+	e := &storeEntry{timestamp: t, name: reportFilename(t, s.seq), rpt: &rpt}
+	s.insert(e)
+	s.mtx.Unlock()
+
+	select {
+	case s.queue <- e:
+		s.setBlocked(false)
+		return nil
+	default:
+	}
+	if !s.setBlocked(true) {
+		log.Printf("Reports are arriving faster than they can be stored in %s; holding them up", s.dir)
+	}
+	select {
+	case s.queue <- e:
+		return nil
+	case <-s.quit:
+		s.mtx.Lock()
+		s.remove(e)
+		s.mtx.Unlock()
+		return fmt.Errorf("report store stopped")
+	}
+}
+
+// setBlocked records whether Put has had to wait, and returns whether it
+// already had, so we only log once per burst of reports.
+func (s *diskStore) setBlocked(blocked bool) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	was := s.blocked
+	s.blocked = blocked
+	return was
+}
+
+// Stop implements ReportStore. It writes the reports still queued, and
+// stops the store.
+func (s *diskStore) Stop() {
+	close(s.quit)
+	<-s.done
+}
+
+func (s *diskStore) loop() {
+	defer close(s.done)
+	for {
+		select {
+		case e := <-s.queue:
+			s.store(e)
+		case <-s.quit:
+			for {
+				select {
+				case e := <-s.queue:
+					s.store(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// store writes the report of the entry, and purges the expired reports if
+// it's time to.
+func (s *diskStore) store(e *storeEntry) {
+	err := writeReport(s.dir, e.name, *e.rpt)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err != nil {
+		log.Printf("Error storing report %s: %v", e.name, err)
+		s.remove(e)
+	} else {
+		e.rpt = nil
+	}
+	if now().Sub(s.lastPurge) >= purgeInterval {
+		s.purge()
+	}
+}
+
+func writeReport(dir, name string, rpt report.Report) error {
+	// Write to a temporary file and rename, so readers never see a partial
+	// report.
+	f, err := ioutil.TempFile(dir, tempFilePrefix)
+	if err != nil {
+		return err
+	}
+	gzwriter := gzip.NewWriter(f)
+	if err := gob.NewEncoder(gzwriter).Encode(rpt); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := gzwriter.Close(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// insert adds the entry to the index, in chronological order. Must be called
+// with the lock held.
+func (s *diskStore) insert(e *storeEntry) {
+	i := sort.Search(len(s.index), func(i int) bool {
+		return s.index[i].timestamp.After(e.timestamp)
+	})
+	s.index = append(s.index, nil)
+	copy(s.index[i+1:], s.index[i:])
+	s.index[i] = e
+}
+
+// remove drops the entry from the index. Must be called with the lock held.
+func (s *diskStore) remove(e *storeEntry) {
+	for i, other := range s.index {
+		if other == e {
+			s.index = append(s.index[:i], s.index[i+1:]...)
+			return
+		}
+	}
+}
+
+// purge deletes all the reports older than the retention, which have been
+// written. Must be called with the lock held.
+func (s *diskStore) purge() {
+	s.lastPurge = now()
+	var (
+		oldest = now().Add(-s.retention)
+		kept   = make([]*storeEntry, 0, len(s.index))
+	)
+	for _, e := range s.index {
+		if !e.timestamp.Before(oldest) || e.rpt != nil {
+			kept = append(kept, e)
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error purging report %s: %v", e.name, err)
+		}
+	}
+	s.index = kept
+}
+
+// Get implements ReportStore. It returns all the reports added between from
+// and through (inclusive), oldest first.
+func (s *diskStore) Get(from, through time.Time) ([]report.Report, error) {
+	s.mtx.Lock()
+	i := sort.Search(len(s.index), func(i int) bool {
+		return !s.index[i].timestamp.Before(from)
+	})
+	entries := []storeEntry{}
+	for ; i < len(s.index) && !s.index[i].timestamp.After(through); i++ {
+		entries = append(entries, *s.index[i])
+	}
+	s.mtx.Unlock()
+
+	reports := []report.Report{}
+	for _, e := range entries {
+		if e.rpt != nil {
+			reports = append(reports, *e.rpt)
+			continue
+		}
+		rpt, err := readReport(filepath.Join(s.dir, e.name))
+		if os.IsNotExist(err) {
+			continue // purged since
+		} else if err != nil {
+			return nil, err
+		}
+		reports = append(reports, rpt)
+	}
+	return reports, nil
+}
+
+func readReport(path string) (report.Report, error) {
+	rpt := report.MakeReport()
+	f, err := os.Open(path)
+	if err != nil {
+		return rpt, err
+	}
+	defer f.Close()
+	gzreader, err := gzip.NewReader(f)
+	if err != nil {
+		return rpt, err
+	}
+	defer gzreader.Close()
+	err = gob.NewDecoder(gzreader).Decode(&rpt)
+	return rpt, err
+}
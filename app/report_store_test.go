@@ -0,0 +1,173 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestDiskStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := app.NewDiskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Stop()
+
+	var (
+		t1 = time.Now()
+		t2 = t1.Add(10 * time.Second)
+		r1 = report.MakeReport()
+		r2 = report.MakeReport()
+	)
+	r1.Endpoint.AddNode("foo", report.MakeNodeWith(map[string]string{"a": "1"}))
+	r2.Endpoint.AddNode("bar", report.MakeNodeWith(map[string]string{"b": "2"}))
+	ok(t, store.Put(t1, r1))
+	ok(t, store.Put(t2, r2))
+
+	for _, tc := range []struct {
+		from, through time.Time
+		want          []report.Report
+	}{
+		{t1, t2, []report.Report{r1, r2}},
+		{t1, t1, []report.Report{r1}},
+		{t1.Add(time.Second), t2, []report.Report{r2}},
+		{t2.Add(time.Second), t2.Add(time.Minute), []report.Report{}},
+	} {
+		have, err := store.Get(tc.from, tc.through)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tc.want) != len(have) {
+			t.Fatalf("want %d reports, have %d", len(tc.want), len(have))
+		}
+		for i := range tc.want {
+			if want := tc.want[i].Endpoint.Nodes; !reflect.DeepEqual(want, have[i].Endpoint.Nodes) {
+				t.Error(test.Diff(want, have[i].Endpoint.Nodes))
+			}
+		}
+	}
+}
+
+func TestDiskStoreReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := app.NewDiskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reports added at the same time are both kept
+	var (
+		now = time.Now()
+		r1  = report.MakeReport()
+		r2  = report.MakeReport()
+	)
+	r1.Endpoint.AddNode("foo", report.MakeNode())
+	r2.Endpoint.AddNode("bar", report.MakeNode())
+	ok(t, store.Put(now, r1))
+	ok(t, store.Put(now, r2))
+	store.Stop()
+
+	// As if we'd stopped while writing a report.
+	partial := filepath.Join(dir, ".report123")
+	ok(t, ioutil.WriteFile(partial, []byte("gob"), 0644))
+
+	store, err = app.NewDiskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Stop()
+	have, err := store.Get(now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := map[string]struct{}{}
+	for _, rpt := range have {
+		for id := range rpt.Endpoint.Nodes {
+			nodes[id] = struct{}{}
+		}
+	}
+	if want := map[string]struct{}{"foo": {}, "bar": {}}; !reflect.DeepEqual(want, nodes) {
+		t.Error(test.Diff(want, nodes))
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("want the partial report removed, have %v", err)
+	}
+}
+
+func TestDiskStoreBackpressure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := app.NewDiskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Stop()
+
+	// Many more reports than can wait to be written are all kept.
+	start := time.Now()
+	for i := 0; i < 500; i++ {
+		ok(t, store.Put(start.Add(time.Duration(i)), report.MakeReport()))
+	}
+	have, err := store.Get(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 500 {
+		t.Errorf("want 500 reports, have %d", len(have))
+	}
+}
+
+func TestCollectorWithStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := app.NewDiskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Stop()
+	c := app.NewCollectorWithStore(50*time.Millisecond, store, nil)
+
+	r1 := report.MakeReport()
+	r1.Endpoint.AddNode("foo", report.MakeNode())
+	c.Add(r1)
+	added := time.Now()
+	time.Sleep(100 * time.Millisecond)
+
+	// The report has aged out of the window, but is still in the store.
+	if have := c.Report().Endpoint.Nodes; len(have) != 0 {
+		t.Errorf("want no nodes, have %v", have)
+	}
+	have, err := c.ReportAt(added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Endpoint.Nodes["foo"]; !ok {
+		t.Errorf("want node foo, have %v", have.Endpoint.Nodes)
+	}
+}
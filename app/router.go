@@ -4,6 +4,9 @@ import (
 	"compress/gzip"
 	"encoding/gob"
 	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -59,24 +62,37 @@ func gzipHandler(h http.HandlerFunc) http.HandlerFunc {
 	return handlers.GZIPHandlerFunc(h, nil)
 }
 
-// RegisterTopologyRoutes registers the various topology routes with a http mux.
+// RegisterTopologyRoutes registers the various topology routes with a http
+// mux. Rendered topologies are cached until the report from c changes, if c
+// is a VersionedReporter.
 func RegisterTopologyRoutes(c Reporter, router *mux.Router) {
+	cache := renderCacheFor(c)
 	get := router.Methods("GET").Subrouter()
 	get.HandleFunc("/api", gzipHandler(apiHandler))
-	get.HandleFunc("/api/topology", gzipHandler(topologyRegistry.makeTopologyList(c)))
+	get.HandleFunc("/api/topology", gzipHandler(topologyRegistry.makeTopologyList(c, cache)))
 	get.HandleFunc("/api/topology/{topology}",
-		gzipHandler(topologyRegistry.captureRenderer(c, handleTopology)))
+		gzipHandler(topologyRegistry.captureRenderer(c, cache, handleTopology)))
 	get.HandleFunc("/api/topology/{topology}/ws",
-		topologyRegistry.captureRenderer(c, handleWs)) // NB not gzip!
+		topologyRegistry.captureRenderer(c, cache, handleWs)) // NB not gzip!
 	get.MatcherFunc(URLMatcher("/api/topology/{topology}/{id}")).HandlerFunc(
-		gzipHandler(topologyRegistry.captureRendererWithoutFilters(c, handleNode)))
+		gzipHandler(topologyRegistry.captureRendererWithoutFilters(c, cache, handleNode)))
+	get.MatcherFunc(URLMatcher("/api/topology/{topology}/{src}/{dst}")).HandlerFunc(
+		gzipHandler(topologyRegistry.captureRendererWithoutFilters(c, cache, handleEdge)))
+	get.MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/metrics/{metric}")).HandlerFunc(
+		gzipHandler(makeNodeMetricHandler(c)))
 	get.HandleFunc("/api/report", gzipHandler(makeRawReportHandler(c)))
+	get.HandleFunc("/metrics", makeMetricsHandler(c))
 }
 
-// RegisterReportPostHandler registers the handler for report submission
-func RegisterReportPostHandler(a Adder, router *mux.Router) {
+// RegisterReportPostHandler registers the handler for report submission.
+// Reports from probes without an accepted token are rejected. Probes may
+// publish deltas, which are applied to the last report from the same probe;
+// if we don't have that report, we reply 409 Conflict, and the probe should
+// publish a full report.
+func RegisterReportPostHandler(a Adder, auth *ProbeAuth, router *mux.Router) {
+	deltas := newDeltaTracker()
 	post := router.Methods("POST").Subrouter()
-	post.HandleFunc("/api/report", func(w http.ResponseWriter, r *http.Request) {
+	post.HandleFunc("/api/report", auth.Wrap(func(w http.ResponseWriter, r *http.Request) {
 		var (
 			rpt    report.Report
 			reader = r.Body
@@ -90,20 +106,78 @@ func RegisterReportPostHandler(a Adder, router *mux.Router) {
 			}
 		}
 
-		decoder := gob.NewDecoder(reader).Decode
-		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
-			decoder = json.NewDecoder(reader).Decode
+		// Older probes don't set a Content-Type, and send gob.
+		mediaType, params := "", map[string]string{}
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			if mediaType, params, err = mime.ParseMediaType(contentType); err != nil {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+				return
+			}
 		}
-		if err := decoder(&rpt); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+
+		if mediaType == report.ProtoContentType && params["proto"] == report.DeltaProtoName {
+			var delta report.Delta
+			if err := decodeProto(reader, &delta); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rpt, err = deltas.apply(r.Header.Get(xfer.ScopeProbeIDHeader), delta)
+			if err == errMissingBase {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			decoder, ok := reportDecoder(mediaType)
+			if !ok {
+				http.Error(w, "unsupported report Content-Type", http.StatusUnsupportedMediaType)
+				return
+			}
+			if err := decoder(reader, &rpt); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 		}
 		a.Add(rpt)
 		if len(rpt.Pod.Nodes) > 0 {
 			topologyRegistry.enableKubernetesTopologies()
 		}
 		w.WriteHeader(http.StatusOK)
-	})
+	}))
+}
+
+// reportDecoder returns the decoder for reports sent with the given media
+// type.
+func reportDecoder(mediaType string) (func(io.Reader, *report.Report) error, bool) {
+	switch mediaType {
+	case "", "application/octet-stream", "application/x-gob":
+		return func(r io.Reader, rpt *report.Report) error {
+			return gob.NewDecoder(r).Decode(rpt)
+		}, true
+	case "application/json":
+		return func(r io.Reader, rpt *report.Report) error {
+			return json.NewDecoder(r).Decode(rpt)
+		}, true
+	case report.ProtoContentType:
+		return func(r io.Reader, rpt *report.Report) error {
+			return decodeProto(r, rpt)
+		}, true
+	}
+	return nil, false
+}
+
+type protoUnmarshaler interface {
+	UnmarshalProto([]byte) error
+}
+
+func decodeProto(r io.Reader, v protoUnmarshaler) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return v.UnmarshalProto(buf)
 }
 
 func apiHandler(w http.ResponseWriter, r *http.Request) {
@@ -111,5 +185,11 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		ID:       UniqueID,
 		Version:  Version,
 		Hostname: hostname.Get(),
+		ReportContentTypes: []string{
+			report.DeltaContentType,
+			report.ProtoContentType,
+			"application/json",
+			"application/x-gob",
+		},
 	})
 }
@@ -14,8 +14,10 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 	"github.com/weaveworks/scope/test/fixture"
+	"github.com/weaveworks/scope/xfer"
 )
 
 type v map[string]string
@@ -43,7 +45,7 @@ func TestReportPostHandler(t *testing.T) {
 	test := func(contentType string, encoder func(interface{}) ([]byte, error)) {
 		router := mux.NewRouter()
 		c := app.NewCollector(1 * time.Minute)
-		app.RegisterReportPostHandler(c, router)
+		app.RegisterReportPostHandler(c, app.NewProbeAuth(nil), router)
 		ts := httptest.NewServer(router)
 		defer ts.Close()
 
@@ -84,4 +86,80 @@ func TestReportPostHandler(t *testing.T) {
 		return buf.Bytes(), err
 	})
 	test("application/json", json.Marshal)
+	test("application/x-protobuf", func(v interface{}) ([]byte, error) {
+		return v.(report.Report).MarshalProto()
+	})
+}
+
+func TestReportPostHandlerUnsupportedContentType(t *testing.T) {
+	router := mux.NewRouter()
+	app.RegisterReportPostHandler(app.NewCollector(1*time.Minute), app.NewProbeAuth(nil), router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/api/report", "text/plain", bytes.NewReader([]byte("foo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusUnsupportedMediaType, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestReportPostHandlerDeltas(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, app.NewProbeAuth(nil), router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	post := func(d report.Delta) int {
+		b, err := d.MarshalProto()
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", ts.URL+"/api/report", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", report.DeltaContentType)
+		req.Header.Set(xfer.ScopeProbeIDHeader, "probe1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A delta before any full report leaves a gap.
+	first := report.MakeReport()
+	first.Endpoint.AddNode("a", report.MakeNode())
+	if want, have := http.StatusConflict, post(report.MakeReport().Diff(first)); want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+
+	if want, have := http.StatusOK, post(report.MakeFullDelta(1, first)); want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+
+	second := report.MakeReport()
+	second.Endpoint.AddNode("b", report.MakeNode())
+	delta := first.Diff(second)
+	delta.Seq, delta.BaseSeq = 2, 1
+	if want, have := http.StatusOK, post(delta); want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+
+	// Skipping a sequence number leaves a gap too.
+	delta.Seq, delta.BaseSeq = 4, 3
+	if want, have := http.StatusConflict, post(delta); want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+
+	// The collector has both reports in its window.
+	if want, have := 2, len(c.Report().Endpoint.Nodes); want != have {
+		t.Errorf("want %d nodes, have %d", want, have)
+	}
 }
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/weaveworks/scope/xfer"
+)
+
+// TenantTokenHeader is the header used by the UI and API clients to pick a
+// tenant, by presenting one of the tenant's probe tokens. The tenant_token
+// query parameter can be used instead, e.g. where headers can't be set on
+// websockets.
+const TenantTokenHeader = "X-Scope-Tenant-Token"
+
+// MultiTenantRouter dispatches requests to a separate handler per tenant, each
+// with its own collector, control router and pipe router. Probes are
+// assigned to a tenant by their token; everyone else picks a tenant with one
+// of its tokens in the X-Scope-Tenant-Token header or the tenant_token query
+// parameter. Only the tenants of the configured tokens are ever created.
+type MultiTenantRouter struct {
+	mtx      sync.Mutex
+	auth     *ProbeAuth
+	factory  func(tenant string) http.Handler
+	handlers map[string]http.Handler
+}
+
+// NewMultiTenantRouter makes a new MultiTenantRouter. The factory is called
+// to make the handler for each tenant, the first time it is seen. As tenants
+// are chosen by token, there must be tokens configured.
+func NewMultiTenantRouter(auth *ProbeAuth, factory func(tenant string) http.Handler) (*MultiTenantRouter, error) {
+	if auth.AcceptsAnyToken() {
+		return nil, fmt.Errorf("multitenant mode needs probe tokens")
+	}
+	return &MultiTenantRouter{
+		auth:     auth,
+		factory:  factory,
+		handlers: map[string]http.Handler{},
+	}, nil
+}
+
+// tenant returns the tenant a request is for. Requests with an unknown
+// token, and requests without one, go to the default (empty) tenant; no
+// probe ever reports there, as it rejects them.
+func (m *MultiTenantRouter) tenant(r *http.Request) string {
+	token, ok := xfer.ProbeToken(r)
+	if !ok {
+		token = r.Header.Get(TenantTokenHeader)
+	}
+	if token == "" {
+		token = r.URL.Query().Get("tenant_token")
+	}
+	tenant, ok := m.auth.Tenant(token)
+	if !ok {
+		return ""
+	}
+	return tenant
+}
+
+func (m *MultiTenantRouter) get(tenant string) http.Handler {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	handler, ok := m.handlers[tenant]
+	if !ok {
+		log.Printf("Creating tenant %q", tenant)
+		handler = m.factory(tenant)
+		m.handlers[tenant] = handler
+	}
+	return handler
+}
+
+// ServeHTTP implements http.Handler.
+func (m *MultiTenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.get(m.tenant(r)).ServeHTTP(w, r)
+}
@@ -0,0 +1,96 @@
+package app_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestMultiTenantRouter(t *testing.T) {
+	auth := app.NewProbeAuth([]string{"token1:team1", "token2:team2"})
+	created := map[string]int{}
+	tenants, err := app.NewMultiTenantRouter(auth, func(tenant string) http.Handler {
+		created[tenant]++
+		router := mux.NewRouter()
+		c := app.NewCollector(1 * time.Minute)
+		app.RegisterTopologyRoutes(c, router)
+		app.RegisterReportPostHandler(c, auth, router)
+		return router
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(tenants)
+	defer ts.Close()
+
+	post := func(token, nodeID string) {
+		rpt := report.MakeReport()
+		rpt.Endpoint.AddNode(nodeID, report.MakeNode())
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(rpt); err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", ts.URL+"/api/report", buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Scope-Probe token="+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		equals(t, http.StatusOK, resp.StatusCode)
+	}
+	post("token1", "a;1.2.3.4;80")
+	post("token2", "b;5.6.7.8;80")
+
+	get := func(path string) report.Report {
+		var rpt report.Report
+		if err := json.Unmarshal(getRawJSON(t, ts, path), &rpt); err != nil {
+			t.Fatal(err)
+		}
+		return rpt
+	}
+	for path, want := range map[string]string{
+		"/api/report?tenant_token=token1": "a;1.2.3.4;80",
+		"/api/report?tenant_token=token2": "b;5.6.7.8;80",
+	} {
+		have := get(path).Endpoint.Nodes
+		if _, ok := have[want]; !ok || len(have) != 1 {
+			t.Errorf("%s: want only %s, have %v", path, want, have)
+		}
+	}
+	for _, path := range []string{
+		"/api/report",
+		"/api/report?tenant=team1", // needs a token of the tenant
+		"/api/report?tenant_token=team1",
+		"/api/report?tenant_token=nope",
+	} {
+		if have := get(path).Endpoint.Nodes; len(have) != 0 {
+			t.Errorf("%s: want no nodes, have %v", path, have)
+		}
+	}
+
+	// Unknown tokens don't make tenants
+	want := map[string]int{"": 1, "team1": 1, "team2": 1}
+	if !reflect.DeepEqual(want, created) {
+		t.Errorf("want tenants %v, have %v", want, created)
+	}
+}
+
+func TestMultiTenantRouterNeedsTokens(t *testing.T) {
+	if _, err := app.NewMultiTenantRouter(app.NewProbeAuth(nil), nil); err == nil {
+		t.Error("want an error without tokens")
+	}
+}
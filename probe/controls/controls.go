@@ -3,6 +3,7 @@ package controls
 import (
 	"sync"
 
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/xfer"
 )
 
@@ -30,6 +31,20 @@ func Register(control string, f xfer.ControlHandlerFunc) {
 	handlers[control] = f
 }
 
+// RegisterWithParams registers a handler for a control which takes
+// arguments. Requests with arguments which aren't valid for the params of
+// the control are rejected, so the handler needn't check them, and the
+// defaults of the params which weren't passed are filled in.
+func RegisterWithParams(control report.Control, f xfer.ControlHandlerFunc) {
+	Register(control.ID, func(req xfer.Request) xfer.Response {
+		if err := control.ValidateArgs(req.Args); err != nil {
+			return xfer.ResponseError(err)
+		}
+		req.Args = control.WithDefaults(req.Args)
+		return f(req)
+	})
+}
+
 // Rm deletes the handler for a given name
 func Rm(control string) {
 	mtx.Lock()
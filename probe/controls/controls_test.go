@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 	"github.com/weaveworks/scope/xfer"
 )
@@ -39,3 +40,37 @@ func TestControlsNotFound(t *testing.T) {
 		t.Fatal(test.Diff(want, have))
 	}
 }
+
+func TestControlsWithParams(t *testing.T) {
+	controls.RegisterWithParams(report.Control{
+		ID: "foo",
+		Params: []report.ControlParam{
+			{Name: "n", Type: report.IntParam, Required: true},
+			{Name: "unit", Type: report.EnumParam, Default: "s", Options: []string{"s", "ms"}},
+		},
+	}, func(req xfer.Request) xfer.Response {
+		return xfer.Response{
+			Value: req.Args["n"] + req.Args["unit"],
+		}
+	})
+	defer controls.Rm("foo")
+
+	for _, tc := range []struct {
+		args map[string]string
+		want xfer.Response
+	}{
+		{map[string]string{"n": "1"}, xfer.Response{Value: "1s"}},
+		{map[string]string{"n": "1", "unit": "ms"}, xfer.Response{Value: "1ms"}},
+		{map[string]string{"n": "1", "unit": "h"}, xfer.Response{Error: `foo: argument "unit": "h" is not one of s, ms`}},
+		{map[string]string{"n": "one"}, xfer.Response{Error: `foo: argument "n": "one" is not of type int`}},
+		{nil, xfer.Response{Error: `foo: missing argument "n"`}},
+	} {
+		have := controls.HandleControlRequest(xfer.Request{
+			Control: "foo",
+			Args:    tc.args,
+		})
+		if !reflect.DeepEqual(tc.want, have) {
+			t.Error(test.Diff(tc.want, have))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	docker_client "github.com/fsouza/go-dockerclient"
+	"github.com/fsouza/go-dockerclient/external/github.com/docker/docker/pkg/stdcopy"
+)
+
+// dockerClient adds what go-dockerclient lacks to its Client.
+type dockerClient struct {
+	*docker_client.Client
+}
+
+// LogsNonBlocking streams the logs of a container like Logs, but returns
+// once they start to stream. Unlike Logs with Follow, the stream can be
+// stopped, by closing the returned CloseWaiter.
+func (c dockerClient) LogsNonBlocking(opts docker_client.LogsOptions) (docker_client.CloseWaiter, error) {
+	if opts.Container == "" {
+		return nil, &docker_client.NoSuchContainer{ID: opts.Container}
+	}
+	endpoint, err := url.Parse(c.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, base := c.HTTPClient, url.URL{Scheme: "http", Host: endpoint.Host}
+	switch {
+	case endpoint.Scheme == "unix":
+		socket := endpoint.Path
+		httpClient = &http.Client{Transport: &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return c.Dialer.Dial("unix", socket)
+			},
+		}}
+		base.Host = "unix.sock" // Unused, but net/http needs one.
+	case c.TLSConfig != nil:
+		base.Scheme = "https"
+	}
+
+	query := url.Values{}
+	query.Set("follow", strconv.FormatBool(opts.Follow))
+	query.Set("stdout", strconv.FormatBool(opts.Stdout))
+	query.Set("stderr", strconv.FormatBool(opts.Stderr))
+	query.Set("timestamps", strconv.FormatBool(opts.Timestamps))
+	query.Set("tail", "all")
+	if opts.Tail != "" {
+		query.Set("tail", opts.Tail)
+	}
+	if opts.Since != 0 {
+		query.Set("since", strconv.FormatInt(opts.Since, 10))
+	}
+	base.Path = "/containers/" + opts.Container + "/logs"
+	base.RawQuery = query.Encode()
+
+	resp, err := httpClient.Get(base.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, &docker_client.NoSuchContainer{ID: opts.Container}
+		}
+		return nil, fmt.Errorf("logs of container %s: %s", opts.Container, resp.Status)
+	}
+
+	s := &logStream{body: resp.Body, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		defer resp.Body.Close()
+		if opts.RawTerminal {
+			_, s.err = io.Copy(opts.OutputStream, resp.Body)
+		} else {
+			_, s.err = stdcopy.StdCopy(opts.OutputStream, opts.ErrorStream, resp.Body)
+		}
+		if s.isStopped() {
+			s.err = nil // Reading a closed body fails; that's expected.
+		}
+	}()
+	return s, nil
+}
+
+// logStream is the CloseWaiter of a stream of logs.
+type logStream struct {
+	sync.Mutex
+	body    io.Closer
+	done    chan struct{}
+	err     error
+	stopped bool
+}
+
+func (s *logStream) isStopped() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.stopped
+}
+
+// Close stops the stream, by closing the connection it reads from.
+func (s *logStream) Close() error {
+	s.Lock()
+	s.stopped = true
+	s.Unlock()
+	return s.body.Close()
+}
+
+// Wait waits for the stream to end, and returns why it did.
+func (s *logStream) Wait() error {
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,81 @@
+package docker_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	client "github.com/fsouza/go-dockerclient"
+
+	"github.com/weaveworks/scope/probe/docker"
+)
+
+func TestLogsNonBlockingClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// A docker which follows the logs forever, like it does for a running
+	// container.
+	query, stop := make(chan string, 1), make(chan struct{})
+	defer close(stop)
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query <- r.URL.Path + "?" + r.URL.RawQuery
+		w.Write([]byte("hello\n"))
+		w.(http.Flusher).Flush()
+		<-stop
+	}))
+
+	c, err := docker.NewDockerClientStub("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w := net.Pipe()
+	defer r.Close()
+	cw, err := c.LogsNonBlocking(client.LogsOptions{
+		Container:    "ping",
+		Follow:       true,
+		Stdout:       true,
+		Tail:         "10",
+		RawTerminal:  true,
+		OutputStream: w,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/containers/ping/logs?follow=true&stderr=false&stdout=true&tail=10&timestamps=false"
+	if have := <-query; have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	have := make([]byte, 6)
+	if _, err := r.Read(have); err != nil || !bytes.Equal(have, []byte("hello\n")) {
+		t.Fatalf("want %q, have %q (%v)", "hello\n", have, err)
+	}
+
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- cw.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("want no error, have %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closing the logs didn't stop them")
+	}
+}
@@ -46,6 +46,19 @@ const (
 	MemoryFailcnt  = "memory_failcnt"
 	MemoryLimit    = "memory_limit"
 
+	// Rates of the network counters are per interface; see NetworkMetricKey.
+	NetworkRxBytesRate   = "network_rx_bytes_rate"
+	NetworkTxBytesRate   = "network_tx_bytes_rate"
+	NetworkRxPacketsRate = "network_rx_packets_rate"
+	NetworkTxPacketsRate = "network_tx_packets_rate"
+	NetworkRxErrorsRate  = "network_rx_errors_rate"
+	NetworkTxErrorsRate  = "network_tx_errors_rate"
+
+	BlkioReadBytesRate  = "blkio_read_bytes_rate"
+	BlkioWriteBytesRate = "blkio_write_bytes_rate"
+	BlkioReadIOPS       = "blkio_read_iops"
+	BlkioWriteIOPS      = "blkio_write_iops"
+
 	CPUPercpuUsage       = "cpu_per_cpu_usage"
 	CPUUsageInUsermode   = "cpu_usage_in_usermode"
 	CPUTotalUsage        = "cpu_total_usage"
@@ -55,10 +68,87 @@ const (
 	StateRunning = "running"
 	StateStopped = "stopped"
 	StatePaused  = "paused"
+	StateDeleted = "deleted"
 
 	stopTimeout = 10
+
+	// networkMetricDelim separates the metric from the interface in the keys
+	// of per interface metrics. Interface names can't contain it.
+	networkMetricDelim = "/"
+
+	// defaultInterface is the interface Docker versions before 1.9 report the
+	// network stats of, as they don't report them per interface.
+	defaultInterface = "eth0"
 )
 
+// NetworkMetrics are the per interface metrics of containers.
+var NetworkMetrics = []string{
+	NetworkRxBytesRate, NetworkTxBytesRate,
+	NetworkRxPacketsRate, NetworkTxPacketsRate,
+	NetworkRxErrorsRate, NetworkTxErrorsRate,
+}
+
+// NetworkMetricKey is the key of the metric for the interface, e.g.
+// "network_rx_bytes_rate/eth0".
+func NetworkMetricKey(metric, iface string) string {
+	return metric + networkMetricDelim + iface
+}
+
+// ParseNetworkMetricKey splits the key of a per interface metric into the
+// metric and the interface.
+func ParseNetworkMetricKey(key string) (metric, iface string, ok bool) {
+	fields := strings.SplitN(key, networkMetricDelim, 2)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// stats are the stats of a container, as streamed by Docker. The client
+// predates the per interface network stats of Docker 1.9, so we decode
+// those ourselves.
+type stats struct {
+	docker.Stats
+	Networks map[string]networkStats `json:"networks,omitempty"`
+}
+
+type networkStats struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+}
+
+// interfaces are the network stats of the container, by interface.
+func (s *stats) interfaces() map[string]networkStats {
+	if len(s.Networks) > 0 {
+		return s.Networks
+	}
+	return map[string]networkStats{defaultInterface: {
+		RxBytes:   s.Network.RxBytes,
+		RxPackets: s.Network.RxPackets,
+		RxErrors:  s.Network.RxErrors,
+		TxBytes:   s.Network.TxBytes,
+		TxPackets: s.Network.TxPackets,
+		TxErrors:  s.Network.TxErrors,
+	}}
+}
+
+// blkioTotals sums the reads and writes of the entries over all devices.
+func blkioTotals(entries []docker.BlkioStatsEntry) (read, write uint64) {
+	for _, e := range entries {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			read += e.Value
+		case "write":
+			write += e.Value
+		}
+	}
+	return read, write
+}
+
 // Exported for testing
 var (
 	DialStub          = net.Dial
@@ -95,8 +185,8 @@ type container struct {
 	sync.RWMutex
 	container    *docker.Container
 	statsConn    ClientConn
-	latestStats  *docker.Stats
-	pendingStats []*docker.Stats
+	latestStats  *stats
+	pendingStats []*stats
 }
 
 // NewContainer creates a new Container
@@ -199,21 +289,21 @@ func (c *container) StartGatheringStats() error {
 			c.latestStats = nil
 		}()
 
-		stats := &docker.Stats{}
+		s := &stats{}
 		decoder := json.NewDecoder(resp.Body)
 
-		for err := decoder.Decode(&stats); err != io.EOF; err = decoder.Decode(&stats) {
+		for err := decoder.Decode(&s); err != io.EOF; err = decoder.Decode(&s) {
 			if err != nil {
 				log.Printf("docker container: error reading event, did container stop? %v", err)
 				return
 			}
 
 			c.Lock()
-			c.latestStats = stats
-			c.pendingStats = append(c.pendingStats, stats)
+			c.latestStats = s
+			c.pendingStats = append(c.pendingStats, s)
 			c.Unlock()
 
-			stats = &docker.Stats{}
+			s = &stats{}
 		}
 	}()
 
@@ -259,10 +349,16 @@ func (c *container) ports(localAddrs []net.IP) report.StringSet {
 	return report.MakeStringSet(ports...)
 }
 
+// sampleTime is when a sample was read, to the second, so the samples of
+// different containers line up when their metrics are summed.
+func sampleTime(s *stats) time.Time {
+	return s.Read.Truncate(time.Second)
+}
+
 func (c *container) memoryUsageMetric() report.Metric {
 	result := report.MakeMetric()
 	for _, s := range c.pendingStats {
-		result = result.Add(s.Read, float64(s.MemoryStats.Usage))
+		result = result.Add(sampleTime(s), float64(s.MemoryStats.Usage))
 	}
 	return result
 }
@@ -282,7 +378,7 @@ func (c *container) cpuPercentMetric() report.Metric {
 		if systemDelta > 0.0 && cpuDelta > 0.0 {
 			cpuPercent = (cpuDelta / systemDelta) * float64(len(s.CPUStats.CPUUsage.PercpuUsage)) * 100.0
 		}
-		result = result.Add(s.Read, cpuPercent)
+		result = result.Add(sampleTime(s), cpuPercent)
 		available := float64(len(s.CPUStats.CPUUsage.PercpuUsage)) * 100.0
 		if available >= result.Max {
 			result.Max = available
@@ -292,11 +388,78 @@ func (c *container) cpuPercentMetric() report.Metric {
 	return result
 }
 
+// counterRate is the rate of change of a counter between two samples. There
+// isn't one if the counter was reset, e.g. when an interface was recreated.
+func counterRate(previous, current uint64, from, to time.Time) (float64, bool) {
+	if current < previous || !to.After(from) {
+		return 0, false
+	}
+	return float64(current-previous) / to.Sub(from).Seconds(), true
+}
+
+// rateMetrics are the rates of the block I/O counters, and of the network
+// counters of each interface.
+func (c *container) rateMetrics() report.Metrics {
+	result := report.Metrics{
+		BlkioReadBytesRate:  report.MakeMetric(),
+		BlkioWriteBytesRate: report.MakeMetric(),
+		BlkioReadIOPS:       report.MakeMetric(),
+		BlkioWriteIOPS:      report.MakeMetric(),
+	}
+	if len(c.pendingStats) == 0 {
+		return result
+	}
+	for iface := range c.pendingStats[len(c.pendingStats)-1].interfaces() {
+		for _, metric := range NetworkMetrics {
+			result[NetworkMetricKey(metric, iface)] = report.MakeMetric()
+		}
+	}
+
+	previous := c.pendingStats[0]
+	for _, s := range c.pendingStats[1:] {
+		t := sampleTime(s)
+		add := func(key string, previousValue, currentValue uint64) {
+			if rate, ok := counterRate(previousValue, currentValue, previous.Read, s.Read); ok {
+				result[key] = result[key].Add(t, rate)
+			}
+		}
+
+		previousRead, previousWrite := blkioTotals(previous.BlkioStats.IOServiceBytesRecursive)
+		read, write := blkioTotals(s.BlkioStats.IOServiceBytesRecursive)
+		add(BlkioReadBytesRate, previousRead, read)
+		add(BlkioWriteBytesRate, previousWrite, write)
+
+		previousRead, previousWrite = blkioTotals(previous.BlkioStats.IOServicedRecursive)
+		read, write = blkioTotals(s.BlkioStats.IOServicedRecursive)
+		add(BlkioReadIOPS, previousRead, read)
+		add(BlkioWriteIOPS, previousWrite, write)
+
+		previousInterfaces := previous.interfaces()
+		for iface, n := range s.interfaces() {
+			p, ok := previousInterfaces[iface]
+			if !ok {
+				continue
+			}
+			add(NetworkMetricKey(NetworkRxBytesRate, iface), p.RxBytes, n.RxBytes)
+			add(NetworkMetricKey(NetworkTxBytesRate, iface), p.TxBytes, n.TxBytes)
+			add(NetworkMetricKey(NetworkRxPacketsRate, iface), p.RxPackets, n.RxPackets)
+			add(NetworkMetricKey(NetworkTxPacketsRate, iface), p.TxPackets, n.TxPackets)
+			add(NetworkMetricKey(NetworkRxErrorsRate, iface), p.RxErrors, n.RxErrors)
+			add(NetworkMetricKey(NetworkTxErrorsRate, iface), p.TxErrors, n.TxErrors)
+		}
+		previous = s
+	}
+	return result
+}
+
 func (c *container) metrics() report.Metrics {
 	result := report.Metrics{
 		MemoryUsage:   c.memoryUsageMetric(),
 		CPUTotalUsage: c.cpuPercentMetric(),
 	}
+	for key, metric := range c.rateMetrics() {
+		result[key] = metric
+	}
 
 	// Keep the latest report to help with relative metric reporting.
 	if len(c.pendingStats) > 0 {
@@ -309,7 +472,17 @@ func (c *container) GetNode(hostID string, localAddrs []net.IP) report.Node {
 	c.RLock()
 	defer c.RUnlock()
 
-	ips := append(c.container.NetworkSettings.SecondaryIPAddresses, c.container.NetworkSettings.IPAddress)
+	var (
+		settings = c.container.NetworkSettings
+		ips      = []string{}
+	)
+	// Dual-stack containers have IPv6 addresses too.
+	for _, ip := range append(append([]string{settings.IPAddress, settings.GlobalIPv6Address},
+		settings.SecondaryIPAddresses...), settings.SecondaryIPv6Addresses...) {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
 	// Treat all Docker IPs as local scoped.
 	ipsWithScopes := []string{}
 	for _, ip := range ips {
@@ -337,11 +510,12 @@ func (c *container) GetNode(hostID string, localAddrs []net.IP) report.Node {
 		result = result.WithControls(UnpauseContainer)
 	} else if c.container.State.Running {
 		result = result.WithControls(
-			RestartContainer, StopContainer, PauseContainer, AttachContainer, ExecContainer,
+			RestartContainer, StopContainer, PauseContainer, AttachContainer, ExecContainer, KillContainer,
 		)
 	} else {
-		result = result.WithControls(StartContainer)
+		result = result.WithControls(StartContainer, RemoveContainer)
 	}
+	result = result.WithControls(LogsContainer) // whatever the state
 
 	AddLabels(result, c.container.Config.Labels)
 
@@ -383,3 +557,33 @@ func ExtractContainerIPs(nmd report.Node) []string {
 func ExtractContainerIPsWithScopes(nmd report.Node) []string {
 	return []string(nmd.Sets[ContainerIPsWithScopes])
 }
+
+// liveContainer lets deletedContainer embed a Container, whose Container
+// method would otherwise clash with the name of the field.
+type liveContainer interface {
+	Container
+}
+
+// deletedContainer is a container which has been destroyed, as the watchers
+// see it one last time.
+type deletedContainer struct {
+	liveContainer
+}
+
+func (c deletedContainer) State() string {
+	return StateDeleted
+}
+
+// GetNode is the node of the container, in StateDeleted and without any
+// controls. As both are newer than those of the container's previous nodes,
+// they win when merged with them.
+func (c deletedContainer) GetNode(hostID string, localAddrs []net.IP) report.Node {
+	result := c.liveContainer.GetNode(hostID, localAddrs).WithLatest(
+		ContainerState, mtime.Now(), StateDeleted,
+	)
+	result.Controls = report.NodeControls{
+		Timestamp: mtime.Now(),
+		Controls:  report.MakeStringSet(),
+	}
+	return result
+}
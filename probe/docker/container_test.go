@@ -86,12 +86,23 @@ func TestContainer(t *testing.T) {
 		"docker_container_ips_with_scopes": report.MakeStringSet("scope;1.2.3.4"),
 	}).WithControls(
 		docker.RestartContainer, docker.StopContainer, docker.PauseContainer,
-		docker.AttachContainer, docker.ExecContainer,
+		docker.AttachContainer, docker.ExecContainer, docker.KillContainer,
+		docker.LogsContainer,
 	).WithLatest(
 		"docker_container_state", now, "running",
 	).WithMetrics(report.Metrics{
-		"cpu_total_usage": report.MakeMetric(),
-		"memory_usage":    report.MakeMetric().Add(now, 12345),
+		"cpu_total_usage":              report.MakeMetric(),
+		"memory_usage":                 report.MakeMetric().Add(now.Truncate(time.Second), 12345),
+		"blkio_read_bytes_rate":        report.MakeMetric(),
+		"blkio_write_bytes_rate":       report.MakeMetric(),
+		"blkio_read_iops":              report.MakeMetric(),
+		"blkio_write_iops":             report.MakeMetric(),
+		"network_rx_bytes_rate/eth0":   report.MakeMetric(),
+		"network_tx_bytes_rate/eth0":   report.MakeMetric(),
+		"network_rx_packets_rate/eth0": report.MakeMetric(),
+		"network_tx_packets_rate/eth0": report.MakeMetric(),
+		"network_rx_errors_rate/eth0":  report.MakeMetric(),
+		"network_tx_errors_rate/eth0":  report.MakeMetric(),
 	})
 	test.Poll(t, 100*time.Millisecond, want, func() interface{} {
 		node := c.GetNode("scope", []net.IP{})
@@ -113,3 +124,100 @@ func TestContainer(t *testing.T) {
 		t.Errorf("%v != %v", have, []string{"1.2.3.4"})
 	}
 }
+
+func TestContainerRateMetrics(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	oldDialStub, oldNewClientConnStub := docker.DialStub, docker.NewClientConnStub
+	defer func() { docker.DialStub, docker.NewClientConnStub = oldDialStub, oldNewClientConnStub }()
+
+	docker.DialStub = func(network, address string) (net.Conn, error) {
+		return nil, nil
+	}
+
+	reader, writer := io.Pipe()
+	connection := &mockConnection{reader}
+
+	docker.NewClientConnStub = func(c net.Conn, r *bufio.Reader) docker.ClientConn {
+		return connection
+	}
+
+	c := docker.NewContainer(container1)
+	if err := c.StartGatheringStats(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopGatheringStats()
+
+	// Docker 1.9 and later report the network stats per interface, which
+	// the client doesn't know about.
+	then := time.Unix(12345, 0).UTC()
+	now := then.Add(2*time.Second + 500*time.Millisecond)
+	for _, s := range []map[string]interface{}{
+		{
+			"read": then,
+			"networks": map[string]interface{}{
+				"eth0": map[string]uint64{"rx_bytes": 1000, "tx_packets": 10},
+				"eth1": map[string]uint64{"rx_bytes": 0},
+			},
+			"blkio_stats": map[string]interface{}{
+				"io_service_bytes_recursive": []client.BlkioStatsEntry{{Op: "Read", Value: 4096}, {Op: "Total", Value: 4096}},
+				"io_serviced_recursive":      []client.BlkioStatsEntry{{Op: "Write", Value: 5}},
+			},
+		},
+		{
+			"read": now,
+			"networks": map[string]interface{}{
+				"eth0": map[string]uint64{"rx_bytes": 6000, "tx_packets": 20},
+				"eth1": map[string]uint64{"rx_bytes": 500},
+			},
+			"blkio_stats": map[string]interface{}{
+				"io_service_bytes_recursive": []client.BlkioStatsEntry{{Op: "Read", Value: 14096}, {Op: "Total", Value: 14096}},
+				"io_serviced_recursive":      []client.BlkioStatsEntry{{Op: "Write", Value: 10}},
+			},
+		},
+	} {
+		if err := json.NewEncoder(writer).Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Samples are at whole seconds
+	at := now.Truncate(time.Second)
+	want := map[string]float64{
+		docker.BlkioReadBytesRate:  4000,
+		docker.BlkioWriteBytesRate: 0,
+		docker.BlkioReadIOPS:       0,
+		docker.BlkioWriteIOPS:      2,
+		docker.NetworkMetricKey(docker.NetworkRxBytesRate, "eth0"):   2000,
+		docker.NetworkMetricKey(docker.NetworkTxPacketsRate, "eth0"): 4,
+		docker.NetworkMetricKey(docker.NetworkRxBytesRate, "eth1"):   200,
+	}
+	test.Poll(t, 100*time.Millisecond, want, func() interface{} {
+		metrics := c.GetNode("scope", []net.IP{}).Metrics
+		have := map[string]float64{}
+		for key := range want {
+			if s := metrics[key].LastSample(); s != nil && s.Timestamp.Equal(at) {
+				have[key] = s.Value
+			}
+		}
+		return have
+	})
+}
+
+func TestContainerDualStack(t *testing.T) {
+	c := docker.NewContainer(&client.Container{
+		ID:     "ping",
+		Config: &client.Config{},
+		NetworkSettings: &client.NetworkSettings{
+			IPAddress:         "172.17.0.2",
+			GlobalIPv6Address: "fd00:dead:beef::2",
+		},
+	})
+	node := c.GetNode("scope", []net.IP{})
+	if want, have := report.MakeStringSet("172.17.0.2", "fd00:dead:beef::2"), node.Sets[docker.ContainerIPs]; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if want, have := report.MakeStringSet("scope;172.17.0.2", "scope;fd00:dead:beef::2"), node.Sets[docker.ContainerIPsWithScopes]; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
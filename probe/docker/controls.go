@@ -1,7 +1,13 @@
 package docker
 
 import (
+	"io"
+	"io/ioutil"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	docker_client "github.com/fsouza/go-dockerclient"
 
@@ -19,10 +25,72 @@ const (
 	UnpauseContainer = "docker_unpause_container"
 	AttachContainer  = "docker_attach_container"
 	ExecContainer    = "docker_exec_container"
+	LogsContainer    = "docker_logs_container"
+	RemoveContainer  = "docker_remove_container"
+	KillContainer    = "docker_kill_container"
+	ResizeTTY        = "docker_resize_tty"
 
 	waitTime = 10
 )
 
+// The controls which take arguments. Their params are validated before they
+// get to the handlers.
+var (
+	removeContainerControl = report.Control{
+		ID:    RemoveContainer,
+		Human: "Remove",
+		Icon:  "fa-trash-o",
+		Params: []report.ControlParam{
+			{Name: "volumes", Human: "Remove volumes", Type: report.BoolParam, Default: "false"},
+		},
+	}
+	killContainerControl = report.Control{
+		ID:    KillContainer,
+		Human: "Kill",
+		Icon:  "fa-times",
+		Params: []report.ControlParam{
+			{Name: "signal", Human: "Signal", Type: report.EnumParam, Default: "KILL", Options: signalNames()},
+		},
+	}
+	execContainerControl = report.Control{
+		ID:    ExecContainer,
+		Human: "Exec",
+		Icon:  "fa-terminal",
+		Params: []report.ControlParam{
+			{Name: "command", Human: "Command", Type: report.StringParam, Default: "/bin/sh"},
+		},
+	}
+	// logsContainerControl's params have no defaults: without them, the logs
+	// start where the probe's LogsConfig says.
+	logsContainerControl = report.Control{
+		ID:    LogsContainer,
+		Human: "Logs",
+		Icon:  "fa-file-text-o",
+		Params: []report.ControlParam{
+			{Name: "tail", Human: "Lines from the end (0 for all)", Type: report.IntParam},
+			{Name: "since", Human: "Seconds back (0 for the beginning)", Type: report.IntParam},
+		},
+	}
+	// resizeTTYControl isn't a button on nodes; the UI uses it to resize
+	// the TTY of an attach or exec pipe along with its terminal.
+	resizeTTYControl = report.Control{
+		ID:    ResizeTTY,
+		Human: "Resize TTY",
+		Icon:  "fa-arrows-alt",
+		Params: []report.ControlParam{
+			{Name: "pipe", Human: "Pipe", Type: report.StringParam, Required: true},
+			{Name: "height", Human: "Height", Type: report.IntParam, Required: true},
+			{Name: "width", Human: "Width", Type: report.IntParam, Required: true},
+		},
+	}
+)
+
+// tty is the TTY of a container an attach or exec pipe is connected to.
+type tty struct {
+	containerID string
+	resize      func(height, width int) error
+}
+
 func (r *registry) stopContainer(containerID string, _ xfer.Request) xfer.Response {
 	log.Printf("Stopping container %s", containerID)
 	return xfer.ResponseError(r.client.StopContainer(containerID, waitTime))
@@ -48,6 +116,75 @@ func (r *registry) unpauseContainer(containerID string, _ xfer.Request) xfer.Res
 	return xfer.ResponseError(r.client.UnpauseContainer(containerID))
 }
 
+func (r *registry) removeContainer(containerID string, req xfer.Request) xfer.Response {
+	log.Printf("Removing container %s", containerID)
+	volumes, _ := strconv.ParseBool(req.Args["volumes"])
+	return xfer.ResponseError(r.client.RemoveContainer(docker_client.RemoveContainerOptions{
+		ID:            containerID,
+		RemoveVolumes: volumes,
+	}))
+}
+
+func (r *registry) killContainer(containerID string, req xfer.Request) xfer.Response {
+	signal := signals[req.Args["signal"]]
+	log.Printf("Killing container %s with signal %d", containerID, signal)
+	return xfer.ResponseError(r.client.KillContainer(docker_client.KillContainerOptions{
+		ID:     containerID,
+		Signal: signal,
+	}))
+}
+
+// signals are the signals the Kill control can send, by name.
+var signals = map[string]docker_client.Signal{
+	"ABRT": docker_client.SIGABRT,
+	"ALRM": docker_client.SIGALRM,
+	"CONT": docker_client.SIGCONT,
+	"HUP":  docker_client.SIGHUP,
+	"INT":  docker_client.SIGINT,
+	"KILL": docker_client.SIGKILL,
+	"QUIT": docker_client.SIGQUIT,
+	"STOP": docker_client.SIGSTOP,
+	"TERM": docker_client.SIGTERM,
+	"TSTP": docker_client.SIGTSTP,
+	"USR1": docker_client.SIGUSR1,
+	"USR2": docker_client.SIGUSR2,
+}
+
+func signalNames() []string {
+	result := make([]string, 0, len(signals))
+	for name := range signals {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (r *registry) resizeTTY(containerID string, req xfer.Request) xfer.Response {
+	r.RLock()
+	t, ok := r.ttys[req.Args["pipe"]]
+	r.RUnlock()
+	if !ok || t.containerID != containerID {
+		return xfer.ResponseErrorf("Not found: %s", req.Args["pipe"])
+	}
+	height, _ := strconv.Atoi(req.Args["height"])
+	width, _ := strconv.Atoi(req.Args["width"])
+	return xfer.ResponseError(t.resize(height, width))
+}
+
+// addTTY makes the TTY a pipe is connected to resizable, until the pipe is
+// closed.
+func (r *registry) addTTY(pipeID string, t tty) {
+	r.Lock()
+	defer r.Unlock()
+	r.ttys[pipeID] = t
+}
+
+func (r *registry) rmTTY(pipeID string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.ttys, pipeID)
+}
+
 func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Response {
 	c, ok := r.GetContainer(containerID)
 	if !ok {
@@ -57,7 +194,7 @@ func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Re
 	hasTTY := c.HasTTY()
 	id, pipe, err := controls.NewPipe(r.pipes, req.AppID)
 	if err != nil {
-		xfer.ResponseError(err)
+		return xfer.ResponseError(err)
 	}
 	local, _ := pipe.Ends()
 	cw, err := r.client.AttachToContainerNonBlocking(docker_client.AttachToContainerOptions{
@@ -74,7 +211,13 @@ func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Re
 	if err != nil {
 		return xfer.ResponseError(err)
 	}
+	if hasTTY {
+		r.addTTY(id, tty{containerID, func(height, width int) error {
+			return r.client.ResizeContainerTTY(containerID, height, width)
+		}})
+	}
 	pipe.OnClose(func() {
+		r.rmTTY(id)
 		if err := cw.Close(); err != nil {
 			log.Printf("Error closing attachment: %v", err)
 			return
@@ -94,21 +237,28 @@ func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Re
 }
 
 func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Response {
+	command := strings.TrimSpace(req.Args["command"])
+	if command == "" {
+		return xfer.ResponseErrorf("No command to exec")
+	}
+	// The shell parses the command, so it can quote its arguments.
+	cmd := []string{"/bin/sh", "-c", command}
+	log.Printf("Exec %q on container %s", command, containerID)
 	exec, err := r.client.CreateExec(docker_client.CreateExecOptions{
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          true,
-		Cmd:          []string{"/bin/sh"},
+		Cmd:          cmd,
 		Container:    containerID,
 	})
 	if err != nil {
-		xfer.ResponseError(err)
+		return xfer.ResponseError(err)
 	}
 
 	id, pipe, err := controls.NewPipe(r.pipes, req.AppID)
 	if err != nil {
-		xfer.ResponseError(err)
+		return xfer.ResponseError(err)
 	}
 	local, _ := pipe.Ends()
 	cw, err := r.client.StartExecNonBlocking(exec.ID, docker_client.StartExecOptions{
@@ -121,7 +271,11 @@ func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Resp
 	if err != nil {
 		return xfer.ResponseError(err)
 	}
+	r.addTTY(id, tty{containerID, func(height, width int) error {
+		return r.client.ResizeExecTTY(exec.ID, height, width)
+	}})
 	pipe.OnClose(func() {
+		r.rmTTY(id)
 		if err := cw.Close(); err != nil {
 			log.Printf("Error closing exec: %v", err)
 			return
@@ -140,6 +294,76 @@ func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Resp
 	}
 }
 
+// LogsConfig configures where the Logs control starts the logs it streams.
+type LogsConfig struct {
+	Tail  int           // number of lines from the end of the logs; 0 for all of them
+	Since time.Duration // how far back in time; 0 for the beginning
+}
+
+func (r *registry) logsContainer(containerID string, req xfer.Request) xfer.Response {
+	c, ok := r.GetContainer(containerID)
+	if !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+
+	hasTTY := c.HasTTY()
+	id, pipe, err := controls.NewPipe(r.pipes, req.AppID)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	local, _ := pipe.Ends()
+	tail, since := r.logs.Tail, r.logs.Since
+	if arg, ok := req.Args["tail"]; ok {
+		tail, _ = strconv.Atoi(arg)
+	}
+	if arg, ok := req.Args["since"]; ok {
+		seconds, _ := strconv.Atoi(arg)
+		since = time.Duration(seconds) * time.Second
+	}
+	opts := docker_client.LogsOptions{
+		Container:    containerID,
+		Follow:       true,
+		Stdout:       true,
+		Stderr:       true,
+		Tail:         "all",
+		RawTerminal:  hasTTY,
+		OutputStream: local,
+		ErrorStream:  local,
+	}
+	if tail > 0 {
+		opts.Tail = strconv.Itoa(tail)
+	}
+	if since > 0 {
+		opts.Since = time.Now().Add(-since).Unix()
+	}
+	cw, err := r.client.LogsNonBlocking(opts)
+	if err != nil {
+		pipe.Close()
+		return xfer.ResponseError(err)
+	}
+	pipe.OnClose(func() {
+		if err := cw.Close(); err != nil {
+			log.Printf("Error closing logs: %v", err)
+			return
+		}
+		log.Printf("Logs of container %s closed.", containerID)
+	})
+	go func() {
+		// The logs are read-only, so anything from the UI is thrown away.
+		io.Copy(ioutil.Discard, local)
+	}()
+	go func() {
+		if err := cw.Wait(); err != nil {
+			log.Printf("Error streaming logs: %v", err)
+		}
+		pipe.Close()
+	}()
+	return xfer.Response{
+		Pipe:   id,
+		RawTTY: hasTTY,
+	}
+}
+
 func captureContainerID(f func(string, xfer.Request) xfer.Response) func(xfer.Request) xfer.Response {
 	return func(req xfer.Request) xfer.Response {
 		_, containerID, ok := report.ParseContainerNodeID(req.NodeID)
@@ -157,5 +381,9 @@ func (r *registry) registerControls() {
 	controls.Register(PauseContainer, captureContainerID(r.pauseContainer))
 	controls.Register(UnpauseContainer, captureContainerID(r.unpauseContainer))
 	controls.Register(AttachContainer, captureContainerID(r.attachContainer))
-	controls.Register(ExecContainer, captureContainerID(r.execContainer))
+	controls.RegisterWithParams(execContainerControl, captureContainerID(r.execContainer))
+	controls.RegisterWithParams(logsContainerControl, captureContainerID(r.logsContainer))
+	controls.RegisterWithParams(removeContainerControl, captureContainerID(r.removeContainer))
+	controls.RegisterWithParams(killContainerControl, captureContainerID(r.killContainer))
+	controls.RegisterWithParams(resizeTTYControl, captureContainerID(r.resizeTTY))
 }
@@ -18,19 +18,31 @@ import (
 func TestControls(t *testing.T) {
 	mdc := newMockClient()
 	setupStubs(mdc, func() {
-		registry, _ := docker.NewRegistry(10*time.Second, nil)
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
 		defer registry.Stop()
 
-		for _, tc := range []struct{ command, result string }{
-			{docker.StopContainer, "stopped"},
-			{docker.StartContainer, "started"},
-			{docker.RestartContainer, "restarted"},
-			{docker.PauseContainer, "paused"},
-			{docker.UnpauseContainer, "unpaused"},
+		for _, tc := range []struct {
+			command string
+			args    map[string]string
+			result  string
+		}{
+			{docker.StopContainer, nil, "stopped"},
+			{docker.StartContainer, nil, "started"},
+			{docker.RestartContainer, nil, "restarted"},
+			{docker.PauseContainer, nil, "paused"},
+			{docker.UnpauseContainer, nil, "unpaused"},
+			{docker.RemoveContainer, nil, "removed (volumes false)"},
+			{docker.RemoveContainer, map[string]string{"volumes": "true"}, "removed (volumes true)"},
+			{docker.RemoveContainer, map[string]string{"volumes": "yes please"}, `docker_remove_container: argument "volumes": "yes please" is not of type bool`},
+			{docker.KillContainer, nil, "killed (signal 9)"},
+			{docker.KillContainer, map[string]string{"signal": "TERM"}, "killed (signal 15)"},
+			{docker.KillContainer, map[string]string{"signal": "HUP"}, "killed (signal 1)"},
+			{docker.KillContainer, map[string]string{"signal": "FOO"}, `docker_kill_container: argument "signal": "FOO" is not one of ABRT, ALRM, CONT, HUP, INT, KILL, QUIT, STOP, TERM, TSTP, USR1, USR2`},
 		} {
 			result := controls.HandleControlRequest(xfer.Request{
 				Control: tc.command,
 				NodeID:  report.MakeContainerNodeID("", "a1b2c3d4e5"),
+				Args:    tc.args,
 			})
 			if !reflect.DeepEqual(result, xfer.Response{
 				Error: tc.result,
@@ -58,7 +70,7 @@ func TestPipes(t *testing.T) {
 
 	mdc := newMockClient()
 	setupStubs(mdc, func() {
-		registry, _ := docker.NewRegistry(10*time.Second, nil)
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
 		defer registry.Stop()
 
 		test.Poll(t, 100*time.Millisecond, true, func() interface{} {
@@ -82,5 +94,99 @@ func TestPipes(t *testing.T) {
 				t.Errorf("diff: %s", test.Diff(want, result))
 			}
 		}
+
+		// The TTY of the last pipe (the exec) is resizable
+		for _, tc := range []struct {
+			containerID string
+			args        map[string]string
+			result      string
+		}{
+			{"ping", map[string]string{"pipe": "pipeid", "height": "24", "width": "80"}, `resized exec of ["/bin/sh" "-c" "/bin/sh"] (24x80)`},
+			{"ping", map[string]string{"pipe": "foo", "height": "24", "width": "80"}, "Not found: foo"},
+			{"pong", map[string]string{"pipe": "pipeid", "height": "24", "width": "80"}, "Not found: pipeid"},
+			{"ping", map[string]string{"pipe": "pipeid"}, `docker_resize_tty: missing argument "height"`},
+		} {
+			result := controls.HandleControlRequest(xfer.Request{
+				Control: docker.ResizeTTY,
+				NodeID:  report.MakeContainerNodeID("", tc.containerID),
+				Args:    tc.args,
+			})
+			if want := (xfer.Response{Error: tc.result}); !reflect.DeepEqual(result, want) {
+				t.Errorf("diff: %s", test.Diff(want, result))
+			}
+		}
+
+		// Exec can run other commands, with quoted arguments
+		controls.HandleControlRequest(xfer.Request{
+			Control: docker.ExecContainer,
+			NodeID:  report.MakeContainerNodeID("", "ping"),
+			Args:    map[string]string{"command": `grep -r "two words" /etc`},
+		})
+		result := controls.HandleControlRequest(xfer.Request{
+			Control: docker.ResizeTTY,
+			NodeID:  report.MakeContainerNodeID("", "ping"),
+			Args:    map[string]string{"pipe": "pipeid", "height": "24", "width": "80"},
+		})
+		if want := (xfer.Response{Error: `resized exec of ["/bin/sh" "-c" "grep -r \"two words\" /etc"] (24x80)`}); !reflect.DeepEqual(result, want) {
+			t.Errorf("diff: %s", test.Diff(want, result))
+		}
+	})
+}
+
+func TestLogs(t *testing.T) {
+	pipe := xfer.NewPipe()
+	oldNewPipe := controls.NewPipe
+	defer func() { controls.NewPipe = oldNewPipe }()
+	controls.NewPipe = func(_ controls.PipeClient, _ string) (string, xfer.Pipe, error) {
+		return "pipeid", pipe, nil
+	}
+
+	mdc := newMockClient()
+	setupStubs(mdc, func() {
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{Tail: 10})
+		defer registry.Stop()
+
+		test.Poll(t, 100*time.Millisecond, true, func() interface{} {
+			_, ok := registry.GetContainer("ping")
+			return ok
+		})
+
+		for _, tc := range []struct {
+			args map[string]string
+			want string
+		}{
+			{nil, "logs of ping from 10 since 0\n"},
+			{map[string]string{"tail": "0", "since": "0"}, "logs of ping from all since 0\n"},
+			{map[string]string{"tail": "5"}, "logs of ping from 5 since 0\n"},
+		} {
+			pipe = xfer.NewPipe()
+			result := controls.HandleControlRequest(xfer.Request{
+				Control: docker.LogsContainer,
+				NodeID:  report.MakeContainerNodeID("", "ping"),
+				Args:    tc.args,
+			})
+			if want := (xfer.Response{Pipe: "pipeid", RawTTY: true}); !reflect.DeepEqual(result, want) {
+				t.Errorf("diff: %s", test.Diff(want, result))
+			}
+
+			_, remote := pipe.Ends()
+			have := make([]byte, len(tc.want))
+			if _, err := io.ReadFull(remote, have); err != nil {
+				t.Fatal(err)
+			}
+			if string(have) != tc.want {
+				t.Errorf("want %q, have %q", tc.want, have)
+			}
+			pipe.Close()
+		}
+
+		result := controls.HandleControlRequest(xfer.Request{
+			Control: docker.LogsContainer,
+			NodeID:  report.MakeContainerNodeID("", "ping"),
+			Args:    map[string]string{"tail": "last"},
+		})
+		if result.Error == "" {
+			t.Errorf("want an error for a tail which isn't a number, have %v", result)
+		}
 	})
 }
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,7 +19,17 @@ const (
 	DieEvent     = "die"
 	PauseEvent   = "pause"
 	UnpauseEvent = "unpause"
+	RenameEvent  = "rename"
+	PullEvent    = "pull"
+	TagEvent     = "tag"
+	UntagEvent   = "untag"
+	DeleteEvent  = "delete" // of an image
+	ImportEvent  = "import"
 	endpoint     = "unix:///var/run/docker.sock"
+
+	// relistenWait is how long we wait before listening for events again,
+	// when the client gives up on the stream (e.g. docker is down).
+	relistenWait = 5 * time.Second
 )
 
 // Vars exported for testing.
@@ -37,7 +48,9 @@ type Registry interface {
 	GetContainer(string) (Container, bool)
 }
 
-// ContainerUpdateWatcher is the type of functions that get called when containers are updated.
+// ContainerUpdateWatcher is the type of functions that get called when
+// containers are updated. When a container is destroyed, they're called
+// with it one last time, in StateDeleted.
 type ContainerUpdateWatcher func(c Container)
 
 type registry struct {
@@ -46,11 +59,13 @@ type registry struct {
 	interval time.Duration
 	client   Client
 	pipes    controls.PipeClient
+	logs     LogsConfig
 
 	watchers        []ContainerUpdateWatcher
 	containers      map[string]Container
 	containersByPID map[int]Container
 	images          map[string]*docker_client.APIImages
+	ttys            map[string]tty // by the ID of the pipe connected to them
 }
 
 // Client interface for mocking.
@@ -69,14 +84,23 @@ type Client interface {
 	AttachToContainerNonBlocking(docker_client.AttachToContainerOptions) (docker_client.CloseWaiter, error)
 	CreateExec(docker_client.CreateExecOptions) (*docker_client.Exec, error)
 	StartExecNonBlocking(string, docker_client.StartExecOptions) (docker_client.CloseWaiter, error)
+	LogsNonBlocking(docker_client.LogsOptions) (docker_client.CloseWaiter, error)
+	RemoveContainer(docker_client.RemoveContainerOptions) error
+	KillContainer(docker_client.KillContainerOptions) error
+	ResizeContainerTTY(string, int, int) error
+	ResizeExecTTY(string, int, int) error
 }
 
 func newDockerClient(endpoint string) (Client, error) {
-	return docker_client.NewClient(endpoint)
+	client, err := docker_client.NewClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return dockerClient{client}, nil
 }
 
 // NewRegistry returns a usable Registry. Don't forget to Stop it.
-func NewRegistry(interval time.Duration, pipes controls.PipeClient) (Registry, error) {
+func NewRegistry(interval time.Duration, pipes controls.PipeClient, logs LogsConfig) (Registry, error) {
 	client, err := NewDockerClientStub(endpoint)
 	if err != nil {
 		return nil, err
@@ -86,9 +110,11 @@ func NewRegistry(interval time.Duration, pipes controls.PipeClient) (Registry, e
 		containers:      map[string]Container{},
 		containersByPID: map[int]Container{},
 		images:          map[string]*docker_client.APIImages{},
+		ttys:            map[string]tty{},
 
 		client:   client,
 		pipes:    pipes,
+		logs:     logs,
 		interval: interval,
 		quit:     make(chan chan struct{}),
 	}
@@ -123,19 +149,17 @@ func (r *registry) loop() {
 
 		// Sleep here so we don't hammer the
 		// logs if docker is down
-		time.Sleep(r.interval)
+		time.Sleep(relistenWait)
 	}
 }
 
+// listenForEvents keeps the registry up to date with the events from docker.
+// The client resumes the stream from the last event it saw (with since) if
+// the connection drops, but if it gives up, so do we; we then listen again,
+// reconciling what we know with docker, but without starting from scratch.
 func (r *registry) listenForEvents() bool {
-	// First we empty the store lists.
-	// This ensure any containers that went away inbetween calls to
-	// listenForEvents don't hang around.
-	r.reset()
-
-	// Next, start listening for events.  We do this before fetching
-	// the list of containers so we don't miss containers created
-	// after listing but before listening for events.
+	// Start listening for events before we reconcile, so we don't miss
+	// containers created after listing but before listening for events.
 	events := make(chan *docker_client.APIEvents)
 	if err := r.client.AddEventListener(events); err != nil {
 		log.Printf("docker registry: %s", err)
@@ -147,24 +171,24 @@ func (r *registry) listenForEvents() bool {
 		}
 	}()
 
-	if err := r.updateContainers(); err != nil {
-		log.Printf("docker registry: %s", err)
-		return true
-	}
-
-	if err := r.updateImages(); err != nil {
+	if err := r.reconcile(); err != nil {
 		log.Printf("docker registry: %s", err)
 		return true
 	}
 
-	otherUpdates := time.Tick(r.interval)
+	// The events should tell us everything; reconciling is a safety net.
+	reconcile := time.Tick(r.interval)
 	for {
 		select {
-		case event := <-events:
+		case event, ok := <-events:
+			if !ok {
+				log.Printf("docker registry: event stream closed")
+				return true
+			}
 			r.handleEvent(event)
 
-		case <-otherUpdates:
-			if err := r.updateImages(); err != nil {
+		case <-reconcile:
+			if err := r.reconcile(); err != nil {
 				log.Printf("docker registry: %s", err)
 				return true
 			}
@@ -182,30 +206,49 @@ func (r *registry) listenForEvents() bool {
 	}
 }
 
-func (r *registry) reset() {
-	r.Lock()
-	defer r.Unlock()
-
-	for _, c := range r.containers {
-		c.StopGatheringStats()
-	}
-
-	r.containers = map[string]Container{}
-	r.containersByPID = map[int]Container{}
-	r.images = map[string]*docker_client.APIImages{}
-}
-
-func (r *registry) updateContainers() error {
+// reconcile brings the registry into line with the containers and images
+// docker has, in case we missed any events. Only the containers which are
+// new, or in a different state, are inspected.
+func (r *registry) reconcile() error {
 	apiContainers, err := r.client.ListContainers(docker_client.ListContainersOptions{All: true})
 	if err != nil {
 		return err
 	}
 
+	listed := map[string]struct{}{}
 	for _, apiContainer := range apiContainers {
-		r.updateContainerState(apiContainer.ID)
+		listed[apiContainer.ID] = struct{}{}
+		if c, ok := r.GetContainer(apiContainer.ID); !ok || c.State() != listedState(apiContainer.Status) {
+			r.updateContainerState(apiContainer.ID)
+		}
 	}
 
-	return nil
+	gone := []string{}
+	r.RLock()
+	for id := range r.containers {
+		if _, ok := listed[id]; !ok {
+			gone = append(gone, id)
+		}
+	}
+	r.RUnlock()
+	for _, id := range gone {
+		r.forgetContainer(id)
+	}
+
+	return r.updateImages()
+}
+
+// listedState is the state of a container, from its status when listed,
+// e.g. "Up 2 hours (Paused)".
+func listedState(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(Paused)"):
+		return StatePaused
+	case strings.HasPrefix(status, "Up"):
+		return StateRunning
+	default:
+		return StateStopped
+	}
 }
 
 func (r *registry) updateImages() error {
@@ -217,6 +260,7 @@ func (r *registry) updateImages() error {
 	r.Lock()
 	defer r.Unlock()
 
+	r.images = make(map[string]*docker_client.APIImages, len(images))
 	for i := range images {
 		image := &images[i]
 		r.images[image.ID] = image
@@ -227,8 +271,39 @@ func (r *registry) updateImages() error {
 
 func (r *registry) handleEvent(event *docker_client.APIEvents) {
 	switch event.Status {
-	case CreateEvent, StartEvent, DieEvent, DestroyEvent, PauseEvent, UnpauseEvent:
+	case CreateEvent, StartEvent, DieEvent, PauseEvent, UnpauseEvent, RenameEvent:
 		r.updateContainerState(event.ID)
+	case DestroyEvent:
+		// No need to inspect it to know it's gone
+		r.forgetContainer(event.ID)
+	case PullEvent, TagEvent, UntagEvent, DeleteEvent, ImportEvent:
+		if err := r.updateImages(); err != nil {
+			log.Printf("docker registry: %s", err)
+		}
+	}
+}
+
+// forgetContainer removes a container which no longer exists, and tells the
+// watchers it's been deleted.
+func (r *registry) forgetContainer(containerID string) {
+	r.Lock()
+	defer r.Unlock()
+	r.dropContainer(containerID)
+}
+
+// dropContainer is forgetContainer, with the registry already locked.
+func (r *registry) dropContainer(containerID string) {
+	c, ok := r.containers[containerID]
+	if !ok {
+		return
+	}
+
+	delete(r.containers, containerID)
+	delete(r.containersByPID, c.PID())
+	c.StopGatheringStats()
+
+	for _, f := range r.watchers {
+		f(deletedContainer{c})
 	}
 }
 
@@ -245,14 +320,7 @@ func (r *registry) updateContainerState(containerID string) {
 		}
 
 		// Container doesn't exist anymore, so lets stop and remove it
-		container, ok := r.containers[containerID]
-		if !ok {
-			return
-		}
-
-		delete(r.containers, containerID)
-		delete(r.containersByPID, container.PID())
-		container.StopGatheringStats()
+		r.dropContainer(containerID)
 		return
 	}
 
@@ -139,14 +139,35 @@ func (m *mockDockerClient) AttachToContainerNonBlocking(_ client.AttachToContain
 	return mockCloseWaiter{}, nil
 }
 
-func (m *mockDockerClient) CreateExec(client.CreateExecOptions) (*client.Exec, error) {
-	return &client.Exec{ID: "id"}, nil
+func (m *mockDockerClient) CreateExec(opts client.CreateExecOptions) (*client.Exec, error) {
+	return &client.Exec{ID: fmt.Sprintf("%q", opts.Cmd)}, nil
 }
 
 func (m *mockDockerClient) StartExecNonBlocking(string, client.StartExecOptions) (client.CloseWaiter, error) {
 	return mockCloseWaiter{}, nil
 }
 
+func (m *mockDockerClient) LogsNonBlocking(opts client.LogsOptions) (client.CloseWaiter, error) {
+	go fmt.Fprintf(opts.OutputStream, "logs of %s from %s since %d\n", opts.Container, opts.Tail, opts.Since)
+	return mockCloseWaiter{}, nil
+}
+
+func (m *mockDockerClient) RemoveContainer(opts client.RemoveContainerOptions) error {
+	return fmt.Errorf("removed (volumes %v)", opts.RemoveVolumes)
+}
+
+func (m *mockDockerClient) KillContainer(opts client.KillContainerOptions) error {
+	return fmt.Errorf("killed (signal %d)", opts.Signal)
+}
+
+func (m *mockDockerClient) ResizeContainerTTY(_ string, height, width int) error {
+	return fmt.Errorf("resized container (%dx%d)", height, width)
+}
+
+func (m *mockDockerClient) ResizeExecTTY(id string, height, width int) error {
+	return fmt.Errorf("resized exec of %s (%dx%d)", id, height, width)
+}
+
 func (m *mockDockerClient) send(event *client.APIEvents) {
 	m.RLock()
 	defer m.RUnlock()
@@ -192,8 +213,8 @@ var (
 			},
 		},
 	}
-	apiContainer1 = client.APIContainers{ID: "ping"}
-	apiContainer2 = client.APIContainers{ID: "wiff"}
+	apiContainer1 = client.APIContainers{ID: "ping", Status: "Up 2 minutes"}
+	apiContainer2 = client.APIContainers{ID: "wiff", Status: "Up 3 seconds"}
 	apiImage1     = client.APIImages{ID: "baz", RepoTags: []string{"bang", "not-chosen"}}
 )
 
@@ -246,7 +267,7 @@ func allImages(r docker.Registry) []*client.APIImages {
 func TestRegistry(t *testing.T) {
 	mdc := newMockClient()
 	setupStubs(mdc, func() {
-		registry, _ := docker.NewRegistry(10*time.Second, nil)
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
 		defer registry.Stop()
 		runtime.Gosched()
 
@@ -269,7 +290,7 @@ func TestRegistry(t *testing.T) {
 func TestLookupByPID(t *testing.T) {
 	mdc := newMockClient()
 	setupStubs(mdc, func() {
-		registry, _ := docker.NewRegistry(10*time.Second, nil)
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
 		defer registry.Stop()
 
 		want := docker.Container(&mockContainer{container1})
@@ -286,7 +307,7 @@ func TestLookupByPID(t *testing.T) {
 func TestRegistryEvents(t *testing.T) {
 	mdc := newMockClient()
 	setupStubs(mdc, func() {
-		registry, _ := docker.NewRegistry(10*time.Second, nil)
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
 		defer registry.Stop()
 		runtime.Gosched()
 
@@ -341,3 +362,88 @@ func TestRegistryEvents(t *testing.T) {
 		}
 	})
 }
+
+func TestRegistryDestroyEvent(t *testing.T) {
+	mdc := newMockClient()
+	setupStubs(mdc, func() {
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
+		defer registry.Stop()
+
+		var (
+			mtx         sync.Mutex
+			lastUpdated string
+		)
+		registry.WatchContainerUpdates(func(c docker.Container) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			lastUpdated = c.ID() + " " + c.State()
+		})
+		test.Poll(t, 100*time.Millisecond, []docker.Container{&mockContainer{container1}}, func() interface{} {
+			return allContainers(registry)
+		})
+
+		// The container is forgotten without being inspected, which would
+		// still find it.
+		mdc.send(&client.APIEvents{Status: docker.DestroyEvent, ID: "ping"})
+		test.Poll(t, 100*time.Millisecond, []docker.Container{}, func() interface{} {
+			return allContainers(registry)
+		})
+
+		want := "ping " + docker.StateDeleted
+		test.Poll(t, 100*time.Millisecond, want, func() interface{} {
+			mtx.Lock()
+			defer mtx.Unlock()
+			return lastUpdated
+		})
+	})
+}
+
+func TestRegistryImageEvents(t *testing.T) {
+	mdc := newMockClient()
+	setupStubs(mdc, func() {
+		registry, _ := docker.NewRegistry(10*time.Second, nil, docker.LogsConfig{})
+		defer registry.Stop()
+
+		test.Poll(t, 100*time.Millisecond, []*client.APIImages{&apiImage1}, func() interface{} {
+			return allImages(registry)
+		})
+
+		retagged := client.APIImages{ID: "baz", RepoTags: []string{"bang", "boom"}}
+		mdc.Lock()
+		mdc.apiImages = []client.APIImages{retagged}
+		mdc.Unlock()
+		mdc.send(&client.APIEvents{Status: docker.TagEvent, ID: "baz"})
+
+		test.Poll(t, 100*time.Millisecond, []*client.APIImages{&retagged}, func() interface{} {
+			return allImages(registry)
+		})
+	})
+}
+
+func TestRegistryReconcile(t *testing.T) {
+	mdc := newMockClient()
+	setupStubs(mdc, func() {
+		registry, _ := docker.NewRegistry(10*time.Millisecond, nil, docker.LogsConfig{})
+		defer registry.Stop()
+
+		check := func(want []docker.Container) {
+			test.Poll(t, 100*time.Millisecond, want, func() interface{} {
+				return allContainers(registry)
+			})
+		}
+		check([]docker.Container{&mockContainer{container1}})
+
+		// Without any events, as if we'd missed them
+		mdc.Lock()
+		mdc.apiContainers = []client.APIContainers{apiContainer1, apiContainer2}
+		mdc.containers["wiff"] = container2
+		mdc.Unlock()
+		check([]docker.Container{&mockContainer{container1}, &mockContainer{container2}})
+
+		mdc.Lock()
+		mdc.apiContainers = []client.APIContainers{apiContainer2}
+		delete(mdc.containers, "ping")
+		mdc.Unlock()
+		check([]docker.Container{&mockContainer{container2}})
+	})
+}
@@ -18,17 +18,20 @@ const (
 
 // Reporter generate Reports containing Container and ContainerImage topologies
 type Reporter struct {
-	registry Registry
-	hostID   string
-	probe    *probe.Probe
+	registry   Registry
+	hostID     string
+	scopedNets report.Networks
+	probe      *probe.Probe
 }
 
-// NewReporter makes a new Reporter
-func NewReporter(registry Registry, hostID string, probe *probe.Probe) *Reporter {
+// NewReporter makes a new Reporter. Addresses in the scoped networks (e.g.
+// the Docker bridge) aren't considered host addresses for port mappings.
+func NewReporter(registry Registry, hostID string, scopedNets report.Networks, probe *probe.Probe) *Reporter {
 	reporter := &Reporter{
-		registry: registry,
-		hostID:   hostID,
-		probe:    probe,
+		registry:   registry,
+		hostID:     hostID,
+		scopedNets: scopedNets,
+		probe:      probe,
 	}
 	registry.WatchContainerUpdates(reporter.ContainerUpdated)
 	return reporter
@@ -39,7 +42,7 @@ func (Reporter) Name() string { return "Docker" }
 
 // ContainerUpdated should be called whenever a container is updated.
 func (r *Reporter) ContainerUpdated(c Container) {
-	localAddrs, err := report.LocalAddresses()
+	localAddrs, err := report.LocalAddresses(r.scopedNets)
 	if err != nil {
 		log.Printf("Error getting local address: %v", err)
 		return
@@ -54,7 +57,7 @@ func (r *Reporter) ContainerUpdated(c Container) {
 
 // Report generates a Report containing Container and ContainerImage topologies
 func (r *Reporter) Report() (report.Report, error) {
-	localAddrs, err := report.LocalAddresses()
+	localAddrs, err := report.LocalAddresses(r.scopedNets)
 	if err != nil {
 		return report.MakeReport(), nil
 	}
@@ -97,11 +100,11 @@ func (r *Reporter) containerTopology(localAddrs []net.IP) report.Topology {
 		Human: "Attach",
 		Icon:  "fa-desktop",
 	})
-	result.Controls.AddControl(report.Control{
-		ID:    ExecContainer,
-		Human: "Exec /bin/sh",
-		Icon:  "fa-terminal",
-	})
+	result.Controls.AddControl(execContainerControl)
+	result.Controls.AddControl(logsContainerControl)
+	result.Controls.AddControl(removeContainerControl)
+	result.Controls.AddControl(killContainerControl)
+	result.Controls.AddControl(resizeTTYControl)
 
 	r.registry.WalkContainers(func(c Container) {
 		nodeID := report.MakeContainerNodeID(r.hostID, c.ID())
@@ -94,8 +94,52 @@ func TestReporter(t *testing.T) {
 			},
 			docker.ExecContainer: report.Control{
 				ID:    docker.ExecContainer,
-				Human: "Exec /bin/sh",
+				Human: "Exec",
 				Icon:  "fa-terminal",
+				Params: []report.ControlParam{
+					{Name: "command", Human: "Command", Type: report.StringParam, Default: "/bin/sh"},
+				},
+			},
+			docker.LogsContainer: report.Control{
+				ID:    docker.LogsContainer,
+				Human: "Logs",
+				Icon:  "fa-file-text-o",
+				Params: []report.ControlParam{
+					{Name: "tail", Human: "Lines from the end (0 for all)", Type: report.IntParam},
+					{Name: "since", Human: "Seconds back (0 for the beginning)", Type: report.IntParam},
+				},
+			},
+			docker.RemoveContainer: report.Control{
+				ID:    docker.RemoveContainer,
+				Human: "Remove",
+				Icon:  "fa-trash-o",
+				Params: []report.ControlParam{
+					{Name: "volumes", Human: "Remove volumes", Type: report.BoolParam, Default: "false"},
+				},
+			},
+			docker.KillContainer: report.Control{
+				ID:    docker.KillContainer,
+				Human: "Kill",
+				Icon:  "fa-times",
+				Params: []report.ControlParam{
+					{
+						Name:    "signal",
+						Human:   "Signal",
+						Type:    report.EnumParam,
+						Default: "KILL",
+						Options: []string{"ABRT", "ALRM", "CONT", "HUP", "INT", "KILL", "QUIT", "STOP", "TERM", "TSTP", "USR1", "USR2"},
+					},
+				},
+			},
+			docker.ResizeTTY: report.Control{
+				ID:    docker.ResizeTTY,
+				Human: "Resize TTY",
+				Icon:  "fa-arrows-alt",
+				Params: []report.ControlParam{
+					{Name: "pipe", Human: "Pipe", Type: report.StringParam, Required: true},
+					{Name: "height", Human: "Height", Type: report.IntParam, Required: true},
+					{Name: "width", Human: "Width", Type: report.IntParam, Required: true},
+				},
 			},
 		},
 	}
@@ -109,7 +153,7 @@ func TestReporter(t *testing.T) {
 		Controls: report.Controls{},
 	}
 
-	reporter := docker.NewReporter(mockRegistryInstance, "", nil)
+	reporter := docker.NewReporter(mockRegistryInstance, "", nil, nil)
 	have, _ := reporter.Report()
 	if !reflect.DeepEqual(want, have) {
 		t.Errorf("%s", test.Diff(want, have))
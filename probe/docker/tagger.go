@@ -44,9 +44,29 @@ func (t *Tagger) Tag(r report.Report) (report.Report, error) {
 		return report.MakeReport(), err
 	}
 	t.tag(tree, &r.Process)
+	tagListeningPorts(r.Process, &r.Container)
 	return r, nil
 }
 
+// tagListeningPorts gives containers the ports their processes listen on, so
+// that idle services are known at the container level too.
+func tagListeningPorts(processes report.Topology, containers *report.Topology) {
+	for _, node := range processes.Nodes {
+		containerID, ok := node.Metadata[ContainerID]
+		if !ok {
+			continue
+		}
+		ports, ok := node.Sets[process.ListeningPorts]
+		if !ok || len(ports) == 0 {
+			continue
+		}
+		containers.AddNode(
+			report.MakeContainerNodeID(report.ExtractHostID(node), containerID),
+			report.MakeNode().WithSet(process.ListeningPorts, ports),
+		)
+	}
+}
+
 func (t *Tagger) tag(tree process.Tree, topology *report.Topology) {
 	for nodeID, node := range topology.Nodes {
 		pidStr, ok := node.Metadata[process.PID]
@@ -58,3 +58,32 @@ func TestTagger(t *testing.T) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
 }
+
+func TestTaggerListeningPorts(t *testing.T) {
+	oldProcessTree := docker.NewProcessTreeStub
+	defer func() { docker.NewProcessTreeStub = oldProcessTree }()
+
+	docker.NewProcessTreeStub = func(_ process.Walker) (process.Tree, error) {
+		return &mockProcessTree{map[int]int{3: 2}}, nil
+	}
+
+	var (
+		pidNodeID       = report.MakeProcessNodeID("somehost.com", "3")
+		containerNodeID = report.MakeContainerNodeID("somehost.com", "ping")
+		ports           = report.MakeStringSet("8080/tcp")
+	)
+	input := report.MakeReport()
+	input.Process.AddNode(pidNodeID, report.MakeNodeWith(map[string]string{
+		process.PID:       "3",
+		report.HostNodeID: report.MakeHostNodeID("somehost.com"),
+	}).WithSet(process.ListeningPorts, ports))
+
+	tagger := docker.NewTagger(mockRegistryInstance, nil)
+	have, err := tagger.Tag(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := ports, have.Container.Nodes[containerNodeID].Sets[process.ListeningPorts]; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/xml"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"strings"
@@ -16,10 +17,12 @@ import (
 const (
 	modules          = "/proc/modules"
 	conntrackModule  = "nf_conntrack"
+	conntrackAcct    = "/proc/sys/net/netfilter/nf_conntrack_acct"
 	xmlHeader        = "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n"
 	conntrackOpenTag = "<conntrack>\n"
 	timeWait         = "TIME_WAIT"
 	tcpProto         = "tcp"
+	udpProto         = "udp"
 	newType          = "new"
 	updateType       = "update"
 	destroyType      = "destroy"
@@ -38,13 +41,22 @@ type layer4 struct {
 	Proto   string   `xml:"protoname,attr"`
 }
 
+// counters are only present with nf_conntrack_acct enabled, and only in the
+// listing of existing flows and in destroy events.
+type counters struct {
+	XMLName xml.Name `xml:"counters"`
+	Packets uint64   `xml:"packets"`
+	Bytes   uint64   `xml:"bytes"`
+}
+
 type meta struct {
-	XMLName   xml.Name `xml:"meta"`
-	Direction string   `xml:"direction,attr"`
-	Layer3    layer3   `xml:"layer3"`
-	Layer4    layer4   `xml:"layer4"`
-	ID        int64    `xml:"id"`
-	State     string   `xml:"state"`
+	XMLName   xml.Name  `xml:"meta"`
+	Direction string    `xml:"direction,attr"`
+	Layer3    layer3    `xml:"layer3"`
+	Layer4    layer4    `xml:"layer4"`
+	Counters  *counters `xml:"counters"`
+	ID        int64     `xml:"id"`
+	State     string    `xml:"state"`
 }
 
 type flow struct {
@@ -83,7 +95,29 @@ type conntrackWalker struct {
 	quit          chan struct{}
 }
 
-// newConntracker creates and starts a new conntracker.
+// newFlowWalker creates and starts a flowWalker, which talks to conntrack
+// over netlink where it can, and otherwise runs the conntrack command. With
+// anyNAT, it only tracks NAT'd flows.
+func newFlowWalker(useConntrack, anyNAT bool) flowWalker {
+	if !useConntrack {
+		return nilFlowWalker{}
+	} else if !ConntrackModulePresent() {
+		log.Printf("Not using conntrack: module not present")
+		return nilFlowWalker{}
+	}
+	walker, err := newNetlinkFlowWalker(anyNAT)
+	if err == nil {
+		return walker
+	}
+	log.Printf("Not using netlink for conntrack (%v), falling back to the conntrack command", err)
+	args := []string{}
+	if anyNAT {
+		args = append(args, "--any-nat")
+	}
+	return newConntrackFlowWalker(useConntrack, args...)
+}
+
+// newConntrackFlowWalker creates and starts a new conntrackWalker.
 func newConntrackFlowWalker(useConntrack bool, args ...string) flowWalker {
 	if !ConntrackModulePresent() {
 		log.Printf("Not using conntrack: module not present")
@@ -124,6 +158,16 @@ var ConntrackModulePresent = func() bool {
 	return false
 }
 
+// ConntrackAccountingEnabled returns true if conntrack counts the bytes and
+// packets of each flow. It is made public for mocking.
+var ConntrackAccountingEnabled = func() bool {
+	buf, err := ioutil.ReadFile(conntrackAcct)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(buf)) == "1"
+}
+
 func (c *conntrackWalker) loop() {
 	// conntrack can sometimes fail with ENOBUFS, when there is a particularly
 	// high connection rate.  In these cases just retry in a loop, so we can
@@ -175,7 +219,7 @@ func (c *conntrackWalker) run() {
 		c.handleFlow(flow, true)
 	}
 
-	args := append([]string{"-E", "-o", "xml", "-p", "tcp"}, c.args...)
+	args := append([]string{"-E", "-o", "xml"}, c.args...)
 	cmd := exec.Command("conntrack", args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -245,7 +289,7 @@ func (c *conntrackWalker) run() {
 }
 
 func (c *conntrackWalker) existingConnections() ([]flow, error) {
-	args := append([]string{"-L", "-o", "xml", "-p", "tcp"}, c.args...)
+	args := append([]string{"-L", "-o", "xml"}, c.args...)
 	cmd := exec.Command("conntrack", args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -294,10 +338,9 @@ func (c *conntrackWalker) handleFlow(f flow, forceAdd bool) {
 		}
 	}
 
-	// For not, I'm only interested in tcp connections - there is too much udp
-	// traffic going on (every container talking to weave dns, for example) to
-	// render nicely. TODO: revisit this.
-	if f.Original.Layer4.Proto != tcpProto {
+	// We're only interested in TCP and UDP; UDP flows have no state, and go
+	// when conntrack times them out.
+	if f.Original.Layer4.Proto != tcpProto && f.Original.Layer4.Proto != udpProto {
 		return
 	}
 
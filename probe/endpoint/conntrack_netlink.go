@@ -0,0 +1,463 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// Constants from linux/netlink.h, linux/netfilter/nfnetlink.h and
+// linux/netfilter/nfnetlink_conntrack.h.
+const (
+	nlmsgHdrLen = 16
+	nlmsgError  = 2
+	nlmsgDone   = 3
+
+	nlmFRequest = 0x1
+	nlmFExcl    = 0x200
+	nlmFCreate  = 0x400
+	nlmFDump    = 0x300
+
+	nlaFNested       = 0x8000
+	nlaFNetByteorder = 0x4000
+	nlaTypeMask      = ^uint16(nlaFNested | nlaFNetByteorder)
+
+	nfgenmsgLen           = 4
+	nfnlSubsysCTNetlink   = 1
+	ipctnlMsgCTNew        = 0
+	ipctnlMsgCTGet        = 1
+	ipctnlMsgCTDelete     = 2
+	nfNetlinkConntrackNew = 1
+	nfNetlinkConntrackUpd = 2
+	nfNetlinkConntrackDel = 4
+	conntrackEventGroups  = nfNetlinkConntrackNew | nfNetlinkConntrackUpd | nfNetlinkConntrackDel
+
+	ctaTupleOrig  = 1
+	ctaTupleReply = 2
+	ctaStatus     = 3
+	ctaProtoinfo  = 4
+	ctaCountersOr = 9
+	ctaCountersRp = 10
+	ctaID         = 12
+
+	ctaTupleIP     = 1
+	ctaTupleProto  = 2
+	ctaIPv4Src     = 1
+	ctaIPv4Dst     = 2
+	ctaIPv6Src     = 3
+	ctaIPv6Dst     = 4
+	ctaProtoNum    = 1
+	ctaProtoSrcPrt = 2
+	ctaProtoDstPrt = 3
+
+	ctaProtoinfoTCP      = 1
+	ctaProtoinfoTCPState = 1
+
+	ctaCountersPackets = 1
+	ctaCountersBytes   = 2
+
+	ipsSrcNAT = 1 << 4
+	ipsDstNAT = 1 << 5
+
+	afUnspec    = 0
+	afInet      = 2
+	afInet6     = 10
+	ipprotoTCP  = 6
+	ipprotoUDP  = 17
+	nfnetlinkV0 = 0
+)
+
+// accountingInterval is how often we refresh the counters of active flows.
+const accountingInterval = 3 * time.Second
+
+// tcpStates are the names conntrack gives the TCP states, indexed by the
+// values in enum tcp_conntrack.
+var tcpStates = []string{
+	"NONE", "SYN_SENT", "SYN_RECV", "ESTABLISHED", "FIN_WAIT",
+	"CLOSE_WAIT", "LAST_ACK", timeWait, "CLOSE", "SYN_SENT2",
+}
+
+// Netlink headers are in host byte order; the conntrack attributes within
+// them are in network byte order.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+type netlinkMessage struct {
+	Type  uint16
+	Flags uint16
+	Seq   uint32
+	Data  []byte // everything after the header
+}
+
+// netlinkSocket is a netlink socket talking to the conntrack subsystem.
+// Sockets must time out their receives periodically, returning no messages,
+// so we notice when we're stopped.
+type netlinkSocket interface {
+	send([]byte) error
+	receive() ([]netlinkMessage, error)
+	close() error
+}
+
+// newNetlinkSocket opens a netlink socket subscribed to the given multicast
+// groups. It is a variable for mocking, and is set in platform-specific
+// files.
+var newNetlinkSocket func(groups uint32) (netlinkSocket, error)
+
+func align(n int) int {
+	return (n + 3) &^ 3
+}
+
+// parseNetlinkMessages splits a buffer received from a netlink socket into
+// messages.
+func parseNetlinkMessages(buf []byte) ([]netlinkMessage, error) {
+	msgs := []netlinkMessage{}
+	for len(buf) >= nlmsgHdrLen {
+		length := int(nativeEndian.Uint32(buf[0:4]))
+		if length < nlmsgHdrLen || length > len(buf) {
+			return nil, fmt.Errorf("netlink message with invalid length %d", length)
+		}
+		msgs = append(msgs, netlinkMessage{
+			Type:  nativeEndian.Uint16(buf[4:6]),
+			Flags: nativeEndian.Uint16(buf[6:8]),
+			Seq:   nativeEndian.Uint32(buf[8:12]),
+			Data:  buf[nlmsgHdrLen:length],
+		})
+		if align(length) >= len(buf) {
+			break
+		}
+		buf = buf[align(length):]
+	}
+	return msgs, nil
+}
+
+// parseAttributes returns the netlink attributes in buf, by type.
+func parseAttributes(buf []byte) (map[uint16][]byte, error) {
+	attrs := map[uint16][]byte{}
+	for len(buf) >= 4 {
+		length := int(nativeEndian.Uint16(buf[0:2]))
+		if length < 4 || length > len(buf) {
+			return nil, fmt.Errorf("netlink attribute with invalid length %d", length)
+		}
+		attrs[nativeEndian.Uint16(buf[2:4])&nlaTypeMask] = buf[4:length]
+		if align(length) >= len(buf) {
+			break
+		}
+		buf = buf[align(length):]
+	}
+	return attrs, nil
+}
+
+// conntrackDumpRequest asks for all the flows, IPv4 and IPv6, like
+// conntrack -L -f ipv4 and -f ipv6 together.
+func conntrackDumpRequest(seq uint32) []byte {
+	buf := make([]byte, nlmsgHdrLen+nfgenmsgLen)
+	nativeEndian.PutUint32(buf[0:4], uint32(len(buf)))
+	nativeEndian.PutUint16(buf[4:6], nfnlSubsysCTNetlink<<8|ipctnlMsgCTGet)
+	nativeEndian.PutUint16(buf[6:8], nlmFRequest|nlmFDump)
+	nativeEndian.PutUint32(buf[8:12], seq)
+	buf[nlmsgHdrLen] = afUnspec
+	buf[nlmsgHdrLen+1] = nfnetlinkV0
+	return buf
+}
+
+// decodeNetlinkError returns the error in an NLMSG_ERROR message, which is
+// nil for acknowledgements.
+func decodeNetlinkError(msg netlinkMessage) error {
+	if len(msg.Data) < 4 {
+		return fmt.Errorf("truncated netlink error")
+	}
+	if errno := int32(nativeEndian.Uint32(msg.Data[0:4])); errno != 0 {
+		return fmt.Errorf("netlink error %d", -errno)
+	}
+	return nil
+}
+
+// decodeFlow turns a ctnetlink message into a flow, as the conntrack command
+// would have output it. It also returns whether the flow is NAT'd.
+func decodeFlow(msg netlinkMessage) (flow, bool, error) {
+	var f flow
+	if msg.Type>>8 != nfnlSubsysCTNetlink {
+		return f, false, fmt.Errorf("unexpected netlink message type %d", msg.Type)
+	}
+	switch msg.Type & 0xff {
+	case ipctnlMsgCTNew:
+		if msg.Flags&(nlmFCreate|nlmFExcl) != 0 {
+			f.Type = newType
+		} else {
+			f.Type = updateType
+		}
+	case ipctnlMsgCTDelete:
+		f.Type = destroyType
+	default:
+		return f, false, fmt.Errorf("unexpected ctnetlink message type %d", msg.Type&0xff)
+	}
+	if len(msg.Data) < nfgenmsgLen {
+		return f, false, fmt.Errorf("truncated ctnetlink message")
+	}
+	attrs, err := parseAttributes(msg.Data[nfgenmsgLen:])
+	if err != nil {
+		return f, false, err
+	}
+
+	original, err := decodeTuple("original", attrs[ctaTupleOrig])
+	if err != nil {
+		return f, false, err
+	}
+	reply, err := decodeTuple("reply", attrs[ctaTupleReply])
+	if err != nil {
+		return f, false, err
+	}
+	independent := meta{Direction: "independent"}
+	if id := attrs[ctaID]; len(id) == 4 {
+		independent.ID = int64(binary.BigEndian.Uint32(id))
+	}
+	if state, ok := decodeTCPState(attrs[ctaProtoinfo]); ok {
+		independent.State = state
+	}
+	if original.Counters, err = decodeCounters(attrs[ctaCountersOr]); err != nil {
+		return f, false, err
+	}
+	if reply.Counters, err = decodeCounters(attrs[ctaCountersRp]); err != nil {
+		return f, false, err
+	}
+	f.Metas = []meta{original, reply, independent}
+
+	var nat bool
+	if status := attrs[ctaStatus]; len(status) == 4 {
+		nat = binary.BigEndian.Uint32(status)&(ipsSrcNAT|ipsDstNAT) != 0
+	}
+	return f, nat, nil
+}
+
+func decodeTuple(direction string, buf []byte) (meta, error) {
+	m := meta{Direction: direction}
+	tuple, err := parseAttributes(buf)
+	if err != nil {
+		return m, err
+	}
+	ip, err := parseAttributes(tuple[ctaTupleIP])
+	if err != nil {
+		return m, err
+	}
+	if src, ok := ip[ctaIPv4Src]; ok {
+		m.Layer3.SrcIP, m.Layer3.DstIP = net.IP(src).String(), net.IP(ip[ctaIPv4Dst]).String()
+	} else if src, ok := ip[ctaIPv6Src]; ok {
+		m.Layer3.SrcIP, m.Layer3.DstIP = net.IP(src).String(), net.IP(ip[ctaIPv6Dst]).String()
+	}
+	proto, err := parseAttributes(tuple[ctaTupleProto])
+	if err != nil {
+		return m, err
+	}
+	if num := proto[ctaProtoNum]; len(num) == 1 {
+		switch num[0] {
+		case ipprotoTCP:
+			m.Layer4.Proto = tcpProto
+		case ipprotoUDP:
+			m.Layer4.Proto = udpProto
+		default:
+			m.Layer4.Proto = strconv.Itoa(int(num[0]))
+		}
+	}
+	if port := proto[ctaProtoSrcPrt]; len(port) == 2 {
+		m.Layer4.SrcPort = int(binary.BigEndian.Uint16(port))
+	}
+	if port := proto[ctaProtoDstPrt]; len(port) == 2 {
+		m.Layer4.DstPort = int(binary.BigEndian.Uint16(port))
+	}
+	return m, nil
+}
+
+// decodeCounters returns nil if the flow has no counters.
+func decodeCounters(buf []byte) (*counters, error) {
+	if buf == nil {
+		return nil, nil
+	}
+	attrs, err := parseAttributes(buf)
+	if err != nil {
+		return nil, err
+	}
+	c := counters{}
+	if packets := attrs[ctaCountersPackets]; len(packets) == 8 {
+		c.Packets = binary.BigEndian.Uint64(packets)
+	}
+	if bytes := attrs[ctaCountersBytes]; len(bytes) == 8 {
+		c.Bytes = binary.BigEndian.Uint64(bytes)
+	}
+	return &c, nil
+}
+
+func decodeTCPState(buf []byte) (string, bool) {
+	protoinfo, err := parseAttributes(buf)
+	if err != nil {
+		return "", false
+	}
+	tcp, err := parseAttributes(protoinfo[ctaProtoinfoTCP])
+	if err != nil {
+		return "", false
+	}
+	state := tcp[ctaProtoinfoTCPState]
+	if len(state) != 1 || int(state[0]) >= len(tcpStates) {
+		return "", false
+	}
+	return tcpStates[state[0]], true
+}
+
+// netlinkWalker talks to conntrack over netlink to track network connections,
+// rather than running the conntrack command, and implements flowWalker.
+type netlinkWalker struct {
+	conntrackWalker
+	anyNAT     bool // only track NAT'd flows, like conntrack --any-nat
+	accounting bool // dump the flows every accountingInterval, for their counters
+}
+
+// newNetlinkFlowWalker creates and starts a netlinkWalker. It fails if we
+// can't subscribe to conntrack events, e.g. without CAP_NET_ADMIN.
+func newNetlinkFlowWalker(anyNAT bool) (flowWalker, error) {
+	if newNetlinkSocket == nil {
+		return nil, fmt.Errorf("netlink not supported")
+	}
+	events, err := newNetlinkSocket(conntrackEventGroups)
+	if err != nil {
+		return nil, err
+	}
+	result := &netlinkWalker{
+		conntrackWalker: conntrackWalker{
+			activeFlows: map[int64]flow{},
+			quit:        make(chan struct{}),
+		},
+		anyNAT: anyNAT,
+		// Events only carry counters when flows are destroyed, so we dump
+		// the flows periodically to keep the counters of active flows up to
+		// date. The NAT mapper doesn't need them.
+		accounting: !anyNAT && ConntrackAccountingEnabled(),
+	}
+	go result.loop(events)
+	return result, nil
+}
+
+func (w *netlinkWalker) loop(events netlinkSocket) {
+	// As with the conntrack command, we may lose events (ENOBUFS) under high
+	// connection rates; in which case we resubscribe, and dump the table
+	// again.
+	for {
+		if events != nil {
+			w.run(events)
+			events.close()
+			w.clearFlows()
+		}
+
+		select {
+		case <-time.After(time.Second):
+		case <-w.quit:
+			return
+		}
+
+		var err error
+		if events, err = newNetlinkSocket(conntrackEventGroups); err != nil {
+			log.Printf("conntrack netlink error: %v", err)
+			events = nil
+		}
+	}
+}
+
+func (w *netlinkWalker) run(events netlinkSocket) {
+	// We subscribe to events before dumping the existing flows, so we don't
+	// miss any in between.
+	existingFlows, err := w.existingConnections()
+	if err != nil {
+		log.Printf("conntrack netlink existingConnections error: %v", err)
+		return
+	}
+	for _, flow := range existingFlows {
+		w.handleFlow(flow, true)
+	}
+	lastDump := time.Now()
+
+	for {
+		select {
+		case <-w.quit:
+			return
+		default:
+		}
+
+		if w.accounting && time.Since(lastDump) >= accountingInterval {
+			existingFlows, err := w.existingConnections()
+			if err != nil {
+				log.Printf("conntrack netlink existingConnections error: %v", err)
+				return
+			}
+			for _, flow := range existingFlows {
+				w.handleFlow(flow, true)
+			}
+			lastDump = time.Now()
+		}
+
+		msgs, err := events.receive()
+		if err != nil {
+			log.Printf("conntrack netlink error: %v", err)
+			return
+		}
+		for _, msg := range msgs {
+			if f, ok := w.decode(msg); ok {
+				w.handleFlow(f, false)
+			}
+		}
+	}
+}
+
+func (w *netlinkWalker) decode(msg netlinkMessage) (flow, bool) {
+	f, nat, err := decodeFlow(msg)
+	if err != nil {
+		log.Printf("conntrack netlink error: %v", err)
+		return f, false
+	}
+	return f, nat || !w.anyNAT
+}
+
+func (w *netlinkWalker) existingConnections() ([]flow, error) {
+	s, err := newNetlinkSocket(0)
+	if err != nil {
+		return []flow{}, err
+	}
+	defer s.close()
+
+	const seq = 1
+	if err := s.send(conntrackDumpRequest(seq)); err != nil {
+		return []flow{}, err
+	}
+	flows := []flow{}
+	for {
+		msgs, err := s.receive()
+		if err != nil {
+			return []flow{}, err
+		} else if len(msgs) == 0 {
+			return []flow{}, fmt.Errorf("timed out dumping flows")
+		}
+		for _, msg := range msgs {
+			if msg.Seq != seq {
+				continue
+			}
+			switch msg.Type {
+			case nlmsgDone:
+				return flows, nil
+			case nlmsgError:
+				if err := decodeNetlinkError(msg); err != nil {
+					return []flow{}, err
+				}
+				continue
+			}
+			if f, ok := w.decode(msg); ok {
+				flows = append(flows, f)
+			}
+		}
+	}
+}
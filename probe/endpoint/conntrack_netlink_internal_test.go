@@ -0,0 +1,322 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/test"
+)
+
+// A new TCP flow from 10.0.0.1:44444 to 10.0.0.2:80, in state SYN_SENT, with
+// ID 0x01020304, as recorded from a ctnetlink socket (on a little-endian
+// machine).
+const recordedNewFlow = "9c000000000106040000000000000000" + // nlmsghdr
+	"02000000" + // nfgenmsg
+	"34000180" + // CTA_TUPLE_ORIG
+	"14000180" + "08000100" + "0a000001" + "08000200" + "0a000002" +
+	"1c000280" + "05000100" + "06000000" + "06000200" + "ad9c0000" + "06000300" + "00500000" +
+	"34000280" + // CTA_TUPLE_REPLY
+	"14000180" + "08000100" + "0a000002" + "08000200" + "0a000001" +
+	"1c000280" + "05000100" + "06000000" + "06000200" + "00500000" + "06000300" + "ad9c0000" +
+	"08000300" + "00000008" + // CTA_STATUS
+	"10000480" + "0c000180" + "05000100" + "01000000" + // CTA_PROTOINFO
+	"08000c00" + "01020304" // CTA_ID
+
+func TestDecodeRecordedFlow(t *testing.T) {
+	if nativeEndian != binary.LittleEndian {
+		t.Skip("recorded on a little-endian machine")
+	}
+	buf, err := hex.DecodeString(recordedNewFlow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := parseNetlinkMessages(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("want 1 message, have %d", len(msgs))
+	}
+	have, nat, err := decodeFlow(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := makeNetlinkFlow(newType, 0x01020304, "SYN_SENT", "10.0.0.1", "10.0.0.2", 44444, 80)
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+	if nat {
+		t.Error("flow shouldn't be NAT'd")
+	}
+}
+
+func makeNetlinkFlow(ty string, id int64, state, srcIP, dstIP string, srcPort, dstPort int) flow {
+	return flow{
+		Type: ty,
+		Metas: []meta{
+			{
+				Direction: "original",
+				Layer3:    layer3{SrcIP: srcIP, DstIP: dstIP},
+				Layer4:    layer4{SrcPort: srcPort, DstPort: dstPort, Proto: tcpProto},
+			},
+			{
+				Direction: "reply",
+				Layer3:    layer3{SrcIP: dstIP, DstIP: srcIP},
+				Layer4:    layer4{SrcPort: dstPort, DstPort: srcPort, Proto: tcpProto},
+			},
+			{
+				Direction: "independent",
+				ID:        id,
+				State:     state,
+			},
+		},
+	}
+}
+
+func nlattr(ty uint16, payload ...[]byte) []byte {
+	length := 4
+	for _, p := range payload {
+		length += len(p)
+	}
+	buf := make([]byte, 4, align(length))
+	nativeEndian.PutUint16(buf[0:2], uint16(length))
+	nativeEndian.PutUint16(buf[2:4], ty)
+	for _, p := range payload {
+		buf = append(buf, p...)
+	}
+	return buf[:align(length)]
+}
+
+func concat(bufs ...[]byte) []byte {
+	result := []byte{}
+	for _, buf := range bufs {
+		result = append(result, buf...)
+	}
+	return result
+}
+
+func be16(v int) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, uint16(v))
+	return buf
+}
+
+func be64(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return buf
+}
+
+func be32(v uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return buf
+}
+
+// encodeFlow encodes the flow as ctnetlink would.
+func encodeFlow(seq uint32, f flow, status uint32) []byte {
+	family := byte(afInet)
+	tuple := func(ty uint16, m meta) []byte {
+		var ip []byte
+		src, dst := net.ParseIP(m.Layer3.SrcIP), net.ParseIP(m.Layer3.DstIP)
+		if src.To4() != nil {
+			ip = concat(nlattr(ctaIPv4Src, src.To4()), nlattr(ctaIPv4Dst, dst.To4()))
+		} else {
+			family = afInet6
+			ip = concat(nlattr(ctaIPv6Src, src), nlattr(ctaIPv6Dst, dst))
+		}
+		return nlattr(ty|nlaFNested,
+			nlattr(ctaTupleIP|nlaFNested, ip),
+			nlattr(ctaTupleProto|nlaFNested,
+				nlattr(ctaProtoNum, []byte{ipprotoTCP}),
+				nlattr(ctaProtoSrcPrt, be16(m.Layer4.SrcPort)),
+				nlattr(ctaProtoDstPrt, be16(m.Layer4.DstPort))))
+	}
+	state := byte(0)
+	for i, s := range tcpStates {
+		if s == f.Metas[2].State {
+			state = byte(i)
+		}
+	}
+	orig, reply := tuple(ctaTupleOrig, f.Metas[0]), tuple(ctaTupleReply, f.Metas[1])
+	data := concat(
+		[]byte{family, nfnetlinkV0, 0, 0},
+		orig,
+		reply,
+		nlattr(ctaStatus, be32(status)),
+		nlattr(ctaProtoinfo|nlaFNested,
+			nlattr(ctaProtoinfoTCP|nlaFNested,
+				nlattr(ctaProtoinfoTCPState, []byte{state}))),
+		nlattr(ctaID, be32(uint32(f.Metas[2].ID))),
+	)
+	for ty, m := range map[uint16]meta{ctaCountersOr: f.Metas[0], ctaCountersRp: f.Metas[1]} {
+		if m.Counters != nil {
+			data = concat(data, nlattr(ty|nlaFNested,
+				nlattr(ctaCountersPackets, be64(m.Counters.Packets)),
+				nlattr(ctaCountersBytes, be64(m.Counters.Bytes))))
+		}
+	}
+
+	var ty, flags uint16 = ipctnlMsgCTNew, 0
+	switch f.Type {
+	case newType:
+		flags = nlmFCreate | nlmFExcl
+	case destroyType:
+		ty = ipctnlMsgCTDelete
+	}
+	return encodeNetlinkMessage(nfnlSubsysCTNetlink<<8|ty, flags, seq, data)
+}
+
+func encodeNetlinkMessage(ty, flags uint16, seq uint32, data []byte) []byte {
+	buf := make([]byte, nlmsgHdrLen, nlmsgHdrLen+len(data))
+	nativeEndian.PutUint32(buf[0:4], uint32(nlmsgHdrLen+len(data)))
+	nativeEndian.PutUint16(buf[4:6], ty)
+	nativeEndian.PutUint16(buf[6:8], flags)
+	nativeEndian.PutUint32(buf[8:12], seq)
+	return append(buf, data...)
+}
+
+type mockNetlinkSocket struct {
+	messages chan []byte
+	sent     [][]byte
+}
+
+func (s *mockNetlinkSocket) send(msg []byte) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *mockNetlinkSocket) receive() ([]netlinkMessage, error) {
+	select {
+	case buf := <-s.messages:
+		return parseNetlinkMessages(buf)
+	case <-time.After(10 * time.Millisecond):
+		return nil, nil
+	}
+}
+
+func (s *mockNetlinkSocket) close() error { return nil }
+
+func TestNetlinkWalker(t *testing.T) {
+	oldNewSocket := newNetlinkSocket
+	defer func() { newNetlinkSocket = oldNewSocket }()
+
+	var (
+		existing = makeNetlinkFlow("", 1, "ESTABLISHED", "1.2.3.4", "2.3.4.5", 2, 3)
+		natted   = makeNetlinkFlow("", 2, "ESTABLISHED", "1.2.3.4", "2.3.4.5", 4, 5)
+		dump     = &mockNetlinkSocket{messages: make(chan []byte, 1)}
+		events   = &mockNetlinkSocket{messages: make(chan []byte)}
+	)
+	dump.messages <- concat(
+		encodeFlow(1, existing, 0),
+		encodeFlow(1, natted, ipsDstNAT),
+		encodeNetlinkMessage(nlmsgDone, 0, 1, []byte{0, 0, 0, 0}),
+	)
+	newNetlinkSocket = func(groups uint32) (netlinkSocket, error) {
+		if groups == 0 {
+			return dump, nil
+		}
+		return events, nil
+	}
+
+	flowWalker, err := newNetlinkFlowWalker(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flowWalker.stop()
+
+	have := func() interface{} {
+		result := []flow{}
+		flowWalker.walkFlows(func(f flow) {
+			f.Original, f.Reply, f.Independent = nil, nil, nil
+			f.Type = ""
+			result = append(result, f)
+		})
+		return result
+	}
+	ts := 100 * time.Millisecond
+
+	// The existing flows are dumped
+	test.Poll(t, ts, 2, func() interface{} { return len(have().([]flow)) })
+	if !reflect.DeepEqual(dump.sent, [][]byte{conntrackDumpRequest(1)}) {
+		t.Errorf("unexpected dump request: %v", dump.sent)
+	}
+
+	// Flows come and go with events
+	flow1 := makeNetlinkFlow(newType, 3, "SYN_SENT", "1.2.3.4", "2.3.4.5", 6, 7)
+	events.messages <- encodeFlow(0, flow1, 0)
+	test.Poll(t, ts, 3, func() interface{} { return len(have().([]flow)) })
+
+	flow1.Type, flow1.Metas[2].State = updateType, timeWait
+	events.messages <- encodeFlow(0, flow1, 0)
+	test.Poll(t, ts, 3, func() interface{} { return len(have().([]flow)) })
+	test.Poll(t, ts, 2, func() interface{} { return len(have().([]flow)) })
+
+	existing.Type = destroyType
+	events.messages <- encodeFlow(0, existing, 0)
+	existing.Type = ""
+	test.Poll(t, ts, []flow{natted}, func() interface{} {
+		// The destroyed flow is buffered for one walk
+		have()
+		return have()
+	})
+}
+
+func TestNetlinkWalkerNAT(t *testing.T) {
+	walker := &netlinkWalker{anyNAT: true}
+	plain := makeNetlinkFlow(newType, 1, "ESTABLISHED", "1.2.3.4", "2.3.4.5", 2, 3)
+	natted := makeNetlinkFlow(newType, 2, "ESTABLISHED", "1.2.3.4", "2.3.4.5", 4, 5)
+
+	msgs, err := parseNetlinkMessages(concat(encodeFlow(0, plain, 0), encodeFlow(0, natted, ipsSrcNAT)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := walker.decode(msgs[0]); ok {
+		t.Error("flows without NAT should be skipped")
+	}
+	if f, ok := walker.decode(msgs[1]); !ok || !reflect.DeepEqual(natted, f) {
+		t.Errorf("want %v, have %v", natted, f)
+	}
+}
+
+func TestDecodeCounters(t *testing.T) {
+	want := makeNetlinkFlow(destroyType, 1, "CLOSE", "1.2.3.4", "2.3.4.5", 2, 3)
+	want.Metas[0].Counters = &counters{Packets: 3, Bytes: 180}
+	want.Metas[1].Counters = &counters{Packets: 2, Bytes: 120}
+
+	msgs, err := parseNetlinkMessages(encodeFlow(0, want, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, _, err := decodeFlow(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+}
+
+func TestDecodeIPv6Flow(t *testing.T) {
+	want := makeNetlinkFlow(newType, 1, "SYN_SENT", "2001:db8::1", "2001:db8::2", 44444, 80)
+
+	buf := encodeFlow(0, want, 0)
+	if family := buf[nlmsgHdrLen]; family != afInet6 {
+		t.Fatalf("want family %d, have %d", afInet6, family)
+	}
+	msgs, err := parseNetlinkMessages(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, _, err := decodeFlow(msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+}
@@ -0,0 +1,63 @@
+package endpoint
+
+import (
+	"syscall"
+	"time"
+)
+
+const (
+	netlinkReceiveTimeout = 1 * time.Second
+	netlinkReceiveBuffer  = 4 * 1024 * 1024 // so we don't lose events under load
+	netlinkMessageBuffer  = 64 * 1024
+)
+
+func init() {
+	newNetlinkSocket = newLinuxNetlinkSocket
+}
+
+type linuxNetlinkSocket struct {
+	fd  int
+	buf []byte
+}
+
+func newLinuxNetlinkSocket(groups uint32) (netlinkSocket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, err
+	}
+	s := &linuxNetlinkSocket{fd: fd, buf: make([]byte, netlinkMessageBuffer)}
+	timeout := syscall.NsecToTimeval(int64(netlinkReceiveTimeout))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		s.close()
+		return nil, err
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, netlinkReceiveBuffer); err != nil {
+		s.close()
+		return nil, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *linuxNetlinkSocket) send(msg []byte) error {
+	return syscall.Sendto(s.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+func (s *linuxNetlinkSocket) receive() ([]netlinkMessage, error) {
+	n, _, err := syscall.Recvfrom(s.fd, s.buf, 0)
+	if err == syscall.EAGAIN || err == syscall.EINTR {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	copy(buf, s.buf[:n])
+	return parseNetlinkMessages(buf)
+}
+
+func (s *linuxNetlinkSocket) close() error {
+	return syscall.Close(s.fd)
+}
@@ -0,0 +1,149 @@
+package endpoint
+
+import (
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	dnsCacheLen        = 4096
+	dnsCacheExpiration = 30 * time.Minute
+
+	// Errors reading packets back off from dnsInitialBackoff to
+	// dnsMaxBackoff, so persistent ones don't make us spin.
+	dnsInitialBackoff = 10 * time.Millisecond
+	dnsMaxBackoff     = 10 * time.Second
+
+	dnsResponseCodeNoErr layers.DNSResponseCode = 0 // not defined by gopacket
+)
+
+// PacketSource is a source of captured packets, e.g. a pcap handle. Reads
+// should time out every so often, with an error which has a Timeout method
+// returning true (like net.Error), so the snooper can be stopped.
+type PacketSource interface {
+	gopacket.ZeroCopyPacketDataSource
+	LinkType() layers.LinkType
+	Close()
+}
+
+// DNSSnooper learns the names local processes looked up, from the DNS
+// responses they got. Unlike reverse (PTR) lookups, which for cloud services
+// tend to return names like ec2-1-2-3-4.compute.amazonaws.com, these are the
+// names the processes actually connected to, e.g. api.stripe.com.
+type DNSSnooper struct {
+	src   PacketSource
+	cache gcache.Cache // map address -> report.StringSet of names
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// NewDNSSnooper starts snooping on the DNS responses from the source, which
+// should only capture DNS traffic (e.g. with a BPF filter of "udp src port
+// 53") for efficiency. The snooper owns the source, and closes it when it's
+// stopped.
+func NewDNSSnooper(src PacketSource) *DNSSnooper {
+	s := &DNSSnooper{
+		src:   src,
+		cache: gcache.New(dnsCacheLen).LRU().Expiration(dnsCacheExpiration).Build(),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Stop stops the snooper. The source is only closed once the snooper has
+// stopped reading from it, as closing a pcap handle which is being read
+// from can hang.
+func (s *DNSSnooper) Stop() {
+	close(s.quit)
+	<-s.done
+	s.src.Close()
+}
+
+// CachedNamesForIP returns the names which were seen to resolve to the
+// address, if any.
+func (s *DNSSnooper) CachedNamesForIP(address string) report.StringSet {
+	if s == nil {
+		return nil
+	}
+	if names, err := s.cache.Get(address); err == nil {
+		return names.(report.StringSet)
+	}
+	return nil
+}
+
+func (s *DNSSnooper) loop() {
+	defer close(s.done)
+	var (
+		decoder = s.src.LinkType()
+		backoff = dnsInitialBackoff
+	)
+	for {
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+
+		data, _, err := s.src.ZeroCopyReadPacketData()
+		switch {
+		case err == io.EOF:
+			return // done
+		case isTimeout(err):
+			continue
+		case err != nil:
+			log.Printf("DNS snooper: read: %v, backing off %s", err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-s.quit:
+				return
+			}
+			if backoff *= 2; backoff > dnsMaxBackoff {
+				backoff = dnsMaxBackoff
+			}
+			continue
+		}
+		backoff = dnsInitialBackoff
+
+		// DNS traffic is light, so we don't bother with a DecodingLayerParser
+		// for each link type.
+		packet := gopacket.NewPacket(data, decoder, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+		if dns, ok := packet.Layer(layers.LayerTypeDNS).(*layers.DNS); ok {
+			s.record(dns)
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	t, ok := err.(interface {
+		Timeout() bool
+	})
+	return ok && t.Timeout()
+}
+
+// record the names asked for in the response against the addresses they
+// resolved to. If the name is an alias (CNAME), it's the one asked for we're
+// interested in.
+func (s *DNSSnooper) record(dns *layers.DNS) {
+	if !dns.QR || dns.ResponseCode != dnsResponseCodeNoErr || len(dns.Questions) == 0 {
+		return
+	}
+	name := strings.TrimRight(string(dns.Questions[0].Name), ".")
+	for _, answer := range dns.Answers {
+		if (answer.Type != layers.DNSTypeA && answer.Type != layers.DNSTypeAAAA) || answer.IP == nil {
+			continue
+		}
+		address := answer.IP.String()
+		// Sets are shared with the reporter, so we don't add to them in place
+		s.cache.Set(address, report.MakeStringSet(name).Merge(s.CachedNamesForIP(address)))
+	}
+}
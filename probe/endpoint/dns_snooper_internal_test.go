@@ -0,0 +1,203 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+type mockPacketSource struct {
+	packets [][]byte
+}
+
+func (s *mockPacketSource) ZeroCopyReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(s.packets) == 0 {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	data := s.packets[0]
+	s.packets = s.packets[1:]
+	return data, gopacket.CaptureInfo{CaptureLength: len(data), Length: len(data)}, nil
+}
+
+func (s *mockPacketSource) LinkType() layers.LinkType { return layers.LinkTypeEthernet }
+
+func (s *mockPacketSource) Close() {}
+
+// dnsName encodes the name as a sequence of labels.
+func dnsName(name string) []byte {
+	buf := []byte{}
+	for _, label := range strings.Split(name, ".") {
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+// dnsRecord encodes a resource record of the type, with a TTL of a minute.
+func dnsRecord(name []byte, ty layers.DNSType, data []byte) []byte {
+	buf := make([]byte, 10)
+	binary.BigEndian.PutUint16(buf[0:2], uint16(ty))
+	binary.BigEndian.PutUint16(buf[2:4], uint16(layers.DNSClassIN))
+	binary.BigEndian.PutUint32(buf[4:8], 60)
+	binary.BigEndian.PutUint16(buf[8:10], uint16(len(data)))
+	return concat(name, buf, data)
+}
+
+// dnsResponse is a response to a query for name, which is an alias for
+// alias, which resolves to the addresses, as a resolver would send it to
+// the local address 10.0.0.1.
+func dnsResponse(t *testing.T, name, alias string, addrs ...net.IP) []byte {
+	header := []byte{0x12, 0x34, 0x81, 0x80, 0, 1, 0, byte(len(addrs) + 1), 0, 0, 0, 0}
+	question := concat(dnsName(name), []byte{0, byte(layers.DNSTypeA), 0, byte(layers.DNSClassIN)})
+	answers := dnsRecord([]byte{0xc0, 12}, layers.DNSTypeCNAME, dnsName(alias))
+	for _, addr := range addrs {
+		if ip := addr.To4(); ip != nil {
+			answers = concat(answers, dnsRecord(dnsName(alias), layers.DNSTypeA, ip))
+		} else {
+			answers = concat(answers, dnsRecord(dnsName(alias), layers.DNSTypeAAAA, addr))
+		}
+	}
+
+	var (
+		eth = layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+			DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		ip4 = layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolUDP,
+			SrcIP:    net.ParseIP("8.8.8.8"),
+			DstIP:    net.ParseIP("10.0.0.1"),
+		}
+		udp = layers.UDP{SrcPort: 53, DstPort: 44444}
+		buf = gopacket.NewSerializeBuffer()
+	)
+	udp.SetNetworkLayerForChecksum(&ip4)
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		&eth, &ip4, &udp, gopacket.Payload(concat(header, question, answers))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDNSSnooper(t *testing.T) {
+	src := &mockPacketSource{packets: [][]byte{
+		dnsResponse(t, "api.stripe.com", "edge.stripe.example", net.ParseIP("1.2.3.4"), net.ParseIP("2001:db8::4")),
+		dnsResponse(t, "www.stripe.com", "edge.stripe.example", net.ParseIP("1.2.3.4")),
+	}}
+	snooper := NewDNSSnooper(src)
+	defer snooper.Stop()
+
+	test.Poll(t, 100*time.Millisecond, report.MakeStringSet("api.stripe.com", "www.stripe.com"), func() interface{} {
+		return snooper.CachedNamesForIP("1.2.3.4")
+	})
+	if want, have := report.MakeStringSet("api.stripe.com"), snooper.CachedNamesForIP("2001:db8::4"); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if have := snooper.CachedNamesForIP("5.6.7.8"); have != nil {
+		t.Errorf("want no names, have %v", have)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+// erroringPacketSource returns err on every read, after a millisecond.
+type erroringPacketSource struct {
+	err   error
+	reads int32
+}
+
+func (s *erroringPacketSource) ZeroCopyReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	atomic.AddInt32(&s.reads, 1)
+	time.Sleep(time.Millisecond)
+	return nil, gopacket.CaptureInfo{}, s.err
+}
+
+func (s *erroringPacketSource) LinkType() layers.LinkType { return layers.LinkTypeEthernet }
+
+func (s *erroringPacketSource) Close() {}
+
+func TestDNSSnooperStop(t *testing.T) {
+	for _, err := range []error{timeoutError{}, errors.New("read error")} {
+		src := &erroringPacketSource{err: err}
+		snooper := NewDNSSnooper(src)
+		time.Sleep(50 * time.Millisecond)
+
+		stopped := make(chan struct{})
+		go func() {
+			snooper.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("%v: snooper didn't stop", err)
+		}
+
+		// Timeouts are read straight through, but errors back off.
+		reads := atomic.LoadInt32(&src.reads)
+		if _, ok := err.(timeoutError); !ok && reads > 5 {
+			t.Errorf("%v: want backoff, have %d reads", err, reads)
+		}
+	}
+}
+
+func TestReporterSnoopedDNSNames(t *testing.T) {
+	procspy.SetFixtures([]procspy.Connection{
+		{
+			Transport:     procspy.TCP,
+			LocalAddress:  net.ParseIP("10.0.0.1"),
+			LocalPort:     44444,
+			RemoteAddress: net.ParseIP("1.2.3.4"),
+			RemotePort:    443,
+		},
+	})
+	snooper := NewDNSSnooper(&mockPacketSource{packets: [][]byte{
+		dnsResponse(t, "api.stripe.com", "edge.stripe.example", net.ParseIP("1.2.3.4")),
+	}})
+	defer snooper.Stop()
+	test.Poll(t, 100*time.Millisecond, 1, func() interface{} {
+		return len(snooper.CachedNamesForIP("1.2.3.4"))
+	})
+
+	reporter := &Reporter{
+		hostID:           "host",
+		hostName:         "host",
+		includeProcesses: true,
+		flowWalker:       &mockFlowWalker{},
+		natMapper:        makeNATMapper(&mockFlowWalker{}),
+		reverseResolver:  newReverseResolver(),
+		dnsSnooper:       snooper,
+	}
+	defer reporter.Stop()
+
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := report.MakeStringSet("api.stripe.com")
+	if have := rpt.Address.Nodes[report.MakeAddressNodeID("host", "1.2.3.4")].Sets[SnoopedDNSNames]; !reflect.DeepEqual(want, have) {
+		t.Errorf("address: want %v, have %v", want, have)
+	}
+	if have := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host", "1.2.3.4", "443")].Sets[SnoopedDNSNames]; !reflect.DeepEqual(want, have) {
+		t.Errorf("endpoint: want %v, have %v", want, have)
+	}
+}
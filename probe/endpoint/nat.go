@@ -47,14 +47,14 @@ func toMapping(f flow) *endpointMapping {
 }
 
 // applyNAT duplicates Nodes in the endpoint topology of a report, based on
-// the NAT table.
-func (n natMapper) applyNAT(rpt report.Report, scope string) {
+// the NAT table. Addresses are scoped as the reporter does.
+func (n natMapper) applyNAT(rpt report.Report, scope string, scopedNets report.Networks) {
 	n.flowWalker.walkFlows(func(f flow) {
 		var (
 			mapping          = toMapping(f)
-			realEndpointID   = report.MakeEndpointNodeID(scope, mapping.originalIP, strconv.Itoa(mapping.originalPort))
+			realEndpointID   = scopedNets.EndpointNodeID(scope, mapping.originalIP, strconv.Itoa(mapping.originalPort))
 			copyEndpointPort = strconv.Itoa(mapping.rewrittenPort)
-			copyEndpointID   = report.MakeEndpointNodeID(scope, mapping.rewrittenIP, copyEndpointPort)
+			copyEndpointID   = scopedNets.EndpointNodeID(scope, mapping.rewrittenIP, copyEndpointPort)
 			node, ok         = rpt.Endpoint.Nodes[realEndpointID]
 		)
 		if !ok {
@@ -54,7 +54,7 @@ func TestNat(t *testing.T) {
 			"foo":     "bar",
 		}))
 
-		makeNATMapper(ct).applyNAT(have, "host1")
+		makeNATMapper(ct).applyNAT(have, "host1", nil)
 		if !reflect.DeepEqual(want, have) {
 			t.Fatal(test.Diff(want, have))
 		}
@@ -86,7 +86,7 @@ func TestNat(t *testing.T) {
 			"foo":     "baz",
 		}))
 
-		makeNATMapper(ct).applyNAT(have, "host1")
+		makeNATMapper(ct).applyNAT(have, "host1", nil)
 		if !reflect.DeepEqual(want, have) {
 			t.Fatal(test.Diff(want, have))
 		}
@@ -5,6 +5,7 @@ package procspy
 import (
 	"bytes"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"syscall"
 
@@ -21,31 +22,80 @@ func SetProcRoot(root string) {
 	procRoot = root
 }
 
+// netNamespace is a network namespace, and the extent of its TCP and UDP
+// tables in the buffers they were read into. The inode of the probe's own
+// namespace is 0.
+type netNamespace struct {
+	inode            uint64
+	tcpStart, tcpEnd int
+	udpStart, udpEnd int
+}
+
+type netNamespacesByInode []netNamespace
+
+func (s netNamespacesByInode) Len() int           { return len(s) }
+func (s netNamespacesByInode) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s netNamespacesByInode) Less(i, j int) bool { return s[i].inode < s[j].inode }
+
 // walkProcPid walks over all numerical (PID) /proc entries, and sees if their
 // ./fd/* files are symlink to sockets. Returns a map from socket ID (inode)
-// to PID. Will return an error if /proc isn't there.
-func walkProcPid(buf *bytes.Buffer, walker process.Walker) (map[uint64]*Proc, error) {
+// to PID. Will return an error if /proc isn't there. The TCP and UDP sockets
+// of each network namespace are read into tcpBuf and udpBuf, and the
+// namespaces which have any are returned too, ordered by inode (so our own
+// namespace comes first).
+func walkProcPid(tcpBuf, udpBuf *bytes.Buffer, walker process.Walker) (map[uint64]*Proc, []netNamespace, error) {
 	var (
 		res        = map[uint64]*Proc{}
 		namespaces = map[uint64]bool{} // map namespace id -> has connections
+		withConns  = []netNamespace{}
+		selfNS     uint64
 		statT      syscall.Stat_t
 	)
 
+	// Sockets in our own namespace aren't attributed to one
+	if err := fs.Lstat(filepath.Join(procRoot, "self", "ns", "net"), &statT); err == nil {
+		selfNS = statT.Ino
+	}
+
 	walker.Walk(func(p, _ process.Process) {
 		dirName := strconv.Itoa(p.PID)
 		fdBase := filepath.Join(procRoot, dirName, "fd")
 
 		// Read network namespace, and if we haven't seen it before,
-		// read /proc/<pid>/net/tcp
+		// read /proc/<pid>/net/{tcp,udp}
 		if err := fs.Lstat(filepath.Join(procRoot, dirName, "/ns/net"), &statT); err != nil {
 			return
 		}
 		hasConns, ok := namespaces[statT.Ino]
 		if !ok {
-			read, _ := readFile(filepath.Join(procRoot, dirName, "/net/tcp"), buf)
-			read6, _ := readFile(filepath.Join(procRoot, dirName, "/net/tcp6"), buf)
-			hasConns = read+read6 > 0
+			ns := netNamespace{
+				inode:    statT.Ino,
+				tcpStart: tcpBuf.Len(),
+				udpStart: udpBuf.Len(),
+			}
+			if ns.inode == selfNS {
+				ns.inode = 0
+			}
+			read := int64(0)
+			for _, f := range []struct {
+				name string
+				buf  *bytes.Buffer
+			}{
+				{"/net/tcp", tcpBuf},
+				{"/net/tcp6", tcpBuf},
+				{"/net/udp", udpBuf},
+				{"/net/udp6", udpBuf},
+			} {
+				if n, err := readFile(filepath.Join(procRoot, dirName, f.name), f.buf); err == nil {
+					read += n
+				}
+			}
+			ns.tcpEnd, ns.udpEnd = tcpBuf.Len(), udpBuf.Len()
+			hasConns = read > 0
 			namespaces[statT.Ino] = hasConns
+			if hasConns {
+				withConns = append(withConns, ns)
+			}
 		}
 		if !hasConns {
 			return
@@ -78,7 +128,8 @@ func walkProcPid(buf *bytes.Buffer, walker process.Walker) (map[uint64]*Proc, er
 		}
 	})
 
-	return res, nil
+	sort.Sort(netNamespacesByInode(withConns))
+	return res, withConns, nil
 }
 
 // readFile reads an arbitrary file into a buffer. It's a variable so it can
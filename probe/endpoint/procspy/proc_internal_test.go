@@ -3,6 +3,7 @@ package procspy
 import (
 	"bytes"
 	"reflect"
+	"strings"
 	"syscall"
 	"testing"
 
@@ -22,6 +23,34 @@ var mockFS = fs.Dir("",
 						Mode: syscall.S_IFSOCK,
 					},
 				},
+				fs.File{
+					FName: "17",
+					FStat: syscall.Stat_t{
+						Ino:  5108,
+						Mode: syscall.S_IFSOCK,
+					},
+				},
+				fs.File{
+					FName: "18",
+					FStat: syscall.Stat_t{
+						Ino:  5110,
+						Mode: syscall.S_IFSOCK,
+					},
+				},
+				fs.File{
+					FName: "19",
+					FStat: syscall.Stat_t{
+						Ino:  5111,
+						Mode: syscall.S_IFSOCK,
+					},
+				},
+				fs.File{
+					FName: "20",
+					FStat: syscall.Stat_t{
+						Ino:  5109,
+						Mode: syscall.S_IFSOCK,
+					},
+				},
 			),
 			fs.File{
 				FName:     "comm",
@@ -38,6 +67,21 @@ var mockFS = fs.Dir("",
 					FName: "tcp",
 					FContents: `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
    0: 00000000:A6C0 00000000:0000 01 00000000:00000000 00:00000000 00000000   105        0 5107 1 ffff8800a6aaf040 100 0 0 10 2d
+   1: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000   105        0 5110 1 ffff8800a6aaf100 100 0 0 10 0
+`,
+				},
+				fs.File{
+					FName: "tcp6",
+					FContents: `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: B80D0120000000000000000001000000:A6C2 B80D0120000000000000000002000000:0050 01 00000000:00000000 00:00000000 00000000   105        0 5111 1 ffff8800a6aaf140 100 0 0 10 2d
+`,
+				},
+				fs.File{
+					FName: "udp",
+					FContents: `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  120: 0100007F:A6C1 0200007F:0035 01 00000000:00000000 00:00000000 00000000   105        0 5108 2 ffff8800a6aaf080 0
+  121: 00000000:0044 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 5109 2 ffff8800a6aaf0c0 0
+  122: 00000000:0000 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 5112 2 ffff8800a6aaf1c0 0
 `,
 				},
 			),
@@ -46,6 +90,42 @@ var mockFS = fs.Dir("",
 				FContents: "1 na R 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0",
 			},
 		),
+		// A process in a network namespace of its own
+		fs.Dir("2",
+			fs.Dir("fd",
+				fs.File{
+					FName: "3",
+					FStat: syscall.Stat_t{
+						Ino:  5120,
+						Mode: syscall.S_IFSOCK,
+					},
+				},
+			),
+			fs.File{
+				FName:     "comm",
+				FContents: "bar\n",
+			},
+			fs.Dir("ns",
+				fs.File{
+					FName: "net",
+					FStat: syscall.Stat_t{
+						Ino: 4026532001,
+					},
+				},
+			),
+			fs.Dir("net",
+				fs.File{
+					FName: "tcp",
+					FContents: `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:A6C3 0100007F:1F90 01 00000000:00000000 00:00000000 00000000   105        0 5120 1 ffff8800a6aaf180 100 0 0 10 2d
+`,
+				},
+			),
+			fs.File{
+				FName:     "stat",
+				FContents: "2 na R 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0",
+			},
+		),
 	),
 )
 
@@ -53,18 +133,36 @@ func TestWalkProcPid(t *testing.T) {
 	fs_hook.Mock(mockFS)
 	defer fs_hook.Restore()
 
-	buf := bytes.Buffer{}
-	have, err := walkProcPid(&buf, process.NewWalker(procRoot))
+	tcpBuf, udpBuf := bytes.Buffer{}, bytes.Buffer{}
+	have, namespaces, err := walkProcPid(&tcpBuf, &udpBuf, process.NewWalker(procRoot))
 	if err != nil {
 		t.Fatal(err)
 	}
+	foo := &Proc{
+		PID:  1,
+		Name: "foo",
+	}
+	bar := &Proc{
+		PID:  2,
+		Name: "bar",
+	}
 	want := map[uint64]*Proc{
-		5107: {
-			PID:  1,
-			Name: "foo",
-		},
+		5107: foo,
+		5108: foo,
+		5109: foo,
+		5110: foo,
+		5111: foo,
+		5120: bar,
 	}
 	if !reflect.DeepEqual(want, have) {
 		t.Fatalf("%+v", have)
 	}
+
+	// Each namespace's tables are read once
+	if len(namespaces) != 2 || namespaces[0].inode != 0 || namespaces[1].inode != 4026532001 {
+		t.Fatalf("%+v", namespaces)
+	}
+	if have := string(tcpBuf.Bytes()[namespaces[1].tcpStart:namespaces[1].tcpEnd]); !strings.Contains(have, ":A6C3") || strings.Contains(have, ":A6C0") {
+		t.Errorf("unexpected tables for namespace %d: %q", namespaces[1].inode, have)
+	}
 }
@@ -1,5 +1,6 @@
-// Package procspy lists TCP connections, and optionally tries to find the
-// owning processes. Works on Linux (via /proc) and Darwin (via `lsof -i` and
+// Package procspy lists TCP (and, on Linux, connected UDP) connections, and
+// optionally tries to find the owning processes. On Linux, listening TCP and
+// UDP sockets are listed too. Works on Linux (via /proc) and Darwin (via `lsof -i` and
 // `netstat`). You'll need root to use Processes().
 package procspy
 
@@ -10,12 +11,28 @@ import (
 )
 
 const (
-	tcpEstablished = 1 // according to /include/net/tcp_states.h
+	tcpEstablished = 1  // according to /include/net/tcp_states.h
+	tcpClose       = 7  // ditto
+	tcpListen      = 10 // ditto
 )
 
-// Connection is a (TCP) connection. The Proc struct might not be filled in.
+// Transports of connections.
+const (
+	TCP = "tcp"
+	UDP = "udp"
+)
+
+// Connection is a TCP or UDP connection. The Proc struct might not be filled
+// in. UDP sockets are connected if they have a default destination (i.e.
+// the process called connect(2) on them); unconnected UDP sockets bound to a
+// port are listening on it. Listening TCP and UDP sockets are Connections
+// too, with Listening set and no remote address to speak of. On Linux, connections in network namespaces
+// other than the probe's own have NetNamespace set to the namespace's inode,
+// as their addresses (e.g. loopback ones) may overlap with other namespaces'.
 type Connection struct {
-	Transport     string
+	Transport     string // TCP or UDP
+	Listening     bool
+	NetNamespace  uint64
 	LocalAddress  net.IP
 	LocalPort     uint16
 	RemoteAddress net.IP
@@ -35,8 +52,9 @@ type ConnIter interface {
 	Next() *Connection
 }
 
-// Connections returns all established (TCP) connections.  If processes is
-// false we'll just list all TCP connections, and there is no need to be root.
+// Connections returns all established TCP connections, connected UDP
+// sockets, and listening TCP and UDP sockets. If processes is false we'll just list all connections, and there
+// is no need to be root.
 // If processes is true it'll additionally try to lookup the process owning the
 // connection, filling in the Proc field. You will need to run this as root to
 // find all processes.
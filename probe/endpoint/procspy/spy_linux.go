@@ -14,46 +14,107 @@ var bufPool = sync.Pool{
 }
 
 type pnConnIter struct {
-	pn    *ProcNet
-	buf   *bytes.Buffer
-	procs map[uint64]*Proc
+	namespaces     []netNamespace
+	netNamespace   uint64
+	tcp, udp       *ProcNet
+	listen         *ProcNet
+	udpListen      *ProcNet
+	tcpBuf, udpBuf *bytes.Buffer
+	procs          map[uint64]*Proc
 }
 
 func (c *pnConnIter) Next() *Connection {
-	n := c.pn.Next()
-	if n == nil {
-		// Done!
-		bufPool.Put(c.buf)
+	for {
+		if n := c.nextInNamespace(); n != nil {
+			n.NetNamespace = c.netNamespace
+			if proc, ok := c.procs[n.inode]; ok {
+				n.Proc = *proc
+			}
+			return n
+		}
+		if len(c.namespaces) == 0 {
+			// Done!
+			bufPool.Put(c.tcpBuf)
+			bufPool.Put(c.udpBuf)
+			return nil
+		}
+
+		// Connected UDP sockets are in state TCP_ESTABLISHED too, and
+		// unconnected ones in TCP_CLOSE.
+		ns := c.namespaces[0]
+		c.namespaces = c.namespaces[1:]
+		tcp := c.tcpBuf.Bytes()[ns.tcpStart:ns.tcpEnd]
+		udp := c.udpBuf.Bytes()[ns.udpStart:ns.udpEnd]
+		c.netNamespace = ns.inode
+		c.tcp = NewProcNet(tcp, tcpEstablished)
+		c.udp = NewProcNet(udp, tcpEstablished)
+		c.listen = NewProcNet(tcp, tcpListen)
+		c.udpListen = NewProcNet(udp, tcpClose)
+	}
+}
+
+// nextInNamespace returns the next connection in the current namespace, if
+// there is one.
+func (c *pnConnIter) nextInNamespace() *Connection {
+	if c.tcp == nil {
 		return nil
 	}
-	if proc, ok := c.procs[n.inode]; ok {
-		n.Proc = *proc
+	n := c.tcp.Next()
+	if n != nil {
+		n.Transport = TCP
+	} else if n = c.udp.Next(); n != nil {
+		n.Transport = UDP
+	} else if n = c.listen.Next(); n != nil {
+		n.Transport = TCP
+		n.Listening = true
+	} else if n = c.nextUDPListening(); n != nil {
+		n.Transport = UDP
+		n.Listening = true
 	}
 	return n
 }
 
+// nextUDPListening returns the next unconnected UDP socket which is bound to
+// a port, i.e. which can receive datagrams from anyone.
+func (c *pnConnIter) nextUDPListening() *Connection {
+	for {
+		n := c.udpListen.Next()
+		if n == nil || n.LocalPort != 0 {
+			return n
+		}
+	}
+}
+
 // cbConnections sets Connections()
 var cbConnections = func(processes bool, walker process.Walker) (ConnIter, error) {
-	// buffer for contents of /proc/<pid>/net/tcp
-	buf := bufPool.Get().(*bytes.Buffer)
-	buf.Reset()
+	// buffers for contents of /proc/<pid>/net/tcp and /proc/<pid>/net/udp
+	tcpBuf, udpBuf := bufPool.Get().(*bytes.Buffer), bufPool.Get().(*bytes.Buffer)
+	tcpBuf.Reset()
+	udpBuf.Reset()
 
-	var procs map[uint64]*Proc
+	var (
+		procs      map[uint64]*Proc
+		namespaces []netNamespace
+	)
 	if processes {
 		var err error
-		if procs, err = walkProcPid(buf, walker); err != nil {
+		if procs, namespaces, err = walkProcPid(tcpBuf, udpBuf, walker); err != nil {
 			return nil, err
 		}
 	}
 
-	if buf.Len() == 0 {
-		readFile(procRoot+"/net/tcp", buf)
-		readFile(procRoot+"/net/tcp6", buf)
+	if tcpBuf.Len() == 0 && udpBuf.Len() == 0 {
+		readFile(procRoot+"/net/tcp", tcpBuf)
+		readFile(procRoot+"/net/tcp6", tcpBuf)
+		readFile(procRoot+"/net/udp", udpBuf)
+		readFile(procRoot+"/net/udp6", udpBuf)
+		namespaces = []netNamespace{{tcpEnd: tcpBuf.Len(), udpEnd: udpBuf.Len()}}
 	}
 
 	return &pnConnIter{
-		pn:    NewProcNet(buf.Bytes(), tcpEstablished),
-		buf:   buf,
-		procs: procs,
+		namespaces: namespaces,
+		tcpBuf:     tcpBuf,
+		udpBuf:     udpBuf,
+		procs:      procs,
 	}, nil
 }
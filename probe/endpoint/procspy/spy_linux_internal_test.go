@@ -20,6 +20,7 @@ func TestLinuxConnections(t *testing.T) {
 	}
 	have := iter.Next()
 	want := &Connection{
+		Transport:     TCP,
 		LocalAddress:  net.ParseIP("0.0.0.0").To4(),
 		LocalPort:     42688,
 		RemoteAddress: net.ParseIP("0.0.0.0").To4(),
@@ -34,6 +35,100 @@ func TestLinuxConnections(t *testing.T) {
 		t.Fatal(test.Diff(want, have))
 	}
 
+	// IPv6 connections are read from /proc/net/tcp6
+	have = iter.Next()
+	want = &Connection{
+		Transport:     TCP,
+		LocalAddress:  net.ParseIP("2001:db8::1"),
+		LocalPort:     42690,
+		RemoteAddress: net.ParseIP("2001:db8::2"),
+		RemotePort:    80,
+		inode:         5111,
+		Proc: Proc{
+			PID:  1,
+			Name: "foo",
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+
+	// Connected UDP sockets are connections
+	have = iter.Next()
+	want = &Connection{
+		Transport:     UDP,
+		LocalAddress:  net.ParseIP("127.0.0.1").To4(),
+		LocalPort:     42689,
+		RemoteAddress: net.ParseIP("127.0.0.2").To4(),
+		RemotePort:    53,
+		inode:         5108,
+		Proc: Proc{
+			PID:  1,
+			Name: "foo",
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+
+	// Listening TCP sockets come last
+	have = iter.Next()
+	want = &Connection{
+		Transport:     TCP,
+		Listening:     true,
+		LocalAddress:  net.ParseIP("0.0.0.0").To4(),
+		LocalPort:     8080,
+		RemoteAddress: net.ParseIP("0.0.0.0").To4(),
+		RemotePort:    0,
+		inode:         5110,
+		Proc: Proc{
+			PID:  1,
+			Name: "foo",
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+
+	// Then the unconnected UDP sockets bound to a port; unbound ones aren't
+	// listed
+	have = iter.Next()
+	want = &Connection{
+		Transport:     UDP,
+		Listening:     true,
+		LocalAddress:  net.ParseIP("0.0.0.0").To4(),
+		LocalPort:     68,
+		RemoteAddress: net.ParseIP("0.0.0.0").To4(),
+		RemotePort:    0,
+		inode:         5109,
+		Proc: Proc{
+			PID:  1,
+			Name: "foo",
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+
+	// Then the connections in other network namespaces
+	have = iter.Next()
+	want = &Connection{
+		Transport:     TCP,
+		NetNamespace:  4026532001,
+		LocalAddress:  net.ParseIP("127.0.0.1").To4(),
+		LocalPort:     42691,
+		RemoteAddress: net.ParseIP("127.0.0.1").To4(),
+		RemotePort:    8080,
+		inode:         5120,
+		Proc: Proc{
+			PID:  2,
+			Name: "bar",
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+
 	if have := iter.Next(); have != nil {
 		t.Fatal(have)
 	}
@@ -1,6 +1,7 @@
 package endpoint
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
@@ -17,6 +18,9 @@ const (
 	Port        = "port"
 	Conntracked = "conntracked"
 	Procspied   = "procspied"
+	Protocol    = "protocol" // set of the transports (tcp, udp) of the node's connections
+
+	SnoopedDNSNames = "snooped_dns_names" // set of the names local processes resolved the address by
 )
 
 // Reporter generates Reports containing the Endpoint topology.
@@ -25,10 +29,19 @@ type Reporter struct {
 	hostName         string
 	includeProcesses bool
 	includeNAT       bool
-	flowWalker       flowWalker // interface
+	scopedNets       report.Networks // addresses in these are scoped by host
+	flowWalker       flowWalker      // interface
 	procWalker       process.Walker
 	natMapper        natMapper
 	reverseResolver  *reverseResolver
+	dnsSnooper       *DNSSnooper            // may be nil
+	lastCounters     map[int64]flowCounters // by conntrack flow ID, as of the last report
+}
+
+// flowCounters are the counters of a flow in each direction, as conntrack
+// accounts for them.
+type flowCounters struct {
+	original, reply counters
 }
 
 // SpyDuration is an exported prometheus metric
@@ -47,16 +60,21 @@ var SpyDuration = prometheus.NewSummaryVec(
 // generate a report.Report that contains every discovered (spied) connection
 // on the host machine, at the granularity of host and port. That information
 // is stored in the Endpoint topology. It optionally enriches that topology
-// with process (PID) information.
-func NewReporter(hostID, hostName string, includeProcesses bool, useConntrack bool, procWalker process.Walker) *Reporter {
+// with process (PID) information. Addresses in the scoped networks (e.g. the
+// Docker bridge) are local to the host, and scoped by its ID. If the DNS
+// snooper isn't nil, remote addresses are named after what local processes
+// resolved them by.
+func NewReporter(hostID, hostName string, includeProcesses bool, useConntrack bool, scopedNets report.Networks, procWalker process.Walker, dnsSnooper *DNSSnooper) *Reporter {
 	return &Reporter{
 		hostID:           hostID,
 		hostName:         hostName,
 		includeProcesses: includeProcesses,
-		flowWalker:       newConntrackFlowWalker(useConntrack),
-		natMapper:        makeNATMapper(newConntrackFlowWalker(useConntrack, "--any-nat")),
+		scopedNets:       scopedNets,
+		flowWalker:       newFlowWalker(useConntrack, false),
+		natMapper:        makeNATMapper(newFlowWalker(useConntrack, true)),
 		reverseResolver:  newReverseResolver(),
 		procWalker:       procWalker,
+		dnsSnooper:       dnsSnooper,
 	}
 }
 
@@ -88,12 +106,20 @@ func (r *Reporter) Report() (report.Report, error) {
 			Procspied: "true",
 		})
 		for conn := conns.Next(); conn != nil; conn = conns.Next() {
+			if conn.Listening {
+				r.addListeningPort(&rpt, conn)
+				continue
+			}
 			var (
 				localPort  = conn.LocalPort
 				remotePort = conn.RemotePort
 				localAddr  = conn.LocalAddress.String()
 				remoteAddr = conn.RemoteAddress.String()
+				netns      string
 			)
+			if conn.NetNamespace != 0 {
+				netns = strconv.FormatUint(conn.NetNamespace, 10)
+			}
 			extraNodeInfo := commonNodeInfo.Copy()
 			if conn.Proc.PID > 0 {
 				extraNodeInfo = extraNodeInfo.WithMetadata(report.Metadata{
@@ -101,45 +127,120 @@ func (r *Reporter) Report() (report.Report, error) {
 					report.HostNodeID: hostNodeID,
 				})
 			}
-			r.addConnection(&rpt, localAddr, remoteAddr, localPort, remotePort, &extraNodeInfo, &commonNodeInfo)
+			r.addConnection(&rpt, conn.Transport, netns, localAddr, remoteAddr, localPort, remotePort, connectionEdge(conn.Transport), &extraNodeInfo, &commonNodeInfo)
 		}
 	}
 
-	// Consult the flowWalker for short-live connections
+	// Consult the flowWalker for short-live connections, and for the bytes
+	// and packets connections carried since the last report.
 	{
 		extraNodeInfo := report.MakeNode().WithMetadata(report.Metadata{
 			Conntracked: "true",
 		})
+		currentCounters := map[int64]flowCounters{}
 		r.flowWalker.walkFlows(func(f flow) {
 			var (
 				localPort  = uint16(f.Original.Layer4.SrcPort)
 				remotePort = uint16(f.Original.Layer4.DstPort)
 				localAddr  = f.Original.Layer3.SrcIP
 				remoteAddr = f.Original.Layer3.DstIP
+				transport  = f.Original.Layer4.Proto
+				edge       = connectionEdge(transport)
 			)
-			r.addConnection(&rpt, localAddr, remoteAddr, localPort, remotePort, &extraNodeInfo, &extraNodeInfo)
+			if original, reply, ok := r.countersSinceLastReport(f, currentCounters); ok {
+				edge.EgressPacketCount = newu64(original.Packets)
+				edge.EgressByteCount = newu64(original.Bytes)
+				edge.IngressPacketCount = newu64(reply.Packets)
+				edge.IngressByteCount = newu64(reply.Bytes)
+			}
+			r.addConnection(&rpt, transport, "", localAddr, remoteAddr, localPort, remotePort, edge, &extraNodeInfo, &extraNodeInfo)
 		})
+		r.lastCounters = currentCounters
 	}
 
-	r.natMapper.applyNAT(rpt, r.hostID)
+	r.natMapper.applyNAT(rpt, r.hostID, r.scopedNets)
 	return rpt, nil
 }
 
-func (r *Reporter) addConnection(rpt *report.Report, localAddr, remoteAddr string, localPort, remotePort uint16, extraLocalNode, extraRemoteNode *report.Node) {
+// countersSinceLastReport returns the packets and bytes the flow carried in
+// each direction since the last report, if conntrack accounts for them, and
+// records its current counters. We don't know how long the flows we see in
+// the first report have been around, so they only establish a baseline.
+func (r *Reporter) countersSinceLastReport(f flow, current map[int64]flowCounters) (counters, counters, bool) {
+	if f.Original.Counters == nil || f.Reply.Counters == nil {
+		return counters{}, counters{}, false
+	}
+	id := f.Independent.ID
+	current[id] = flowCounters{*f.Original.Counters, *f.Reply.Counters}
+	if r.lastCounters == nil {
+		return counters{}, counters{}, false
+	}
+	last := r.lastCounters[id]
+	return current[id].original.since(last.original), current[id].reply.since(last.reply), true
+}
+
+// since returns the difference between c and an earlier value of the
+// counters. If they went backwards, the flow ID has been reused, and all of
+// c is new.
+func (c counters) since(last counters) counters {
+	if c.Packets < last.Packets || c.Bytes < last.Bytes {
+		return c
+	}
+	return counters{Packets: c.Packets - last.Packets, Bytes: c.Bytes - last.Bytes}
+}
+
+// connectionEdge is the edge metadata for a single connection over the given
+// transport.
+func connectionEdge(transport string) report.EdgeMetadata {
+	if transport == procspy.UDP {
+		return report.EdgeMetadata{
+			MaxConnCountUDP: newu64(1),
+		}
+	}
+	return report.EdgeMetadata{
+		MaxConnCountTCP: newu64(1),
+	}
+}
+
+// addListeningPort records the port a process listens on as a set on its
+// process node, so that services without clients are known too.
+func (r *Reporter) addListeningPort(rpt *report.Report, conn *procspy.Connection) {
+	if conn.Proc.PID == 0 {
+		return
+	}
+	var (
+		pid  = strconv.FormatUint(uint64(conn.Proc.PID), 10)
+		port = fmt.Sprintf("%d/%s", conn.LocalPort, conn.Transport)
+	)
+	rpt.Process = rpt.Process.AddNode(report.MakeProcessNodeID(r.hostID, pid), report.MakeNodeWith(map[string]string{
+		process.PID: pid,
+	}).WithSet(process.ListeningPorts, report.MakeStringSet(port)))
+}
+
+// addConnection adds a connection between the local and remote address and
+// port to the report. The edge metadata is from the point of view of the
+// local end (i.e. egress is from local to remote); edges go from the client
+// to the server. Connections in network namespaces other than the host's
+// have netns set, and their loopback addresses are scoped by it.
+func (r *Reporter) addConnection(rpt *report.Report, transport, netns, localAddr, remoteAddr string, localPort, remotePort uint16, edge report.EdgeMetadata, extraLocalNode, extraRemoteNode *report.Node) {
 	localIsClient := int(localPort) > int(remotePort)
+	if !localIsClient {
+		edge = edge.Reversed()
+	}
+	protocol := report.MakeStringSet(transport)
 
 	// Update address topology
 	{
 		var (
-			localAddressNodeID  = report.MakeAddressNodeID(r.hostID, localAddr)
-			remoteAddressNodeID = report.MakeAddressNodeID(r.hostID, remoteAddr)
+			localAddressNodeID  = r.scopedNets.NetNamespaceAddressNodeID(r.hostID, netns, localAddr)
+			remoteAddressNodeID = r.scopedNets.NetNamespaceAddressNodeID(r.hostID, netns, remoteAddr)
 			localNode           = report.MakeNodeWith(map[string]string{
 				"name": r.hostName,
 				Addr:   localAddr,
-			})
+			}).WithSet(Protocol, protocol)
 			remoteNode = report.MakeNodeWith(map[string]string{
 				Addr: remoteAddr,
-			})
+			}).WithSet(Protocol, protocol)
 		)
 
 		// In case we have a reverse resolution for the IP, we can use it for
@@ -147,17 +248,16 @@ func (r *Reporter) addConnection(rpt *report.Report, localAddr, remoteAddr strin
 		if remoteNames, err := r.reverseResolver.get(remoteAddr); err == nil {
 			remoteNode = remoteNode.WithSet("name", report.MakeStringSet(remoteNames...))
 		}
+		if snoopedNames := r.dnsSnooper.CachedNamesForIP(remoteAddr); len(snoopedNames) > 0 {
+			remoteNode = remoteNode.WithSet(SnoopedDNSNames, snoopedNames)
+		}
 
 		if localIsClient {
 			// New nodes are merged into the report so we don't need to do any
 			// counting here; the merge does it for us.
-			localNode = localNode.WithEdge(remoteAddressNodeID, report.EdgeMetadata{
-				MaxConnCountTCP: newu64(1),
-			})
+			localNode = localNode.WithEdge(remoteAddressNodeID, edge.Copy())
 		} else {
-			remoteNode = localNode.WithEdge(localAddressNodeID, report.EdgeMetadata{
-				MaxConnCountTCP: newu64(1),
-			})
+			remoteNode = localNode.WithEdge(localAddressNodeID, edge.Copy())
 		}
 
 		if extraLocalNode != nil {
@@ -173,17 +273,17 @@ func (r *Reporter) addConnection(rpt *report.Report, localAddr, remoteAddr strin
 	// Update endpoint topology
 	if r.includeProcesses {
 		var (
-			localEndpointNodeID  = report.MakeEndpointNodeID(r.hostID, localAddr, strconv.Itoa(int(localPort)))
-			remoteEndpointNodeID = report.MakeEndpointNodeID(r.hostID, remoteAddr, strconv.Itoa(int(remotePort)))
+			localEndpointNodeID  = r.scopedNets.NetNamespaceEndpointNodeID(r.hostID, netns, localAddr, strconv.Itoa(int(localPort)))
+			remoteEndpointNodeID = r.scopedNets.NetNamespaceEndpointNodeID(r.hostID, netns, remoteAddr, strconv.Itoa(int(remotePort)))
 
 			localNode = report.MakeNodeWith(map[string]string{
 				Addr: localAddr,
 				Port: strconv.Itoa(int(localPort)),
-			})
+			}).WithSet(Protocol, protocol)
 			remoteNode = report.MakeNodeWith(map[string]string{
 				Addr: remoteAddr,
 				Port: strconv.Itoa(int(remotePort)),
-			})
+			}).WithSet(Protocol, protocol)
 		)
 
 		// In case we have a reverse resolution for the IP, we can use it for
@@ -191,17 +291,16 @@ func (r *Reporter) addConnection(rpt *report.Report, localAddr, remoteAddr strin
 		if remoteNames, err := r.reverseResolver.get(remoteAddr); err == nil {
 			remoteNode = remoteNode.WithSet("name", report.MakeStringSet(remoteNames...))
 		}
+		if snoopedNames := r.dnsSnooper.CachedNamesForIP(remoteAddr); len(snoopedNames) > 0 {
+			remoteNode = remoteNode.WithSet(SnoopedDNSNames, snoopedNames)
+		}
 
 		if localIsClient {
 			// New nodes are merged into the report so we don't need to do any
 			// counting here; the merge does it for us.
-			localNode = localNode.WithEdge(remoteEndpointNodeID, report.EdgeMetadata{
-				MaxConnCountTCP: newu64(1),
-			})
+			localNode = localNode.WithEdge(remoteEndpointNodeID, edge.Copy())
 		} else {
-			remoteNode = remoteNode.WithEdge(localEndpointNodeID, report.EdgeMetadata{
-				MaxConnCountTCP: newu64(1),
-			})
+			remoteNode = remoteNode.WithEdge(localEndpointNodeID, edge.Copy())
 		}
 
 		if extraLocalNode != nil {
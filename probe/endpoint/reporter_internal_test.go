@@ -0,0 +1,78 @@
+package endpoint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestConntrackCounters(t *testing.T) {
+	procspy.SetFixtures([]procspy.Connection{})
+
+	makeFlow := func(id int64, srcPort int, original, reply counters) flow {
+		f := makeNetlinkFlow(updateType, id, "ESTABLISHED", "10.0.0.1", "10.0.0.2", srcPort, 80)
+		f.Metas[0].Counters, f.Metas[1].Counters = &original, &reply
+		f.Original, f.Reply, f.Independent = &f.Metas[0], &f.Metas[1], &f.Metas[2]
+		return f
+	}
+	var (
+		walker   = &mockFlowWalker{}
+		reporter = &Reporter{
+			hostID:           "host",
+			hostName:         "host",
+			includeProcesses: true,
+			flowWalker:       walker,
+			natMapper:        makeNATMapper(&mockFlowWalker{}),
+			reverseResolver:  newReverseResolver(),
+		}
+		localAddress   = report.MakeAddressNodeID("host", "10.0.0.1")
+		remoteAddress  = report.MakeAddressNodeID("host", "10.0.0.2")
+		localEndpoint  = report.MakeEndpointNodeID("host", "10.0.0.1", "44444")
+		remoteEndpoint = report.MakeEndpointNodeID("host", "10.0.0.2", "80")
+	)
+	defer reporter.Stop()
+
+	// The first report only establishes a baseline
+	walker.flows = []flow{makeFlow(1, 44444, counters{Packets: 10, Bytes: 1000}, counters{Packets: 8, Bytes: 5000})}
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := connectionEdge(procspy.TCP)
+	if have := rpt.Address.Nodes[localAddress].Edges[remoteAddress]; !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+
+	// Then we get the deltas; new flows count in full
+	walker.flows = []flow{
+		makeFlow(1, 44444, counters{Packets: 15, Bytes: 1500}, counters{Packets: 12, Bytes: 9000}),
+		makeFlow(2, 44445, counters{Packets: 1, Bytes: 100}, counters{Packets: 1, Bytes: 200}),
+	}
+	rpt, err = reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = report.EdgeMetadata{
+		EgressPacketCount:  newu64(6),
+		EgressByteCount:    newu64(600),
+		IngressPacketCount: newu64(5),
+		IngressByteCount:   newu64(4200),
+		MaxConnCountTCP:    newu64(1),
+	}
+	if have := rpt.Address.Nodes[localAddress].Edges[remoteAddress]; !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+	want = report.EdgeMetadata{
+		EgressPacketCount:  newu64(5),
+		EgressByteCount:    newu64(500),
+		IngressPacketCount: newu64(4),
+		IngressByteCount:   newu64(4000),
+		MaxConnCountTCP:    newu64(1),
+	}
+	if have := rpt.Endpoint.Nodes[localEndpoint].Edges[remoteEndpoint]; !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+}
@@ -2,12 +2,14 @@ package endpoint_test
 
 import (
 	"net"
+	"reflect"
 	"strconv"
 	"testing"
 
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -71,7 +73,7 @@ func TestSpyNoProcesses(t *testing.T) {
 		nodeName = "frenchs-since-1904"   // TODO rename to hostNmae
 	)
 
-	reporter := endpoint.NewReporter(nodeID, nodeName, false, false, nil)
+	reporter := endpoint.NewReporter(nodeID, nodeName, false, false, nil, nil, nil)
 	r, _ := reporter.Report()
 	//buf, _ := json.MarshalIndent(r, "", "    ")
 	//t.Logf("\n%s\n", buf)
@@ -107,7 +109,7 @@ func TestSpyWithProcesses(t *testing.T) {
 		nodeName = "fishermans-friend" // TODO rename to hostNmae
 	)
 
-	reporter := endpoint.NewReporter(nodeID, nodeName, true, false, nil)
+	reporter := endpoint.NewReporter(nodeID, nodeName, true, false, nil, nil, nil)
 	r, _ := reporter.Report()
 	// buf, _ := json.MarshalIndent(r, "", "    ") ; t.Logf("\n%s\n", buf)
 
@@ -132,3 +134,112 @@ func TestSpyWithProcesses(t *testing.T) {
 		}
 	}
 }
+
+func TestSpyUDP(t *testing.T) {
+	procspy.SetFixtures([]procspy.Connection{
+		{
+			Transport:     procspy.UDP,
+			LocalAddress:  fixLocalAddress,
+			LocalPort:     fixRemotePort,
+			RemoteAddress: fixRemoteAddress,
+			RemotePort:    53,
+		},
+	})
+
+	const nodeID = "heinz-tomato-ketchup"
+	reporter := endpoint.NewReporter(nodeID, "frenchs-since-1904", true, false, nil, nil, nil)
+	r, _ := reporter.Report()
+
+	var (
+		scopedLocal  = report.MakeEndpointNodeID(nodeID, fixLocalAddress.String(), strconv.Itoa(int(fixRemotePort)))
+		scopedRemote = report.MakeEndpointNodeID(nodeID, fixRemoteAddress.String(), "53")
+		edge         = r.Endpoint.Nodes[scopedLocal].Edges[scopedRemote]
+	)
+	if edge.MaxConnCountUDP == nil || *edge.MaxConnCountUDP != 1 || edge.MaxConnCountTCP != nil {
+		t.Fatalf("want one UDP connection, have %+v", edge)
+	}
+	for _, id := range []string{scopedLocal, scopedRemote} {
+		if want, have := report.MakeStringSet(procspy.UDP), r.Endpoint.Nodes[id].Sets[endpoint.Protocol]; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: want protocols %v, have %v", id, want, have)
+		}
+	}
+}
+
+func TestSpyListening(t *testing.T) {
+	procspy.SetFixtures([]procspy.Connection{
+		{
+			Transport:     procspy.TCP,
+			Listening:     true,
+			LocalAddress:  net.IPv4zero,
+			LocalPort:     8080,
+			RemoteAddress: net.IPv4zero,
+			Proc:          procspy.Proc{PID: fixProcessPID, Name: "nginx"},
+		},
+		{
+			Transport:     procspy.TCP,
+			Listening:     true,
+			LocalAddress:  net.IPv4zero,
+			LocalPort:     8443,
+			RemoteAddress: net.IPv4zero,
+			Proc:          procspy.Proc{PID: fixProcessPID, Name: "nginx"},
+		},
+		{
+			Transport:     procspy.UDP,
+			Listening:     true,
+			LocalAddress:  net.IPv4zero,
+			LocalPort:     8443,
+			RemoteAddress: net.IPv4zero,
+			Proc:          procspy.Proc{PID: fixProcessPID, Name: "nginx"},
+		},
+	})
+
+	const nodeID = "heinz-tomato-ketchup"
+	reporter := endpoint.NewReporter(nodeID, "frenchs-since-1904", true, false, nil, nil, nil)
+	r, _ := reporter.Report()
+
+	// Listening sockets aren't connections
+	if want, have := 0, len(r.Endpoint.Nodes)+len(r.Address.Nodes); want != have {
+		t.Fatalf("want %d endpoint and address nodes, have %d", want, have)
+	}
+
+	pid := strconv.FormatUint(uint64(fixProcessPID), 10)
+	node := r.Process.Nodes[report.MakeProcessNodeID(nodeID, pid)]
+	if want, have := report.MakeStringSet("8080/tcp", "8443/tcp", "8443/udp"), node.Sets[process.ListeningPorts]; !reflect.DeepEqual(want, have) {
+		t.Errorf("want listening ports %v, have %v", want, have)
+	}
+	if want, have := pid, node.Metadata[process.PID]; want != have {
+		t.Errorf("want pid %q, have %q", want, have)
+	}
+}
+
+func TestSpyNetNamespaces(t *testing.T) {
+	// The same loopback connection, in the host's namespace and in another
+	loopback := func(netns uint64) procspy.Connection {
+		return procspy.Connection{
+			Transport:     procspy.TCP,
+			NetNamespace:  netns,
+			LocalAddress:  net.ParseIP("127.0.0.1"),
+			LocalPort:     44444,
+			RemoteAddress: net.ParseIP("127.0.0.1"),
+			RemotePort:    80,
+			Proc:          procspy.Proc{PID: fixProcessPID, Name: "curl"},
+		}
+	}
+	procspy.SetFixtures([]procspy.Connection{loopback(0), loopback(4026532001)})
+
+	const nodeID = "heinz-tomato-ketchup"
+	reporter := endpoint.NewReporter(nodeID, "frenchs-since-1904", true, false, nil, nil, nil)
+	r, _ := reporter.Report()
+
+	for _, id := range []string{
+		report.MakeEndpointNodeID(nodeID, "127.0.0.1", "44444"),
+		report.MakeScopedEndpointNodeID(report.MakeNetNamespaceScope(nodeID, "4026532001"), "127.0.0.1", "44444"),
+	} {
+		if _, ok := r.Endpoint.Nodes[id]; !ok {
+			t.Errorf("want endpoint %s, have %v", id, r.Endpoint.Nodes)
+		}
+	}
+	if want, have := 4, len(r.Endpoint.Nodes); want != have {
+		t.Errorf("want %d endpoint nodes, have %d", want, have)
+	}
+}
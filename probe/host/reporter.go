@@ -10,17 +10,18 @@ import (
 
 // Keys for use in Node.Metadata.
 const (
-	Timestamp     = "ts"
-	HostName      = "host_name"
-	LocalNetworks = "local_networks"
-	OS            = "os"
-	KernelVersion = "kernel_version"
-	Uptime        = "uptime"
-	Load1         = "load1"
-	Load5         = "load5"
-	Load15        = "load15"
-	CPUUsage      = "cpu_usage_percent"
-	MemUsage      = "mem_usage_bytes"
+	Timestamp      = "ts"
+	HostName       = "host_name"
+	LocalNetworks  = "local_networks"
+	ScopedNetworks = "scoped_networks" // local networks whose addresses are scoped by host
+	OS             = "os"
+	KernelVersion  = "kernel_version"
+	Uptime         = "uptime"
+	Load1          = "load1"
+	Load5          = "load5"
+	Load15         = "load15"
+	CPUUsage       = "cpu_usage_percent"
+	MemUsage       = "mem_usage_bytes"
 )
 
 // Exposed for testing.
@@ -33,18 +34,22 @@ const (
 
 // Reporter generates Reports containing the host topology.
 type Reporter struct {
-	hostID    string
-	hostName  string
-	localNets report.Networks
+	hostID     string
+	hostName   string
+	localNets  report.Networks
+	scopedNets report.Networks
 }
 
 // NewReporter returns a Reporter which produces a report containing host
-// topology for this host.
-func NewReporter(hostID, hostName string, localNets report.Networks) *Reporter {
+// topology for this host. The scoped networks are those of the local
+// networks whose addresses are only meaningful on this host, so the probe
+// scopes them by host ID.
+func NewReporter(hostID, hostName string, localNets, scopedNets report.Networks) *Reporter {
 	return &Reporter{
-		hostID:    hostID,
-		hostName:  hostName,
-		localNets: localNets,
+		hostID:     hostID,
+		hostName:   hostName,
+		localNets:  localNets,
+		scopedNets: scopedNets,
 	}
 }
 
@@ -53,14 +58,7 @@ func (Reporter) Name() string { return "Host" }
 
 // Report implements Reporter.
 func (r *Reporter) Report() (report.Report, error) {
-	var (
-		rep        = report.MakeReport()
-		localCIDRs []string
-	)
-
-	for _, localNet := range r.localNets {
-		localCIDRs = append(localCIDRs, localNet.String())
-	}
+	rep := report.MakeReport()
 
 	uptime, err := GetUptime()
 	if err != nil {
@@ -86,7 +84,8 @@ func (r *Reporter) Report() (report.Report, error) {
 		KernelVersion: kernel,
 		Uptime:        uptime.String(),
 	}).WithSets(report.Sets{
-		LocalNetworks: report.MakeStringSet(localCIDRs...),
+		LocalNetworks:  report.MakeStringSet(r.localNets.Strings()...),
+		ScopedNetworks: report.MakeStringSet(r.scopedNets.Strings()...),
 	}).WithMetrics(metrics))
 
 	return rep, nil
@@ -32,6 +32,7 @@ func TestReporter(t *testing.T) {
 		kernel      = "release version"
 		_, ipnet, _ = net.ParseCIDR(network)
 		localNets   = report.Networks([]*net.IPNet{ipnet})
+		scopedNets  = report.ParseNetworks("172.17.0.0/16", "fd00:dead:beef::/64")
 	)
 
 	mtime.NowForce(timestamp)
@@ -65,9 +66,10 @@ func TestReporter(t *testing.T) {
 		host.Uptime:        uptime,
 		host.KernelVersion: kernel,
 	}).WithSets(report.Sets{
-		host.LocalNetworks: report.MakeStringSet(network),
+		host.LocalNetworks:  report.MakeStringSet(network),
+		host.ScopedNetworks: report.MakeStringSet("172.17.0.0/16", "fd00:dead:beef::/64"),
 	}).WithMetrics(load))
-	have, _ := host.NewReporter(hostID, hostname, localNets).Report()
+	have, _ := host.NewReporter(hostID, hostname, localNets, scopedNets).Report()
 	if !reflect.DeepEqual(want, have) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
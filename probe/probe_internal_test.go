@@ -2,8 +2,8 @@ package probe
 
 import (
 	"compress/gzip"
-	"encoding/gob"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"testing"
 	"time"
@@ -54,16 +54,20 @@ func (mockReporter) Name() string { return "Mock" }
 
 type mockPublisher struct {
 	have chan report.Report
+	last *report.Report
 }
 
 func (m mockPublisher) Publish(in io.Reader) error {
-	var r report.Report
+	var d report.Delta
 	if reader, err := gzip.NewReader(in); err != nil {
 		return err
-	} else if err := gob.NewDecoder(reader).Decode(&r); err != nil {
+	} else if buf, err := ioutil.ReadAll(reader); err != nil {
+		return err
+	} else if err := d.UnmarshalProto(buf); err != nil {
 		return err
 	}
-	m.have <- r
+	*m.last = m.last.Apply(d)
+	m.have <- *m.last
 	return nil
 }
 
@@ -75,7 +79,8 @@ func TestProbe(t *testing.T) {
 	want := report.MakeReport()
 	node := report.MakeNodeWith(map[string]string{"b": "c"})
 	want.Endpoint.AddNode("a", node)
-	pub := mockPublisher{make(chan report.Report)}
+	last := report.MakeReport()
+	pub := mockPublisher{make(chan report.Report), &last}
 
 	p := New(10*time.Millisecond, 100*time.Millisecond, pub)
 	p.AddReporter(mockReporter{want})
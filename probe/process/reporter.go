@@ -16,6 +16,10 @@ const (
 	Threads     = "threads"
 	CPUUsage    = "cpu_usage_percent"
 	MemoryUsage = "memory_usage_bytes"
+
+	// ListeningPorts is the set of ports (e.g. "8080/tcp") the process
+	// listens on. The endpoint reporter finds them.
+	ListeningPorts = "listening_ports"
 )
 
 // Reporter generates Reports containing the Process topology.
@@ -0,0 +1,274 @@
+package sniff
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// httpPendingTimeout is how long we wait for the response to a request
+	// before we forget about it.
+	httpPendingTimeout = time.Minute
+
+	// maxHTTPPending bounds the number of requests we remember per
+	// connection, e.g. when we don't see the responses.
+	maxHTTPPending = 64
+
+	// httpDuplicateWindow is how long we remember the segments we've
+	// counted, to ignore the other copies of them we capture.
+	httpDuplicateWindow = 10 * time.Second
+)
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("DELETE "),
+	[]byte("HEAD "), []byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+// httpConn identifies a TCP connection from an HTTP client to a server.
+type httpConn struct {
+	clientIP, serverIP     string
+	clientPort, serverPort uint16
+}
+
+// httpClient identifies the client end of a TCP connection. Unlike the
+// server end, it's the same in all the copies of the connection's packets we
+// capture, e.g. before and after the DNAT to a container's address.
+type httpClient struct {
+	ip   string
+	port uint16
+}
+
+// httpSegment identifies the TCP segment starting a request or response.
+// Capturing on all interfaces, we see a segment once on the container's
+// veth, again on the bridge, and again after NAT; we only count it once.
+type httpSegment struct {
+	client   httpClient
+	seq      uint32
+	response bool
+}
+
+// httpRequest is a request awaiting its response.
+type httpRequest struct {
+	conn httpConn
+	ts   time.Time // capture time
+}
+
+// HTTPReporter is a reporter which parses the HTTP/1.x requests and
+// responses it sees on the selected server ports. It puts request counts,
+// response status classes and latencies on the edges between the client
+// and server endpoints. Unlike the Sniffer, it doesn't sample, as it needs
+// to see the request and the response to know the latency.
+type HTTPReporter struct {
+	hostID     string
+	scopedNets report.Networks
+	ports      map[uint16]struct{}
+	quit       chan struct{}
+
+	parser  *gopacket.DecodingLayerParser
+	decoded []gopacket.LayerType
+	eth     layers.Ethernet
+	sll     layers.LinuxSLL
+	ip4     layers.IPv4
+	ip6     layers.IPv6
+	tcp     layers.TCP
+
+	mtx     sync.Mutex
+	rpt     report.Report
+	pending map[httpClient][]httpRequest
+	seen    map[httpSegment]time.Time // capture times of the segments we counted
+	latest  time.Time                 // capture time of the latest packet
+}
+
+// NewHTTPReporter returns a new HTTP reporter, which parses the packets from
+// the source until it's done. Servers are on the passed ports; it's best to
+// capture only the traffic to and from those (e.g. with a BPF filter of "tcp
+// port 80 or tcp port 8080"). Addresses in the scoped networks are scoped by
+// host, as the endpoint reporter does.
+func NewHTTPReporter(hostID string, scopedNets report.Networks, src Source, ports []uint16) *HTTPReporter {
+	r := &HTTPReporter{
+		hostID:     hostID,
+		scopedNets: scopedNets,
+		ports:      map[uint16]struct{}{},
+		quit:       make(chan struct{}),
+		rpt:        report.MakeReport(),
+		pending:    map[httpClient][]httpRequest{},
+		seen:       map[httpSegment]time.Time{},
+	}
+	for _, port := range ports {
+		r.ports[port] = struct{}{}
+	}
+	first := layers.LayerTypeEthernet
+	if src.LinkType() == layers.LinkTypeLinuxSLL {
+		first = layers.LayerTypeLinuxSLL // e.g. capturing on "any"
+	}
+	r.parser = gopacket.NewDecodingLayerParser(first, &r.eth, &r.sll, &r.ip4, &r.ip6, &r.tcp)
+	go r.loop(src)
+	return r
+}
+
+// Name implements the Reporter interface.
+func (*HTTPReporter) Name() string { return "HTTP" }
+
+// Stop stops the reporter. It doesn't close the source.
+func (r *HTTPReporter) Stop() {
+	close(r.quit)
+}
+
+// Report implements the Reporter interface. Requests are only ever reported
+// once.
+func (r *HTTPReporter) Report() (report.Report, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	rpt := r.rpt
+	r.rpt = report.MakeReport()
+
+	// Forget requests whose responses we'll never see
+	for client, requests := range r.pending {
+		for len(requests) > 0 && r.latest.Sub(requests[0].ts) > httpPendingTimeout {
+			requests = requests[1:]
+		}
+		if len(requests) == 0 {
+			delete(r.pending, client)
+		} else {
+			r.pending[client] = requests
+		}
+	}
+	for segment, ts := range r.seen {
+		if r.latest.Sub(ts) > httpDuplicateWindow {
+			delete(r.seen, segment)
+		}
+	}
+	return rpt, nil
+}
+
+func (r *HTTPReporter) loop(src gopacket.ZeroCopyPacketDataSource) {
+	for {
+		select {
+		case <-r.quit:
+			return
+		default:
+		}
+
+		data, ci, err := src.ZeroCopyReadPacketData()
+		if err == io.EOF {
+			return // done
+		}
+		if err != nil {
+			log.Printf("HTTP reporter: read: %v", err)
+			continue
+		}
+		if err := r.parser.DecodeLayers(data, &r.decoded); err != nil {
+			// We'll always get an error for the TCP payload, as we haven't
+			// configured a decoder for it.
+		}
+		var srcIP, dstIP string
+		for _, t := range r.decoded {
+			switch t {
+			case layers.LayerTypeIPv4:
+				srcIP, dstIP = r.ip4.SrcIP.String(), r.ip4.DstIP.String()
+			case layers.LayerTypeIPv6:
+				srcIP, dstIP = r.ip6.SrcIP.String(), r.ip6.DstIP.String()
+			case layers.LayerTypeTCP:
+				if srcIP != "" && len(r.tcp.Payload) > 0 {
+					r.handle(srcIP, dstIP, uint16(r.tcp.SrcPort), uint16(r.tcp.DstPort), r.tcp.Seq, r.tcp.Payload, ci.Timestamp)
+				}
+			}
+		}
+	}
+}
+
+// handle a TCP segment, which starts a request if it's to one of the ports,
+// or a response if it's from one. Other segments are the rest of the headers
+// and the bodies of the requests and responses, which we don't care about.
+// Responses are put on the edge of their request, whichever copy of it we
+// saw first.
+func (r *HTTPReporter) handle(srcIP, dstIP string, srcPort, dstPort uint16, seq uint32, payload []byte, ts time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if ts.After(r.latest) {
+		r.latest = ts
+	}
+
+	if _, ok := r.ports[dstPort]; ok && isHTTPRequest(payload) {
+		client := httpClient{ip: srcIP, port: srcPort}
+		if r.duplicate(httpSegment{client: client, seq: seq}, ts) {
+			return
+		}
+		conn := httpConn{clientIP: srcIP, clientPort: srcPort, serverIP: dstIP, serverPort: dstPort}
+		if len(r.pending[client]) < maxHTTPPending {
+			r.pending[client] = append(r.pending[client], httpRequest{conn: conn, ts: ts})
+		}
+		r.addEdge(conn, report.EdgeMetadata{}.WithHTTPRequest())
+		return
+	}
+
+	if _, ok := r.ports[srcPort]; ok {
+		code, ok := httpStatusCode(payload)
+		if !ok {
+			return
+		}
+		client := httpClient{ip: dstIP, port: dstPort}
+		if r.duplicate(httpSegment{client: client, seq: seq, response: true}, ts) {
+			return
+		}
+		conn := httpConn{clientIP: dstIP, clientPort: dstPort, serverIP: srcIP, serverPort: srcPort}
+		latency := time.Duration(-1)
+		if requests := r.pending[client]; len(requests) > 0 {
+			// HTTP/1.x responses are in the order of the requests
+			conn, latency = requests[0].conn, ts.Sub(requests[0].ts)
+			r.pending[client] = requests[1:]
+		}
+		r.addEdge(conn, report.EdgeMetadata{}.WithHTTPResponse(code, latency))
+	}
+}
+
+// duplicate is true if we've already seen the segment, and otherwise
+// remembers it. Must be called with the lock held.
+func (r *HTTPReporter) duplicate(segment httpSegment, ts time.Time) bool {
+	if _, ok := r.seen[segment]; ok {
+		return true
+	}
+	r.seen[segment] = ts
+	return false
+}
+
+func (r *HTTPReporter) addEdge(conn httpConn, emd report.EdgeMetadata) {
+	var (
+		clientID = r.scopedNets.EndpointNodeID(r.hostID, conn.clientIP, strconv.Itoa(int(conn.clientPort)))
+		serverID = r.scopedNets.EndpointNodeID(r.hostID, conn.serverIP, strconv.Itoa(int(conn.serverPort)))
+	)
+	r.rpt.Endpoint = r.rpt.Endpoint.
+		AddNode(clientID, report.MakeNode().WithEdge(serverID, emd)).
+		AddNode(serverID, report.MakeNode())
+}
+
+func isHTTPRequest(payload []byte) bool {
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(payload, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpStatusCode parses the status code from a status line, e.g.
+// "HTTP/1.1 200 OK".
+func httpStatusCode(payload []byte) (int, bool) {
+	if len(payload) < 12 || !bytes.HasPrefix(payload, []byte("HTTP/1.")) || payload[8] != ' ' {
+		return 0, false
+	}
+	code, err := strconv.Atoi(string(payload[9:12]))
+	if err != nil || code < 100 || code > 599 {
+		return 0, false
+	}
+	return code, true
+}
@@ -0,0 +1,142 @@
+package sniff_test
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/weaveworks/scope/probe/sniff"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+type mockPacket struct {
+	data []byte
+	ts   time.Time
+}
+
+// mockPacketsSource yields each of its packets once, and then is done.
+type mockPacketsSource struct {
+	packets []mockPacket
+	done    chan struct{}
+}
+
+func (s *mockPacketsSource) ZeroCopyReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(s.packets) == 0 {
+		close(s.done)
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	p := s.packets[0]
+	s.packets = s.packets[1:]
+	return p.data, gopacket.CaptureInfo{Timestamp: p.ts, CaptureLength: len(p.data), Length: len(p.data)}, nil
+}
+
+func (s *mockPacketsSource) LinkType() layers.LinkType { return layers.LinkTypeEthernet }
+
+func (s *mockPacketsSource) Close() {}
+
+func tcpPacket(t *testing.T, srcIP, dstIP string, srcPort, dstPort int, seq uint32, ts time.Time, payload string) mockPacket {
+	var (
+		buf = gopacket.NewSerializeBuffer()
+		ip  = &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.ParseIP(srcIP), DstIP: net.ParseIP(dstIP)}
+		tcp = &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), Seq: seq}
+	)
+	tcp.SetNetworkLayerForChecksum(ip)
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+			DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+			EthernetType: layers.EthernetTypeIPv4,
+		}, ip, tcp, gopacket.Payload(payload),
+	); err != nil {
+		t.Fatal(err)
+	}
+	return mockPacket{data: buf.Bytes(), ts: ts}
+}
+
+func TestHTTPReporter(t *testing.T) {
+	const hostID = "host"
+	var (
+		t0  = time.Unix(1450000000, 0)
+		src = &mockPacketsSource{done: make(chan struct{}), packets: []mockPacket{
+			tcpPacket(t, "10.0.0.1", "10.0.0.2", 44444, 80, 1, t0, "GET /a HTTP/1.1\r\nHost: b\r\n\r\n"),
+			tcpPacket(t, "10.0.0.2", "10.0.0.1", 80, 44444, 1, t0.Add(30*time.Millisecond), "HTTP/1.1 503 Service Unavailable\r\n\r\n"),
+			tcpPacket(t, "10.0.0.1", "10.0.0.2", 44444, 80, 30, t0.Add(40*time.Millisecond), "POST /c HTTP/1.1\r\nHost: b\r\n\r\n"),
+			tcpPacket(t, "10.0.0.1", "10.0.0.2", 44444, 80, 60, t0.Add(41*time.Millisecond), "request body"),
+			tcpPacket(t, "10.0.0.2", "10.0.0.1", 80, 44444, 38, t0.Add(42*time.Millisecond), "HTTP/1.1 200 OK\r\n\r\n"),
+
+			// Not on one of the ports
+			tcpPacket(t, "10.0.0.1", "10.0.0.3", 44445, 8000, 1, t0, "GET / HTTP/1.1\r\n\r\n"),
+		}}
+	)
+	r := sniff.NewHTTPReporter(hostID, nil, src, []uint16{80, 8080})
+	defer r.Stop()
+	<-src.done
+
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		clientID = report.MakeEndpointNodeID(hostID, "10.0.0.1", "44444")
+		serverID = report.MakeEndpointNodeID(hostID, "10.0.0.2", "80")
+		want     = report.EdgeMetadata{}.
+				WithHTTPRequest().WithHTTPResponse(503, 30*time.Millisecond).
+				WithHTTPRequest().WithHTTPResponse(200, 2*time.Millisecond)
+	)
+	if have := rpt.Endpoint.Nodes[clientID].Edges[serverID]; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+	if want, have := 2, len(rpt.Endpoint.Nodes); want != have {
+		t.Errorf("want %d nodes, have %d", want, have)
+	}
+
+	// Requests are only reported once
+	if rpt, _ := r.Report(); len(rpt.Endpoint.Nodes) != 0 {
+		t.Errorf("want an empty report, have %v", rpt.Endpoint.Nodes)
+	}
+}
+
+func TestHTTPReporterDuplicates(t *testing.T) {
+	const hostID = "host"
+	var (
+		t0       = time.Unix(1450000000, 0)
+		request  = "GET /a HTTP/1.1\r\nHost: b\r\n\r\n"
+		response = "HTTP/1.1 200 OK\r\n\r\n"
+		src      = &mockPacketsSource{done: make(chan struct{}), packets: []mockPacket{
+			// The request, on the client's veth and on the bridge, and again
+			// after the DNAT to the server container.
+			tcpPacket(t, "10.0.0.1", "10.0.0.2", 44444, 80, 1, t0, request),
+			tcpPacket(t, "10.0.0.1", "10.0.0.2", 44444, 80, 1, t0, request),
+			tcpPacket(t, "10.0.0.1", "172.17.0.2", 44444, 8080, 1, t0, request),
+
+			// The response, from the server container, and after NAT.
+			tcpPacket(t, "172.17.0.2", "10.0.0.1", 8080, 44444, 1, t0.Add(10*time.Millisecond), response),
+			tcpPacket(t, "10.0.0.2", "10.0.0.1", 80, 44444, 1, t0.Add(10*time.Millisecond), response),
+		}}
+	)
+	r := sniff.NewHTTPReporter(hostID, nil, src, []uint16{80, 8080})
+	defer r.Stop()
+	<-src.done
+
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		clientID = report.MakeEndpointNodeID(hostID, "10.0.0.1", "44444")
+		serverID = report.MakeEndpointNodeID(hostID, "10.0.0.2", "80")
+		want     = report.EdgeMetadata{}.WithHTTPRequest().WithHTTPResponse(200, 10*time.Millisecond)
+	)
+	if have := rpt.Endpoint.Nodes[clientID].Edges[serverID]; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+	if want, have := 2, len(rpt.Endpoint.Nodes); want != have {
+		t.Errorf("want %d nodes, have %d", want, have)
+	}
+}
@@ -14,70 +14,38 @@ import (
 	"github.com/weaveworks/scope/report"
 )
 
-/*
-It's important to adjust GOMAXPROCS when using sniffer:
-
-if *captureEnabled {
-	var sniffers int
-	for _, iface := range strings.Split(*captureInterfaces, ",") {
-		source, err := sniff.NewSource(iface)
-		if err != nil {
-			log.Printf("warning: %v", err)
-			continue
-		}
-		defer source.Close()
-		log.Printf("capturing packets on %s", iface)
-		reporters = append(reporters, sniff.New(hostID, localNets, source, *captureOn, *captureOff))
-		sniffers++
-	}
-	// Packet capture can block OS threads on Linux, so we need to provide
-	// sufficient overhead in GOMAXPROCS.
-	if have, want := runtime.GOMAXPROCS(-1), (sniffers + 1); have < want {
-		runtime.GOMAXPROCS(want)
-	}
-}
-
-func interfaces() string {
-	ifaces, err := net.Interfaces()
-	if err != nil {
-		log.Print(err)
-		return ""
-	}
-	a := make([]string, 0, len(ifaces))
-	for _, iface := range ifaces {
-		a = append(a, iface.Name)
-	}
-	return strings.Join(a, ",")
-}
-
-Also, the capture on/off sampling methodology is probably not worth keeping.
-*/
-
 // Sniffer is a packet-sniffing reporter.
 type Sniffer struct {
-	hostID    string
-	localNets report.Networks
-	reports   chan chan report.Report
-	parser    *gopacket.DecodingLayerParser
-	decoded   []gopacket.LayerType
-	eth       layers.Ethernet
-	ip4       layers.IPv4
-	ip6       layers.IPv6
-	tcp       layers.TCP
-	udp       layers.UDP
-	icmp4     layers.ICMPv4
-	icmp6     layers.ICMPv6
+	hostID     string
+	localNets  report.Networks
+	scopedNets report.Networks
+	reports    chan chan report.Report
+	quit       chan struct{}
+	parser     *gopacket.DecodingLayerParser
+	decoded    []gopacket.LayerType
+	eth        layers.Ethernet
+	ip4        layers.IPv4
+	ip6        layers.IPv6
+	tcp        layers.TCP
+	udp        layers.UDP
+	icmp4      layers.ICMPv4
+	icmp6      layers.ICMPv6
 }
 
 // New returns a new sniffing reporter that samples traffic by turning its
 // packet capture facilities on and off. Note that the on and off durations
 // represent a way to bound CPU burn. Effective sample rate needs to be
-// calculated as (packets decoded / packets observed).
-func New(hostID string, localNets report.Networks, src gopacket.ZeroCopyPacketDataSource, on, off time.Duration) *Sniffer {
+// calculated as (packets decoded / packets observed). The sniffer keeps
+// reporting (empty reports) once the source is done, until it's stopped.
+// Addresses in the scoped networks are scoped by host, as the endpoint
+// reporter does.
+func New(hostID string, localNets, scopedNets report.Networks, src gopacket.ZeroCopyPacketDataSource, on, off time.Duration) *Sniffer {
 	s := &Sniffer{
-		hostID:    hostID,
-		localNets: localNets,
-		reports:   make(chan chan report.Report),
+		hostID:     hostID,
+		localNets:  localNets,
+		scopedNets: scopedNets,
+		reports:    make(chan chan report.Report),
+		quit:       make(chan struct{}),
 	}
 	s.parser = gopacket.NewDecodingLayerParser(
 		layers.LayerTypeEthernet,
@@ -87,6 +55,14 @@ func New(hostID string, localNets report.Networks, src gopacket.ZeroCopyPacketDa
 	return s
 }
 
+// Name implements the Reporter interface.
+func (*Sniffer) Name() string { return "Sniffer" }
+
+// Stop stops the sniffer. It doesn't close the source.
+func (s *Sniffer) Stop() {
+	close(s.quit)
+}
+
 // Report implements the Reporter interface.
 func (s *Sniffer) Report() (report.Report, error) {
 	c := make(chan report.Report)
@@ -142,6 +118,9 @@ func (s *Sniffer) loop(src gopacket.ZeroCopyPacketDataSource, on, off time.Durat
 			rpt = report.MakeReport()
 
 		case <-done:
+			done = nil // keep serving reports, e.g. after reading a pcap file
+
+		case <-s.quit:
 			return
 		}
 	}
@@ -247,7 +226,8 @@ func (s *Sniffer) read(src gopacket.ZeroCopyPacketDataSource, dst chan Packet, p
 		case dst <- p:
 			atomic.AddUint64(count, 1)
 		default:
-			log.Printf("sniffer dropped packet")
+			// Dropped packets still count towards the total, so they're
+			// compensated for by the sampling rate.
 		}
 	}
 }
@@ -300,8 +280,8 @@ func (s *Sniffer) Merge(p Packet, rpt *report.Report) {
 	// For sure, we can add to the address topology.
 	{
 		var (
-			srcNodeID = report.MakeAddressNodeID(s.hostID, localIP)
-			dstNodeID = report.MakeAddressNodeID(s.hostID, remoteIP)
+			srcNodeID = s.scopedNets.AddressNodeID(s.hostID, localIP)
+			dstNodeID = s.scopedNets.AddressNodeID(s.hostID, remoteIP)
 		)
 
 		rpt.Address = addAdjacency(rpt.Address, srcNodeID, dstNodeID)
@@ -332,8 +312,8 @@ func (s *Sniffer) Merge(p Packet, rpt *report.Report) {
 	// If we have ports, we can add to the endpoint topology, too.
 	if p.SrcPort != "" && p.DstPort != "" {
 		var (
-			srcNodeID = report.MakeEndpointNodeID(s.hostID, localIP, localPort)
-			dstNodeID = report.MakeEndpointNodeID(s.hostID, remoteIP, remotePort)
+			srcNodeID = s.scopedNets.EndpointNodeID(s.hostID, localIP, localPort)
+			dstNodeID = s.scopedNets.EndpointNodeID(s.hostID, remoteIP, remotePort)
 		)
 
 		rpt.Endpoint = addAdjacency(rpt.Endpoint, srcNodeID, dstNodeID)
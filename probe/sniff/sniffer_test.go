@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 
-	"github.com/weaveworks/scope/experimental/sniff"
+	"github.com/weaveworks/scope/common/mtime"
+	"github.com/weaveworks/scope/probe/sniff"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 )
@@ -21,25 +23,89 @@ func TestSnifferShutdown(t *testing.T) {
 		src    = newMockSource([]byte{}, nil)
 		on     = time.Millisecond
 		off    = time.Millisecond
-		s      = sniff.New(hostID, report.Networks{}, src, on, off)
+		s      = sniff.New(hostID, report.Networks{}, nil, src, on, off)
 	)
 
-	// Stopping the source should terminate the sniffer.
+	// Once the source is done, the sniffer should keep reporting.
 	src.Close()
 	time.Sleep(10 * time.Millisecond)
+	if _, err := s.Report(); err != nil {
+		t.Fatal(err)
+	}
 
-	// Try to get a report from the sniffer. It should block forever, as the
-	// loop goroutine should have exited.
+	// Stopping the sniffer should terminate it. Try to get a report from the
+	// sniffer: it should block forever, as the loop goroutine has exited.
+	s.Stop()
+	time.Sleep(10 * time.Millisecond)
 	report := make(chan struct{})
 	go func() { _, _ = s.Report(); close(report) }()
 	select {
 	case <-time.After(time.Millisecond):
 	case <-report:
-		t.Errorf("shouldn't get report after Close")
+		t.Errorf("shouldn't get report after Stop")
+	}
+}
+
+func TestSnifferReport(t *testing.T) {
+	var (
+		hostID = "abcd"
+		buf    = gopacket.NewSerializeBuffer()
+		ip     = &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolTCP,
+			SrcIP:    net.ParseIP("1.0.0.1"),
+			DstIP:    net.ParseIP("2.0.0.2"),
+		}
+		tcp = &layers.TCP{SrcPort: 1000, DstPort: 80}
+
+		_, ipnet, _ = net.ParseCIDR("1.0.0.0/24")
+		localNets   = report.Networks([]*net.IPNet{ipnet})
+	)
+	tcp.SetNetworkLayerForChecksum(ip)
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+			DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+			EthernetType: layers.EthernetTypeIPv4,
+		}, ip, tcp, gopacket.Payload("hello"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// The source produces packets faster than the sniffer can merge them, so
+	// some get dropped, which must show up in the sampling rate.
+	src := newMockSource(buf.Bytes(), nil)
+	defer src.Close()
+	s := sniff.New(hostID, localNets, nil, src, time.Second, 0)
+	defer s.Stop()
+	time.Sleep(10 * time.Millisecond)
+
+	rpt, err := s.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpt.Sampling.Count == 0 || rpt.Sampling.Total < rpt.Sampling.Count {
+		t.Fatalf("bad sampling: %+v", rpt.Sampling)
+	}
+	var (
+		srcNodeID = report.MakeEndpointNodeID(hostID, "1.0.0.1", "1000")
+		dstNodeID = report.MakeEndpointNodeID(hostID, "2.0.0.2", "80")
+		emd       = rpt.Endpoint.Nodes[srcNodeID].Edges[dstNodeID]
+	)
+	if emd.EgressPacketCount == nil || emd.EgressByteCount == nil {
+		t.Fatalf("no counts on edge: %+v", emd)
+	}
+	// Both counts are inflated by the sampling rate, give or take rounding.
+	if packets, bytes := *emd.EgressPacketCount, *emd.EgressByteCount; bytes+5 < 5*packets || bytes > 5*packets+5 {
+		t.Errorf("want ~%d bytes, have %d", 5*packets, bytes)
 	}
 }
 
 func TestMerge(t *testing.T) {
+	mtime.NowForce(time.Now())
+	defer mtime.NowReset()
+
 	var (
 		hostID = "xyz"
 		src    = newMockSource([]byte{}, nil)
@@ -58,7 +124,7 @@ func TestMerge(t *testing.T) {
 		_, ipnet, _ = net.ParseCIDR(p.SrcIP + "/24") // ;)
 		localNets   = report.Networks([]*net.IPNet{ipnet})
 	)
-	sniff.New(hostID, localNets, src, on, off).Merge(p, &rpt)
+	sniff.New(hostID, localNets, nil, src, on, off).Merge(p, &rpt)
 
 	var (
 		srcEndpointNodeID = report.MakeEndpointNodeID(hostID, p.SrcIP, p.SrcPort)
@@ -72,6 +138,7 @@ func TestMerge(t *testing.T) {
 			}),
 			dstEndpointNodeID: report.MakeNode(),
 		},
+		Controls: report.Controls{},
 	}), rpt.Endpoint; !reflect.DeepEqual(want, have) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
@@ -88,6 +155,7 @@ func TestMerge(t *testing.T) {
 			}),
 			dstAddressNodeID: report.MakeNode(),
 		},
+		Controls: report.Controls{},
 	}), rpt.Address; !reflect.DeepEqual(want, have) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
@@ -0,0 +1,90 @@
+package sniff
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// Source describes a packet data source that can be terminated.
+type Source interface {
+	gopacket.ZeroCopyPacketDataSource
+	LinkType() layers.LinkType
+	Close()
+}
+
+const (
+	snaplen = 65535
+	promisc = true
+	timeout = pcap.BlockForever
+)
+
+// NewSource returns a live packet data source via the passed device
+// (interface). If filter isn't empty, it's applied as a BPF filter, so only
+// matching packets are captured.
+func NewSource(device, filter string) (Source, error) {
+	handle, err := pcap.OpenLive(device, snaplen, promisc, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return withFilter(handle, filter)
+}
+
+// NewSourceWithTimeout is like NewSource, but reads return ErrTimeout when
+// no packets arrive within the timeout, rather than blocking until one does,
+// so that readers get a chance to stop.
+func NewSourceWithTimeout(device, filter string, timeout time.Duration) (Source, error) {
+	handle, err := pcap.OpenLive(device, snaplen, promisc, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := withFilter(handle, filter); err != nil {
+		return nil, err
+	}
+	return timeoutSource{handle}, nil
+}
+
+// ErrTimeout is returned by reads from sources with a timeout, when no
+// packets arrived in time. Like net.Error, it has a Timeout method.
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout expired" }
+func (timeoutError) Timeout() bool { return true }
+
+type timeoutSource struct {
+	*pcap.Handle
+}
+
+func (s timeoutSource) ZeroCopyReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	data, ci, err := s.Handle.ZeroCopyReadPacketData()
+	if err == pcap.NextErrorTimeoutExpired {
+		err = ErrTimeout
+	}
+	return data, ci, err
+}
+
+// NewFileSource returns a packet data source which reads the packets in the
+// passed pcap file, for offline testing. The source is done once the file
+// has been read. If filter isn't empty, it's applied as a BPF filter.
+func NewFileSource(filename, filter string) (Source, error) {
+	handle, err := pcap.OpenOffline(filename)
+	if err != nil {
+		return nil, err
+	}
+	return withFilter(handle, filter)
+}
+
+func withFilter(handle *pcap.Handle, filter string) (Source, error) {
+	if filter == "" {
+		return handle, nil
+	}
+	if err := handle.SetBPFFilter(filter); err != nil {
+		handle.Close()
+		return nil, err
+	}
+	return handle, nil
+}
@@ -6,6 +6,9 @@ import (
 	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -14,26 +17,61 @@ import (
 	"github.com/weaveworks/weave/common"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/xfer"
 )
 
 // Router creates the mux for all the various app components.
-func router(c app.Collector) *mux.Router {
+func router(c app.Collector, auth *app.ProbeAuth, rules []app.AlertRule, sinks []app.AlertSink) *mux.Router {
 	router := mux.NewRouter()
 	app.RegisterTopologyRoutes(c, router)
-	app.RegisterReportPostHandler(c, router)
-	app.RegisterControlRoutes(router)
-	app.RegisterPipeRoutes(router)
+	app.RegisterAlertRoutes(c, rules, sinks, router)
+	app.RegisterReportPostHandler(c, auth, router)
+	app.RegisterControlRoutes(auth, router)
+	app.RegisterPipeRoutes(auth, router)
 	router.Methods("GET").PathPrefix("/").Handler(http.FileServer(FS(false)))
 	return router
 }
 
+// tenantDir names the directory a tenant's reports, and own copies of config
+// files, are kept in.
+func tenantDir(tenant string) string {
+	return "tenant-" + url.QueryEscape(tenant)
+}
+
+// tenantFile is where a tenant's own copy of a config file is kept: in the
+// tenant's directory next to it.
+func tenantFile(filename, tenant string) string {
+	return filepath.Join(filepath.Dir(filename), tenantDir(tenant), filepath.Base(filename))
+}
+
+// tenantWebhook adds the tenant to the query of a webhook URL, so whatever
+// receives the alerts can tell the tenants apart.
+func tenantWebhook(webhook, tenant string) string {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return webhook
+	}
+	query := u.Query()
+	query.Set("tenant", tenant)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 // Main runs the app
 func appMain() {
 	var (
-		window    = flag.Duration("window", 15*time.Second, "window")
-		listen    = flag.String("http.address", ":"+strconv.Itoa(xfer.AppPort), "webserver listen address")
-		logPrefix = flag.String("log.prefix", "<app>", "prefix for each log line")
+		window           = flag.Duration("window", 15*time.Second, "window")
+		listen           = flag.String("http.address", ":"+strconv.Itoa(xfer.AppPort), "webserver listen address")
+		logPrefix        = flag.String("log.prefix", "<app>", "prefix for each log line")
+		storageDir       = flag.String("storage.dir", "", "directory to keep reports in, for historic queries (disabled if empty)")
+		storageRetention = flag.Duration("storage.retention", 24*time.Hour, "how long to keep reports in the storage directory")
+		probeTokens      = flag.String("probe.tokens", "", "comma-separated list of tokens probes may use (any token is accepted if neither this nor -probe.tokens.file is set)")
+		probeTokensFile  = flag.String("probe.tokens.file", "", "file with tokens probes may use, one per line")
+		multitenant      = flag.Bool("multitenant", false, "keep reports, controls, pipes, alerts and external groups separate per tenant, as chosen by the probe token (needs -probe.tokens or -probe.tokens.file)")
+		alertRules       = flag.String("alerts.rules", "", "file with a JSON list of alert rules, evaluated against every report (disabled if empty; in multitenant mode, each tenant's are read from tenant-<tenant>/<file name> next to it)")
+		alertWebhooks    = flag.String("alerts.webhook", "", "comma-separated list of URLs to POST alerts to, as they fire and resolve (in multitenant mode, with the tenant added to the query)")
+		externalGroups   = flag.String("external.groups", "", "file with a JSON list of named groups of remote CIDRs and hostnames, rendered instead of The Internet (disabled if empty; in multitenant mode, each tenant's are read from tenant-<tenant>/<file name> next to it)")
 	)
 	flag.Parse()
 
@@ -48,7 +86,110 @@ func appMain() {
 	app.UniqueID = strconv.FormatInt(rand.Int63(), 16)
 	app.Version = version
 	log.Printf("app starting, version %s, ID %s", app.Version, app.UniqueID)
-	http.Handle("/", router(app.NewCollector(*window)))
+
+	tokens := []string{}
+	if *probeTokens != "" {
+		tokens = append(tokens, strings.Split(*probeTokens, ",")...)
+	}
+	if *probeTokensFile != "" {
+		fileTokens, err := app.LoadProbeTokens(*probeTokensFile)
+		if err != nil {
+			log.Fatalf("Error reading probe tokens from %s: %v", *probeTokensFile, err)
+		}
+		tokens = append(tokens, fileTokens...)
+	}
+	if len(tokens) > 0 {
+		log.Printf("accepting %d probe token(s)", len(tokens))
+	}
+
+	auth := app.NewProbeAuth(tokens)
+
+	loadRules := func(filename string) ([]app.AlertRule, error) {
+		if *alertRules == "" {
+			return []app.AlertRule{}, nil
+		}
+		return app.LoadAlertRules(filename)
+	}
+	makeSinks := func(tenant string) []app.AlertSink {
+		sinks := []app.AlertSink{}
+		if *alertWebhooks == "" {
+			return sinks
+		}
+		for _, webhook := range strings.Split(*alertWebhooks, ",") {
+			if tenant != "" {
+				webhook = tenantWebhook(webhook, tenant)
+			}
+			sinks = append(sinks, app.NewWebhookSink(webhook))
+		}
+		return sinks
+	}
+	loadGroups := func(filename string) (render.ExternalGroups, error) {
+		if *externalGroups == "" {
+			return nil, nil
+		}
+		return render.LoadExternalGroups(filename)
+	}
+
+	if *storageDir != "" {
+		log.Printf("storing reports in %s for %s", *storageDir, *storageRetention)
+	}
+	newCollector := func(dir string, groups render.ExternalGroups) (app.Collector, error) {
+		if dir == "" {
+			return app.NewCollectorWithStore(*window, nil, groups), nil
+		}
+		store, err := app.NewDiskStore(dir, *storageRetention)
+		if err != nil {
+			return app.NewCollectorWithStore(*window, nil, groups), err
+		}
+		return app.NewCollectorWithStore(*window, store, groups), nil
+	}
+
+	if *multitenant {
+		tenants, err := app.NewMultiTenantRouter(auth, func(tenant string) http.Handler {
+			dir := ""
+			if *storageDir != "" {
+				dir = filepath.Join(*storageDir, tenantDir(tenant))
+			}
+			// Tenants without their own config files have no rules or groups.
+			rules, err := loadRules(tenantFile(*alertRules, tenant))
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("Error reading alert rules, not evaluating any for tenant %q: %v", tenant, err)
+			}
+			groups, err := loadGroups(tenantFile(*externalGroups, tenant))
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("Error reading external groups, not rendering any for tenant %q: %v", tenant, err)
+			}
+			c, err := newCollector(dir, groups)
+			if err != nil {
+				log.Printf("Error opening report storage %s, not storing reports for tenant %q: %v", dir, tenant, err)
+			}
+			return router(c, auth, rules, makeSinks(tenant))
+		})
+		if err != nil {
+			log.Fatalf("Error setting up tenants: %v", err)
+		}
+		http.Handle("/", tenants)
+	} else {
+		rules, err := loadRules(*alertRules)
+		if err != nil {
+			log.Fatalf("Error reading alert rules from %s: %v", *alertRules, err)
+		}
+		if *alertRules != "" {
+			log.Printf("evaluating %d alert rule(s)", len(rules))
+		}
+		groups, err := loadGroups(*externalGroups)
+		if err != nil {
+			log.Fatalf("Error reading external groups from %s: %v", *externalGroups, err)
+		}
+		if *externalGroups != "" {
+			log.Printf("rendering %d external group(s)", len(groups))
+		}
+		c, err := newCollector(*storageDir, groups)
+		if err != nil {
+			log.Fatalf("Error opening report storage %s: %v", *storageDir, err)
+		}
+		http.Handle("/", router(c, auth, rules, makeSinks("")))
+	}
 	go func() {
 		log.Printf("listening on %s", *listen)
 		log.Print(http.ListenAndServe(*listen, nil))
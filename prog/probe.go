@@ -9,6 +9,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/sniff"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/xfer"
 )
@@ -39,14 +41,24 @@ func probeMain() {
 		spyInterval        = flag.Duration("spy.interval", time.Second, "spy (scan) interval")
 		spyProcs           = flag.Bool("processes", true, "report processes (needs root)")
 		dockerEnabled      = flag.Bool("docker", false, "collect Docker-related attributes for processes")
-		dockerInterval     = flag.Duration("docker.interval", 10*time.Second, "how often to update Docker attributes")
+		dockerInterval     = flag.Duration("docker.interval", 1*time.Minute, "how often to reconcile Docker state with the Docker events, in case any were missed")
 		dockerBridge       = flag.String("docker.bridge", "docker0", "the docker bridge name")
+		dockerLogsTail     = flag.Int("docker.logs.tail", 100, "number of lines from the end of a container's logs to start the Logs control with (0 for all)")
+		dockerLogsSince    = flag.Duration("docker.logs.since", 0, "how far back in time to start the Logs control with (0 for the beginning)")
 		kubernetesEnabled  = flag.Bool("kubernetes", false, "collect kubernetes-related attributes for containers, should only be enabled on the master node")
 		kubernetesAPI      = flag.String("kubernetes.api", "http://localhost:8080", "Address of kubernetes master api")
 		kubernetesInterval = flag.Duration("kubernetes.interval", 10*time.Second, "how often to do a full resync of the kubernetes data")
 		weaveRouterAddr    = flag.String("weave.router.addr", "", "IP address or FQDN of the Weave router")
 		procRoot           = flag.String("proc.root", "/proc", "location of the proc filesystem")
 		useConntrack       = flag.Bool("conntrack", true, "also use conntrack to track connections")
+		captureEnabled     = flag.Bool("capture", false, "perform sampled packet capture")
+		captureInterfaces  = flag.String("capture.interfaces", interfaces(), "packet capture on these interfaces")
+		captureFilter      = flag.String("capture.filter", "", "BPF filter for packet capture, e.g. \"tcp port 80\"")
+		captureOn          = flag.Duration("capture.on", 1*time.Second, "packet capture duty cycle 'on'")
+		captureOff         = flag.Duration("capture.off", 5*time.Second, "packet capture duty cycle 'off'")
+		captureFile        = flag.String("capture.file", "", "read packets from this pcap file instead of the interfaces (for testing)")
+		httpPorts          = flag.String("capture.http.ports", "", "comma-separated list of server ports to parse HTTP/1.x on, for request rates, statuses and latencies on edges (disabled if empty)")
+		dnsSnoop           = flag.Bool("dns.snoop", false, "name remote endpoints after the DNS responses local processes get (needs root)")
 		insecure           = flag.Bool("insecure", false, "(SSL) explicitly allow \"insecure\" SSL connections and transfers")
 		logPrefix          = flag.String("log.prefix", "<probe>", "prefix for each log line")
 	)
@@ -110,28 +122,49 @@ func probeMain() {
 	resolver := xfer.NewStaticResolver(targets, clients.Set)
 	defer resolver.Stop()
 
+	// Addresses in scoped networks (e.g. the Docker bridge) are local to this
+	// host, so we scope them by host ID.
+	scopedNets := report.Networks{}
+	if *dockerEnabled {
+		if nets, err := report.InterfaceNetworks(*dockerBridge); err == nil {
+			scopedNets = nets
+		} else {
+			log.Printf("Docker: problem with bridge %s: %v", *dockerBridge, err)
+		}
+	}
+
+	var dnsSnooper *endpoint.DNSSnooper
+	if *dnsSnoop {
+		if source, err := sniff.NewSourceWithTimeout("any", "udp src port 53", time.Second); err == nil {
+			dnsSnooper = endpoint.NewDNSSnooper(source)
+			defer dnsSnooper.Stop()
+		} else {
+			log.Printf("DNS snooping: %v", err)
+		}
+	}
+
 	processCache := process.NewCachingWalker(process.NewWalker(*procRoot))
 
-	endpointReporter := endpoint.NewReporter(hostID, hostName, *spyProcs, *useConntrack, processCache)
+	endpointReporter := endpoint.NewReporter(hostID, hostName, *spyProcs, *useConntrack, scopedNets, processCache, dnsSnooper)
 	defer endpointReporter.Stop()
 
 	p := probe.New(*spyInterval, *publishInterval, clients)
 	p.AddTicker(processCache)
 	p.AddReporter(
 		endpointReporter,
-		host.NewReporter(hostID, hostName, localNets),
+		host.NewReporter(hostID, hostName, localNets, scopedNets),
 		process.NewReporter(processCache, hostID, process.GetDeltaTotalJiffies),
 	)
 	p.AddTagger(probe.NewTopologyTagger(), host.NewTagger(hostID, probeID))
 
 	if *dockerEnabled {
-		if err := report.AddLocalBridge(*dockerBridge); err != nil {
-			log.Printf("Docker: problem with bridge %s: %v", *dockerBridge, err)
-		}
-		if registry, err := docker.NewRegistry(*dockerInterval, clients); err == nil {
+		if registry, err := docker.NewRegistry(*dockerInterval, clients, docker.LogsConfig{
+			Tail:  *dockerLogsTail,
+			Since: *dockerLogsSince,
+		}); err == nil {
 			defer registry.Stop()
 			p.AddTagger(docker.NewTagger(registry, processCache))
-			p.AddReporter(docker.NewReporter(registry, hostID, p))
+			p.AddReporter(docker.NewReporter(registry, hostID, scopedNets, p))
 		} else {
 			log.Printf("Docker: failed to start registry: %v", err)
 		}
@@ -146,6 +179,61 @@ func probeMain() {
 		}
 	}
 
+	if *captureEnabled {
+		var sources []sniff.Source
+		if *captureFile != "" {
+			if source, err := sniff.NewFileSource(*captureFile, *captureFilter); err == nil {
+				log.Printf("capturing packets from %s", *captureFile)
+				sources = append(sources, source)
+			} else {
+				log.Printf("warning: %v", err)
+			}
+		} else {
+			for _, iface := range strings.Split(*captureInterfaces, ",") {
+				source, err := sniff.NewSource(iface, *captureFilter)
+				if err != nil {
+					log.Printf("warning: %v", err)
+					continue
+				}
+				log.Printf("capturing packets on %s", iface)
+				sources = append(sources, source)
+			}
+		}
+		for _, source := range sources {
+			defer source.Close()
+			sniffer := sniff.New(hostID, localNets, scopedNets, source, *captureOn, *captureOff)
+			defer sniffer.Stop()
+			p.AddReporter(sniffer)
+		}
+		// Packet capture can block OS threads on Linux, so we need to provide
+		// sufficient overhead in GOMAXPROCS.
+		if have, want := runtime.GOMAXPROCS(-1), (len(sources) + 1); have < want {
+			runtime.GOMAXPROCS(want)
+		}
+	}
+
+	if *httpPorts != "" {
+		ports, filters := []uint16{}, []string{}
+		for _, s := range strings.Split(*httpPorts, ",") {
+			port, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				log.Fatalf("HTTP: bad port %q: %v", s, err)
+			}
+			ports = append(ports, uint16(port))
+			filters = append(filters, fmt.Sprintf("tcp port %d", port))
+		}
+		// We see some packets several times on "any" (e.g. on a veth and on
+		// the bridge); the reporter only counts them once.
+		if source, err := sniff.NewSource("any", strings.Join(filters, " or ")); err == nil {
+			defer source.Close()
+			httpReporter := sniff.NewHTTPReporter(hostID, scopedNets, source, ports)
+			defer httpReporter.Stop()
+			p.AddReporter(httpReporter)
+		} else {
+			log.Printf("HTTP: failed to capture packets: %v", err)
+		}
+	}
+
 	if *weaveRouterAddr != "" {
 		weave := overlay.NewWeave(hostID, *weaveRouterAddr)
 		defer weave.Stop()
@@ -167,3 +255,16 @@ func probeMain() {
 
 	common.SignalHandlerLoop()
 }
+
+func interfaces() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
+	a := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		a = append(a, iface.Name)
+	}
+	return strings.Join(a, ",")
+}
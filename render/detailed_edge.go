@@ -0,0 +1,133 @@
+package render
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// DetailedEdge is the data type that's yielded to the JavaScript layer when
+// we want deep information about the edge between two nodes.
+type DetailedEdge struct {
+	ID       string              `json:"id"`
+	Source   string              `json:"source"`
+	Target   string              `json:"target"`
+	Metadata report.EdgeMetadata `json:"metadata"`
+	Tables   []Table             `json:"tables"`
+}
+
+// MakeDetailedEdge describes the edge from src to dst, by the connections
+// between their origin endpoint nodes (or, failing those, their origin
+// address nodes) which make it up. A connection may be known from either of
+// its ends, e.g. only from the probe on the dst host, so we look at both.
+func MakeDetailedEdge(r report.Report, src, dst RenderableNode) DetailedEdge {
+	var (
+		connections = []Row{}
+		emd         = report.EdgeMetadata{}
+	)
+	// Connections are in the address topology too, so we only look there if
+	// there are no endpoints, lest we count them twice.
+	for _, topology := range []report.Topology{r.Endpoint, r.Address} {
+		seen := map[string]struct{}{}
+		add := func(srcID, dstID string, md report.EdgeMetadata) {
+			key := srcID + report.EdgeDelim + dstID
+			if _, ok := seen[key]; ok {
+				return
+			}
+			seen[key] = struct{}{}
+			srcNode, dstNode := topology.Nodes[srcID], topology.Nodes[dstID]
+			connections = append(connections, Row{
+				Key:        connectionLabel(r, srcID, srcNode),
+				ValueMajor: connectionLabel(r, dstID, dstNode),
+				ValueMinor: connectionSources(srcNode, dstNode),
+				Expandable: true,
+			})
+			emd = emd.Flatten(md)
+		}
+		for _, srcID := range src.Origins {
+			srcNode, ok := topology.Nodes[srcID]
+			if !ok {
+				continue
+			}
+			for _, dstID := range srcNode.Adjacency {
+				if dst.Origins.Contains(dstID) {
+					add(srcID, dstID, srcNode.Edges[dstID])
+				}
+			}
+		}
+		for _, dstID := range dst.Origins {
+			dstNode, ok := topology.Nodes[dstID]
+			if !ok {
+				continue
+			}
+			for _, srcID := range dstNode.Adjacency {
+				if src.Origins.Contains(srcID) {
+					add(srcID, dstID, dstNode.Edges[srcID].Reversed())
+				}
+			}
+		}
+		if len(connections) > 0 {
+			break
+		}
+	}
+
+	tables := []Table{}
+	if table, ok := connectionsTable(connections, r, emd); ok {
+		tables = append(tables, table)
+	}
+	return DetailedEdge{
+		ID:       src.ID + report.EdgeDelim + dst.ID,
+		Source:   src.ID,
+		Target:   dst.ID,
+		Metadata: emd,
+		Tables:   tables,
+	}
+}
+
+// connectionLabel labels one end of a connection by its address and port,
+// and the process it belongs to, if we know it.
+func connectionLabel(r report.Report, nodeID string, n report.Node) string {
+	_, addr, port, ok := report.ParseEndpointNodeID(nodeID)
+	if !ok {
+		if _, addr, ok := report.ParseAddressNodeID(nodeID); ok {
+			return addr
+		}
+		return nodeID
+	}
+	if names, ok := n.Sets["name"]; ok && len(names) > 0 {
+		addr = names[0]
+	}
+	label := net.JoinHostPort(addr, port)
+	pid, ok := n.Metadata[process.PID]
+	if !ok {
+		return label
+	}
+	comm := r.Process.Nodes[report.MakeProcessNodeID(report.ExtractHostID(n), pid)].Metadata[process.Comm]
+	if comm == "" {
+		return fmt.Sprintf("%s (%s)", label, pid)
+	}
+	return fmt.Sprintf("%s (%s %s)", label, comm, pid)
+}
+
+// connectionSources says how the probes found out about the connection.
+func connectionSources(nodes ...report.Node) string {
+	var procspied, conntracked bool
+	for _, n := range nodes {
+		_, ok := n.Metadata[endpoint.Procspied]
+		procspied = procspied || ok
+		_, ok = n.Metadata[endpoint.Conntracked]
+		conntracked = conntracked || ok
+	}
+	sources := []string{}
+	if procspied {
+		sources = append(sources, "procspied")
+	}
+	if conntracked {
+		sources = append(sources, "conntracked")
+	}
+	return strings.Join(sources, ", ")
+}
@@ -0,0 +1,86 @@
+package render_test
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestMakeDetailedEdge(t *testing.T) {
+	var (
+		nodes = render.ProcessRenderer.Render(fixture.Report)
+		have  = render.MakeDetailedEdge(fixture.Report, nodes[expected.ClientProcess1ID], nodes[expected.ServerProcessID])
+		want  = render.DetailedEdge{
+			ID:     expected.ClientProcess1ID + report.EdgeDelim + expected.ServerProcessID,
+			Source: expected.ClientProcess1ID,
+			Target: expected.ServerProcessID,
+			Metadata: report.EdgeMetadata{
+				EgressPacketCount: newu64(10),
+				EgressByteCount:   newu64(100),
+			},
+			Tables: []render.Table{
+				{
+					Title:   "Connections",
+					Numeric: false,
+					Rank:    0,
+					Rows: []render.Row{
+						{Key: "Egress packet rate", ValueMajor: "5", ValueMinor: "packets/sec"},
+						{Key: "Egress byte rate", ValueMajor: "50", ValueMinor: "Bps"},
+						{Key: "Sampling rate", ValueMajor: "25", ValueMinor: "%"},
+						{Key: "Client", ValueMajor: "Server", Expandable: true},
+						{
+							Key:        fmt.Sprintf("%s (%s %s)", net.JoinHostPort(fixture.ClientIP, fixture.ClientPort54001), fixture.Client1Comm, fixture.Client1PID),
+							ValueMajor: fmt.Sprintf("%s (%s %s)", net.JoinHostPort(fixture.ServerIP, fixture.ServerPort), fixture.ServerComm, fixture.ServerPID),
+							ValueMinor: "procspied",
+							Expandable: true,
+						},
+					},
+				},
+			},
+		}
+	)
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedEdgeFromDst(t *testing.T) {
+	var (
+		srcID = report.MakeEndpointNodeID("client", "10.0.0.1", "54001")
+		dstID = report.MakeEndpointNodeID("server", "10.0.0.2", "80")
+		rpt   = report.MakeReport()
+	)
+	// Only the server's probe saw the connection.
+	rpt.Endpoint.AddNode(srcID, report.MakeNode())
+	rpt.Endpoint.AddNode(dstID, report.MakeNodeWith(map[string]string{
+		endpoint.Conntracked: "true",
+	}).WithAdjacent(srcID).WithEdge(srcID, report.EdgeMetadata{
+		EgressPacketCount: newu64(10),
+	}))
+
+	src, dst := render.NewRenderableNode("src"), render.NewRenderableNode("dst")
+	src.Origins = report.MakeIDList(srcID)
+	dst.Origins = report.MakeIDList(dstID)
+	have := render.MakeDetailedEdge(rpt, src, dst)
+
+	if want := (report.EdgeMetadata{IngressPacketCount: newu64(10)}); !reflect.DeepEqual(want, have.Metadata) {
+		t.Errorf("%s", test.Diff(want, have.Metadata))
+	}
+	want := render.Row{
+		Key:        "10.0.0.1:54001",
+		ValueMajor: "10.0.0.2:80",
+		ValueMinor: "conntracked",
+		Expandable: true,
+	}
+	if len(have.Tables) != 1 || !reflect.DeepEqual(want, have.Tables[0].Rows[len(have.Tables[0].Rows)-1]) {
+		t.Errorf("want a connection %v, have %v", want, have.Tables)
+	}
+}
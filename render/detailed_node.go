@@ -2,8 +2,10 @@ package render
 
 import (
 	"fmt"
+	"net"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/host"
@@ -13,7 +15,9 @@ import (
 )
 
 const (
-	containerImageRank = 4
+	externalGroupRank  = 6
+	containerImageRank = 5
+	containersRank     = 4
 	containerRank      = 3
 	processRank        = 2
 	hostRank           = 1
@@ -105,7 +109,13 @@ func MakeDetailedNode(r report.Report, n RenderableNode) DetailedNode {
 		}
 	}
 
-	if table, ok := connectionsTable(connections, r, n); ok {
+	if table, ok := connectionsTable(connections, r, n.EdgeMetadata); ok {
+		tables = append(tables, table)
+	}
+	if table, ok := containersTable(r, n); ok {
+		tables = append(tables, table)
+	}
+	if table, ok := externalGroupTable(n); ok {
 		tables = append(tables, table)
 	}
 
@@ -147,7 +157,7 @@ func getRenderingContext(r report.Report, n RenderableNode) (multiContainer, mul
 	return
 }
 
-func connectionsTable(connections []Row, r report.Report, n RenderableNode) (Table, bool) {
+func connectionsTable(connections []Row, r report.Report, emd report.EdgeMetadata) (Table, bool) {
 	sec := r.Window.Seconds()
 	rate := func(u *uint64) (float64, bool) {
 		if u == nil {
@@ -170,23 +180,43 @@ func connectionsTable(connections []Row, r report.Report, n RenderableNode) (Tab
 	}
 
 	rows := []Row{}
-	if n.EdgeMetadata.MaxConnCountTCP != nil {
-		rows = append(rows, Row{Key: "TCP connections", ValueMajor: strconv.FormatUint(*n.EdgeMetadata.MaxConnCountTCP, 10)})
+	if emd.MaxConnCountTCP != nil {
+		rows = append(rows, Row{Key: "TCP connections", ValueMajor: strconv.FormatUint(*emd.MaxConnCountTCP, 10)})
 	}
-	if rate, ok := rate(n.EdgeMetadata.EgressPacketCount); ok {
+	if emd.MaxConnCountUDP != nil {
+		rows = append(rows, Row{Key: "UDP connections", ValueMajor: strconv.FormatUint(*emd.MaxConnCountUDP, 10)})
+	}
+	conns := len(rows)
+	if rate, ok := rate(emd.EgressPacketCount); ok {
 		rows = append(rows, Row{Key: "Egress packet rate", ValueMajor: fmt.Sprintf("%.0f", rate), ValueMinor: "packets/sec"})
 	}
-	if rate, ok := rate(n.EdgeMetadata.IngressPacketCount); ok {
+	if rate, ok := rate(emd.IngressPacketCount); ok {
 		rows = append(rows, Row{Key: "Ingress packet rate", ValueMajor: fmt.Sprintf("%.0f", rate), ValueMinor: "packets/sec"})
 	}
-	if rate, ok := rate(n.EdgeMetadata.EgressByteCount); ok {
+	if rate, ok := rate(emd.EgressByteCount); ok {
 		s, unit := shortenByteRate(rate)
 		rows = append(rows, Row{Key: "Egress byte rate", ValueMajor: s, ValueMinor: unit})
 	}
-	if rate, ok := rate(n.EdgeMetadata.IngressByteCount); ok {
+	if rate, ok := rate(emd.IngressByteCount); ok {
 		s, unit := shortenByteRate(rate)
 		rows = append(rows, Row{Key: "Ingress byte rate", ValueMajor: s, ValueMinor: unit})
 	}
+	if sampled := r.Sampling.Rate(); len(rows) > conns && sampled < 1.0 {
+		// The rates above are extrapolated from sampled packet capture.
+		rows = append(rows, Row{Key: "Sampling rate", ValueMajor: fmt.Sprintf("%.0f", sampled*100), ValueMinor: "%"})
+	}
+	if rate, ok := rate(emd.HTTPRequestCount); ok {
+		rows = append(rows, Row{Key: "HTTP request rate", ValueMajor: fmt.Sprintf("%.1f", rate), ValueMinor: "requests/sec"})
+	}
+	if responses := httpResponseCount(emd); responses > 0 {
+		errors := emd.HTTPStatusClassCounts[report.HTTPStatusClass(500)]
+		rows = append(rows, Row{Key: "HTTP error rate", ValueMajor: fmt.Sprintf("%.1f", float64(errors)*100/float64(responses)), ValueMinor: "% 5xx"})
+	}
+	for _, p := range []float64{50, 95, 99} {
+		if latency, ok := emd.HTTPLatencyPercentile(p); ok {
+			rows = append(rows, Row{Key: fmt.Sprintf("HTTP latency (p%.0f)", p), ValueMajor: latency.String(), ValueMinor: "or less"})
+		}
+	}
 	if len(connections) > 0 {
 		sort.Sort(sortableRows(connections))
 		rows = append(rows, Row{Key: "Client", ValueMajor: "Server", Expandable: true})
@@ -203,6 +233,15 @@ func connectionsTable(connections []Row, r report.Report, n RenderableNode) (Tab
 	return Table{}, false
 }
 
+// httpResponseCount is the number of HTTP responses on the edges.
+func httpResponseCount(emd report.EdgeMetadata) uint64 {
+	total := uint64(0)
+	for _, count := range emd.HTTPStatusClassCounts {
+		total += count
+	}
+	return total
+}
+
 func controlsFor(topology report.Topology, nodeID string) []ControlInstance {
 	result := []ControlInstance{}
 	node, ok := topology.Nodes[nodeID]
@@ -259,9 +298,9 @@ func connectionDetailsRows(topology report.Topology, originID string) []Row {
 	labeler := func(nodeID string, sets report.Sets) (string, bool) {
 		if _, addr, port, ok := report.ParseEndpointNodeID(nodeID); ok {
 			if names, ok := sets["name"]; ok {
-				return fmt.Sprintf("%s:%s", names[0], port), true
+				return net.JoinHostPort(names[0], port), true
 			}
-			return fmt.Sprintf("%s:%s", addr, port), true
+			return net.JoinHostPort(addr, port), true
 		}
 		if _, addr, ok := report.ParseAddressNodeID(nodeID); ok {
 			return addr, true
@@ -319,6 +358,8 @@ func processOriginTable(nmd report.Node, addHostTag bool, addContainerTag bool)
 		}
 	}
 
+	rows = append(rows, listeningPortsRows(nmd)...)
+
 	if containerID, ok := nmd.Metadata[docker.ContainerID]; ok && addContainerTag {
 		rows = append([]Row{{Key: "Container ID", ValueMajor: containerID}}, rows...)
 	}
@@ -358,6 +399,14 @@ func processOriginTable(nmd report.Node, addHostTag bool, addContainerTag bool)
 	}, len(rows) > 0 || commFound || pidFound
 }
 
+func listeningPortsRows(nmd report.Node) []Row {
+	ports, ok := nmd.Sets[process.ListeningPorts]
+	if !ok || len(ports) == 0 {
+		return nil
+	}
+	return []Row{{Key: "Listening ports", ValueMajor: strings.Join(ports, ", "), ValueMinor: ""}}
+}
+
 type formatter func(report.Metric) (report.Metric, string)
 
 func sparklineRow(human string, metric report.Metric, format formatter) Row {
@@ -412,6 +461,96 @@ func formatPercent(m report.Metric) (report.Metric, string) {
 	return m, ""
 }
 
+func formatByteRate(m report.Metric) (report.Metric, string) {
+	m, s := formatMemory(m)
+	if s == "" {
+		return m, ""
+	}
+	return m, s + "/s"
+}
+
+func formatRate(m report.Metric) (report.Metric, string) {
+	if s := m.LastSample(); s != nil {
+		return m, fmt.Sprintf("%0.2f/s", s.Value)
+	}
+	return m, ""
+}
+
+// containerMetricRows are the sparklines of the metrics of a container, or
+// of the totals of a group of them.
+func containerMetricRows(metrics report.Metrics) []Row {
+	rows := []Row{}
+	for _, tuple := range []struct {
+		key, human string
+		fmt        formatter
+	}{
+		{docker.MemoryUsage, "Memory Usage", formatMemory},
+		{docker.CPUTotalUsage, "CPU Usage", formatPercent},
+		{docker.BlkioReadBytesRate, "Block I/O Read", formatByteRate},
+		{docker.BlkioWriteBytesRate, "Block I/O Write", formatByteRate},
+		{docker.BlkioReadIOPS, "Block I/O Read IOPS", formatDefault},
+		{docker.BlkioWriteIOPS, "Block I/O Write IOPS", formatDefault},
+	} {
+		if val, ok := metrics[tuple.key]; ok {
+			rows = append(rows, sparklineRow(tuple.human, val, tuple.fmt))
+		}
+	}
+
+	interfaces := report.MakeStringSet()
+	for key := range metrics {
+		if _, iface, ok := docker.ParseNetworkMetricKey(key); ok {
+			interfaces = interfaces.Add(iface)
+		}
+	}
+	for _, iface := range interfaces {
+		for _, tuple := range []struct {
+			metric, human string
+			fmt           formatter
+		}{
+			{docker.NetworkRxBytesRate, "Network Rx", formatByteRate},
+			{docker.NetworkTxBytesRate, "Network Tx", formatByteRate},
+			{docker.NetworkRxPacketsRate, "Network Rx Packets", formatRate},
+			{docker.NetworkTxPacketsRate, "Network Tx Packets", formatRate},
+			{docker.NetworkRxErrorsRate, "Network Rx Errors", formatRate},
+			{docker.NetworkTxErrorsRate, "Network Tx Errors", formatRate},
+		} {
+			if val, ok := metrics[docker.NetworkMetricKey(tuple.metric, iface)]; ok {
+				rows = append(rows, sparklineRow(fmt.Sprintf("%s (%s)", tuple.human, iface), val, tuple.fmt))
+			}
+		}
+	}
+	return rows
+}
+
+// containersTable totals the metrics of the containers the node is made of,
+// if there's more than one, e.g. of all the containers of an image.
+func containersTable(r report.Report, n RenderableNode) (Table, bool) {
+	var (
+		metrics    = report.Metrics{}
+		containers = 0
+	)
+	for _, id := range n.Origins {
+		nmd, ok := r.Container.Nodes[id]
+		if !ok {
+			continue
+		}
+		containers++
+		for key, metric := range nmd.Metrics {
+			metrics[key] = metrics[key].Sum(metric)
+		}
+	}
+	if containers < 2 {
+		return Table{}, false
+	}
+	rows := containerMetricRows(metrics)
+	return Table{
+		Title:   fmt.Sprintf("Containers (%d)", containers),
+		Numeric: false,
+		Rows:    rows,
+		Rank:    containersRank,
+	}, len(rows) > 0
+}
+
 func containerOriginTable(nmd report.Node, addHostTag bool) (Table, bool) {
 	rows := []Row{}
 	for _, tuple := range []struct{ key, human string }{
@@ -439,18 +578,14 @@ func containerOriginTable(nmd report.Node, addHostTag bool) (Table, bool) {
 	for _, ip := range docker.ExtractContainerIPs(nmd) {
 		rows = append(rows, Row{Key: "IP Address", ValueMajor: ip, ValueMinor: ""})
 	}
+	rows = append(rows, listeningPortsRows(nmd)...)
 	rows = append(rows, getDockerLabelRows(nmd)...)
 
 	if addHostTag {
 		rows = append([]Row{{Key: "Host", ValueMajor: report.ExtractHostID(nmd)}}, rows...)
 	}
 
-	if val, ok := nmd.Metrics[docker.MemoryUsage]; ok {
-		rows = append(rows, sparklineRow("Memory Usage", val, formatMemory))
-	}
-	if val, ok := nmd.Metrics[docker.CPUTotalUsage]; ok {
-		rows = append(rows, sparklineRow("CPU Usage", val, formatPercent))
-	}
+	rows = append(rows, containerMetricRows(nmd.Metrics)...)
 
 	var (
 		title           = "Container"
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 	"github.com/weaveworks/scope/test/fixture"
 )
@@ -163,7 +166,7 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 			{
 				Title:   `Container Image "image/server"`,
 				Numeric: false,
-				Rank:    4,
+				Rank:    5,
 				Rows: []render.Row{
 					{Key: "Image ID", ValueMajor: fixture.ServerContainerImageID},
 					{Key: `Label "foo1"`, ValueMajor: `bar1`},
@@ -208,6 +211,7 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 				Rows: []render.Row{
 					{Key: "Ingress packet rate", ValueMajor: "105", ValueMinor: "packets/sec"},
 					{Key: "Ingress byte rate", ValueMajor: "1.0", ValueMinor: "KBps"},
+					{Key: "Sampling rate", ValueMajor: "25", ValueMinor: "%"},
 					{Key: "Client", ValueMajor: "Server", Expandable: true},
 					{
 						Key:        fmt.Sprintf("%s:%s", fixture.UnknownClient1IP, fixture.UnknownClient1Port),
@@ -247,3 +251,55 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
 }
+
+func TestMakeDetailedContainersNode(t *testing.T) {
+	var (
+		now  = time.Now()
+		rpt  = report.MakeReport()
+		ids  = []string{report.MakeContainerNodeID("host", "a"), report.MakeContainerNodeID("host", "b")}
+		rxs  = []float64{100, 200}
+		read = []float64{1024, 2048}
+		mem  = []float64{1 << 20, 2 << 20}
+		cpu  = []float64{10, 20}
+	)
+	for i, id := range ids {
+		rpt.Container = rpt.Container.AddNode(id, report.MakeNodeWith(map[string]string{
+			docker.ContainerID: id,
+		}).WithMetrics(report.Metrics{
+			docker.MemoryUsage:        report.MakeMetric().Add(now, mem[i]),
+			docker.CPUTotalUsage:      report.MakeMetric().Add(now, cpu[i]),
+			docker.BlkioReadBytesRate: report.MakeMetric().Add(now, read[i]),
+			docker.NetworkMetricKey(docker.NetworkRxBytesRate, "eth0"): report.MakeMetric().Add(now, rxs[i]),
+		}))
+	}
+	n := render.NewRenderableNode("image")
+	n.Origins = report.MakeIDList(ids...)
+
+	var (
+		memMetric  = report.MakeMetric().Add(now, 3<<20).Div(1 << 20)
+		cpuMetric  = report.MakeMetric().Add(now, 30)
+		readMetric = report.MakeMetric().Add(now, 3072).Div(1024)
+		rxMetric   = report.MakeMetric().Add(now, 300)
+	)
+	want := render.Table{
+		Title:   "Containers (2)",
+		Numeric: false,
+		Rank:    4,
+		Rows: []render.Row{
+			{Key: "Memory Usage", ValueMajor: "3.00 MB", Metric: &memMetric, ValueType: "sparkline"},
+			{Key: "CPU Usage", ValueMajor: "30.00%", Metric: &cpuMetric, ValueType: "sparkline"},
+			{Key: "Block I/O Read", ValueMajor: "3.00 KB/s", Metric: &readMetric, ValueType: "sparkline"},
+			{Key: "Network Rx (eth0)", ValueMajor: "300.00 bytes/s", Metric: &rxMetric, ValueType: "sparkline"},
+		},
+	}
+	for _, have := range render.MakeDetailedNode(rpt, n).Tables {
+		if have.Title != want.Title {
+			continue
+		}
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("%s", test.Diff(want, have))
+		}
+		return
+	}
+	t.Errorf("no %q table", want.Title)
+}
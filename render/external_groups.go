@@ -0,0 +1,178 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
+)
+
+// ExternalGroup is a named group of remote addresses, by network and/or by
+// hostname, e.g.
+//
+//	{"name": "AWS us-east-1", "cidrs": ["52.0.0.0/11", "54.80.0.0/13"]}
+//	{"name": "RDS", "hostnames": ["*.rds.amazonaws.com"]}
+//
+// Hostnames are patterns as understood by path.Match, and are matched
+// against the names the probes resolved the addresses by. Remote nodes in a
+// group are rendered as a pseudo node for the group, instead of as part of
+// The Internet.
+type ExternalGroup struct {
+	Name      string   `json:"name"`
+	CIDRs     []string `json:"cidrs,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	networks report.Networks
+}
+
+// ExternalGroups are groups of remote nodes, matched in order, so the first
+// group an address is in wins.
+type ExternalGroups []ExternalGroup
+
+// Metadata and set keys with which Apply tags the nodes in a group.
+const (
+	ExternalGroupName      = "external_group"
+	ExternalGroupCIDRs     = "external_group_cidrs"
+	ExternalGroupHostnames = "external_group_hostnames"
+)
+
+// LoadExternalGroups reads a JSON list of groups from a file.
+func LoadExternalGroups(filename string) (ExternalGroups, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := []ExternalGroup{}
+	if err := json.NewDecoder(f).Decode(&groups); err != nil {
+		return nil, err
+	}
+	return MakeExternalGroups(groups)
+}
+
+// MakeExternalGroups checks the groups, and makes ExternalGroups of them.
+func MakeExternalGroups(groups []ExternalGroup) (ExternalGroups, error) {
+	names := map[string]struct{}{}
+	result := make(ExternalGroups, 0, len(groups))
+	for _, g := range groups {
+		if err := g.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := names[g.Name]; ok {
+			return nil, fmt.Errorf("duplicate external group %q", g.Name)
+		}
+		names[g.Name] = struct{}{}
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+// Apply tags the endpoint and address nodes of the report which are in one
+// of the groups, so they're rendered as part of it when they're remote. The
+// report is modified, and returned.
+func (gs ExternalGroups) Apply(rpt report.Report) report.Report {
+	if len(gs) == 0 {
+		return rpt
+	}
+	for id, n := range rpt.Endpoint.Nodes {
+		addr, ok := n.Metadata[endpoint.Addr]
+		if !ok {
+			_, addr, _, _ = report.ParseEndpointNodeID(id)
+		}
+		rpt.Endpoint.Nodes[id] = gs.tag(addr, n)
+	}
+	for id, n := range rpt.Address.Nodes {
+		rpt.Address.Nodes[id] = gs.tag(n.Metadata[endpoint.Addr], n)
+	}
+	return rpt
+}
+
+// tag tags the node at the address with the first group it's in.
+func (gs ExternalGroups) tag(addr string, n report.Node) report.Node {
+	for _, g := range gs {
+		if !g.matches(addr, n) {
+			continue
+		}
+		return n.WithMetadata(map[string]string{
+			ExternalGroupName: g.Name,
+		}).WithSets(report.Sets{
+			ExternalGroupCIDRs:     report.MakeStringSet(g.CIDRs...),
+			ExternalGroupHostnames: report.MakeStringSet(g.Hostnames...),
+		})
+	}
+	return n
+}
+
+// validate checks the group, and parses its networks.
+func (g *ExternalGroup) validate() error {
+	if g.Name == "" {
+		return fmt.Errorf("external group without a name")
+	}
+	if len(g.CIDRs) == 0 && len(g.Hostnames) == 0 {
+		return fmt.Errorf("external group %q: no cidrs or hostnames", g.Name)
+	}
+	g.networks = report.Networks{}
+	for _, cidr := range g.CIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("external group %q: %v", g.Name, err)
+		}
+		g.networks = append(g.networks, ipNet)
+	}
+	for _, pattern := range g.Hostnames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("external group %q: bad hostname pattern %q", g.Name, pattern)
+		}
+	}
+	return nil
+}
+
+// matches is true if the address is in one of the group's networks, or if
+// one of the names of the node matches one of its hostnames.
+func (g ExternalGroup) matches(addr string, n report.Node) bool {
+	if ip := net.ParseIP(addr); ip != nil && g.networks.Contains(ip) {
+		return true
+	}
+	for _, names := range []report.StringSet{n.Sets["name"], n.Sets[endpoint.SnoopedDNSNames]} {
+		for _, name := range names {
+			for _, pattern := range g.Hostnames {
+				if ok, _ := path.Match(pattern, name); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// MakeExternalGroupID produces the ID of the pseudo node for the external
+// group.
+func MakeExternalGroupID(name string) string {
+	return MakePseudoNodeID(TheInternetID, "group", name)
+}
+
+// externalGroupTable describes the external group of the pseudo node, if
+// it's one.
+func externalGroupTable(n RenderableNode) (Table, bool) {
+	name, ok := n.Metadata[ExternalGroupName]
+	if !ok || n.ID != MakeExternalGroupID(name) {
+		return Table{}, false
+	}
+	rows := []Row{{Key: "Name", ValueMajor: name}}
+	for _, cidr := range n.Sets[ExternalGroupCIDRs] {
+		rows = append(rows, Row{Key: "Network", ValueMajor: cidr})
+	}
+	for _, pattern := range n.Sets[ExternalGroupHostnames] {
+		rows = append(rows, Row{Key: "Hostname", ValueMajor: pattern})
+	}
+	return Table{
+		Title: "External group",
+		Rank:  externalGroupRank,
+		Rows:  rows,
+	}, true
+}
@@ -0,0 +1,106 @@
+package render_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestLoadExternalGroups(t *testing.T) {
+	f, err := ioutil.TempFile("", "external-groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`[
+		{"name": "corporate VPN", "cidrs": ["10.8.0.0/16"]},
+		{"name": "RDS", "hostnames": ["*.rds.amazonaws.com"]}
+	]`)
+	f.Close()
+
+	groups, err := render.LoadExternalGroups(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(groups); want != have {
+		t.Errorf("want %d groups, have %d", want, have)
+	}
+
+	for _, bad := range [][]render.ExternalGroup{
+		{{Name: "no networks"}},
+		{{Name: "bad cidr", CIDRs: []string{"10.0.0.0/33"}}},
+		{{Name: "bad pattern", Hostnames: []string{"[.example.com"}}},
+		{{Name: "dup", CIDRs: []string{"1.0.0.0/8"}}, {Name: "dup", CIDRs: []string{"2.0.0.0/8"}}},
+	} {
+		if _, err := render.MakeExternalGroups(bad); err == nil {
+			t.Errorf("want error for %v", bad)
+		}
+	}
+}
+
+func TestMapExternalGroups(t *testing.T) {
+	groups, err := render.MakeExternalGroups([]render.ExternalGroup{
+		{Name: "partner API", CIDRs: []string{"203.0.113.0/24"}},
+		{Name: "RDS", Hostnames: []string{"*.rds.amazonaws.com"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nodes are only in a group once the groups are applied to their report.
+	endpointNode := func(addr string, names ...string) render.RenderableNode {
+		id := report.MakeEndpointNodeID("", addr, "443")
+		n := report.MakeNodeWith(map[string]string{endpoint.Addr: addr, endpoint.Port: "443", endpoint.Procspied: "true"})
+		if len(names) > 0 {
+			n = n.WithSet(endpoint.SnoopedDNSNames, report.MakeStringSet(names...))
+		}
+		rpt := report.MakeReport()
+		rpt.Endpoint.AddNode(id, n)
+		return nrn(groups.Apply(rpt).Endpoint.Nodes[id])
+	}
+	localNetworks := report.ParseNetworks("10.0.0.0/8")
+	for _, tc := range []struct {
+		node   render.RenderableNode
+		wantID string
+	}{
+		{endpointNode("203.0.113.7"), render.MakeExternalGroupID("partner API")},
+		{endpointNode("54.1.2.3", "mydb.abc.us-east-1.rds.amazonaws.com"), render.MakeExternalGroupID("RDS")},
+		{endpointNode("54.1.2.4", "api.stripe.com"), render.MakePseudoNodeID(render.TheInternetID, "api.stripe.com")},
+		{endpointNode("54.1.2.5"), render.TheInternetID},
+	} {
+		have := render.MapEndpointIdentity(tc.node, localNetworks)
+		if _, ok := have[tc.wantID]; !ok || len(have) != 1 {
+			t.Errorf("%s: want %s, have %v", tc.node.Metadata[endpoint.Addr], tc.wantID, have)
+		}
+	}
+
+	// Without the groups, remote nodes are part of The Internet
+	ungrouped := nrn(report.MakeNodeWith(map[string]string{endpoint.Addr: "203.0.113.7", endpoint.Port: "443", endpoint.Procspied: "true"}))
+	if have := render.MapEndpointIdentity(ungrouped, localNetworks); len(have) != 1 || have[render.TheInternetID].ID == "" {
+		t.Errorf("want %s, have %v", render.TheInternetID, have)
+	}
+
+	// Group nodes have a table describing the group
+	node := render.MapEndpointIdentity(endpointNode("203.0.113.7"), localNetworks)[render.MakeExternalGroupID("partner API")]
+	detailed := render.MakeDetailedNode(report.MakeReport(), node)
+	if want, have := "partner API", detailed.LabelMajor; want != have {
+		t.Errorf("want label %q, have %q", want, have)
+	}
+	want := []render.Table{{
+		Title: "External group",
+		Rank:  6,
+		Rows: []render.Row{
+			{Key: "Name", ValueMajor: "partner API"},
+			{Key: "Network", ValueMajor: "203.0.113.0/24"},
+		},
+	}}
+	if !reflect.DeepEqual(want, detailed.Tables) {
+		t.Errorf("%s", test.Diff(want, detailed.Tables))
+	}
+}
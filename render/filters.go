@@ -5,6 +5,7 @@ import (
 
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -120,17 +121,23 @@ func (f Filter) Stats(rpt report.Report) Stats {
 const IsConnected = "is_connected"
 
 // FilterUnconnected produces a renderer that filters unconnected nodes
-// from the given renderer
+// from the given renderer. Nodes listening on ports are kept, as they are
+// services which just have no clients right now.
 func FilterUnconnected(r Renderer) Renderer {
 	return Filter{
 		Renderer: ColorConnected(r),
 		FilterFunc: func(node RenderableNode) bool {
 			_, ok := node.Metadata[IsConnected]
-			return ok
+			return ok || isListening(node)
 		},
 	}
 }
 
+// isListening is true for nodes with processes listening on ports.
+func isListening(node RenderableNode) bool {
+	return len(node.Sets[process.ListeningPorts]) > 0
+}
+
 // FilterNoop does nothing.
 func FilterNoop(in Renderer) Renderer {
 	return in
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
@@ -26,6 +27,22 @@ func TestFilterRender(t *testing.T) {
 	}
 }
 
+func TestFilterUnconnectedListening(t *testing.T) {
+	listening := report.MakeNode().WithSet(process.ListeningPorts, report.MakeStringSet("80/tcp"))
+	renderer := render.FilterUnconnected(
+		mockRenderer{RenderableNodes: render.RenderableNodes{
+			"foo": {ID: "foo", Node: listening},
+			"bar": {ID: "bar", Node: report.MakeNode()},
+		}})
+	want := render.RenderableNodes{
+		"foo": {ID: "foo", Node: report.MakeNode()},
+	}
+	have := renderer.Render(report.MakeReport()).Prune()
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
 func TestFilterRender2(t *testing.T) {
 	// Test adjacencies are removed for filtered nodes.
 	renderer := render.Filter{
@@ -62,7 +62,7 @@ func MapEndpointIdentity(m RenderableNode, local report.Networks) RenderableNode
 		// If the dstNodeAddr is not in a network local to this report, we emit an
 		// internet node
 		if ip := net.ParseIP(addr); ip != nil && !local.Contains(ip) {
-			return RenderableNodes{TheInternetID: newDerivedPseudoNode(TheInternetID, TheInternetMajor, m)}
+			return theInternetNodes(m)
 		}
 
 		// We are a 'client' pseudo node if the port is in the ephemeral port range.
@@ -79,16 +79,17 @@ func MapEndpointIdentity(m RenderableNode, local report.Networks) RenderableNode
 		// Otherwise (the server node is missing), generate a pseudo node for every (server ip, server port)
 		outputID := MakePseudoNodeID(addr, port)
 		if port != "" {
-			return RenderableNodes{outputID: newDerivedPseudoNode(outputID, addr+":"+port, m)}
+			return RenderableNodes{outputID: newDerivedPseudoNode(outputID, net.JoinHostPort(addr, port), m)}
 		}
 		return RenderableNodes{outputID: newDerivedPseudoNode(outputID, addr, m)}
 	}
 
 	var (
-		id    = MakeEndpointID(report.ExtractHostID(m.Node), addr, port)
-		major = fmt.Sprintf("%s:%s", addr, port)
-		minor = report.ExtractHostID(m.Node)
-		rank  = major
+		scope, _, _, _ = report.ParseEndpointNodeID(m.ID)
+		id             = MakeEndpointID(addressScope(m, scope), addr, port)
+		major          = net.JoinHostPort(addr, port)
+		minor          = report.ExtractHostID(m.Node)
+		rank           = major
 	)
 
 	pid, pidOK := m.Metadata[process.PID]
@@ -123,7 +124,7 @@ func MapProcessIdentity(m RenderableNode, _ report.Networks) RenderableNodes {
 // expect that certain keys are present.
 func MapContainerIdentity(m RenderableNode, _ report.Networks) RenderableNodes {
 	id, ok := m.Metadata[docker.ContainerID]
-	if !ok {
+	if !ok || isDeletedContainer(m) {
 		return RenderableNodes{}
 	}
 
@@ -142,6 +143,13 @@ func MapContainerIdentity(m RenderableNode, _ report.Networks) RenderableNodes {
 	return RenderableNodes{id: node}
 }
 
+// isDeletedContainer is true if the probe saw the container being destroyed,
+// in which case its node only lingers until the report it's in expires.
+func isDeletedContainer(m RenderableNode) bool {
+	state, ok := m.Latest.Lookup(docker.ContainerState)
+	return ok && state == docker.StateDeleted
+}
+
 // GetRenderableContainerName obtains a user-friendly container name, to render in the UI
 func GetRenderableContainerName(nmd report.Node) (string, bool) {
 	// Amazon's ecs-agent produces huge Docker container names, destructively
@@ -232,7 +240,7 @@ func MapAddressIdentity(m RenderableNode, local report.Networks) RenderableNodes
 		// If the addr is not in a network local to this report, we emit an
 		// internet node
 		if !local.Contains(net.ParseIP(addr)) {
-			return RenderableNodes{TheInternetID: newDerivedPseudoNode(TheInternetID, TheInternetMajor, m)}
+			return theInternetNodes(m)
 		}
 
 		// Otherwise generate a pseudo node for every
@@ -245,15 +253,56 @@ func MapAddressIdentity(m RenderableNode, local report.Networks) RenderableNodes
 	}
 
 	var (
-		id    = MakeAddressID(report.ExtractHostID(m.Node), addr)
-		major = addr
-		minor = report.ExtractHostID(m.Node)
-		rank  = major
+		scope, _, _ = report.ParseAddressNodeID(m.ID)
+		id          = MakeAddressID(addressScope(m, scope), addr)
+		major       = addr
+		minor       = report.ExtractHostID(m.Node)
+		rank        = major
 	)
 
 	return RenderableNodes{id: NewRenderableNodeWith(id, major, minor, rank, m)}
 }
 
+// addressScope returns what the node's address is scoped by in its rendered
+// ID: its host, unless the address is local to a network namespace (as per
+// the scope in its node ID), in which case it's that namespace, so the same
+// loopback addresses in different namespaces are rendered separately.
+func addressScope(m RenderableNode, scope string) string {
+	if _, _, ok := report.ParseNetNamespaceScope(scope); ok {
+		return scope
+	}
+	return report.ExtractHostID(m.Node)
+}
+
+// theInternetNodes produces the pseudo node for a node outside of our
+// networks. If it's in one of the external groups (as tagged by
+// ExternalGroups.Apply), it gets the group's pseudo node. Otherwise, if the
+// probes saw the names local processes resolved its address by, it gets a
+// pseudo node per name (e.g. api.stripe.com); failing that, it's part of The
+// Internet.
+func theInternetNodes(m RenderableNode) RenderableNodes {
+	if group, ok := m.Metadata[ExternalGroupName]; ok {
+		id := MakeExternalGroupID(group)
+		node := newDerivedPseudoNode(id, group, m)
+		node.LabelMinor = TheInternetMajor
+		return RenderableNodes{id: node}
+	}
+	names := m.Sets[endpoint.SnoopedDNSNames]
+	if len(names) == 0 {
+		return RenderableNodes{TheInternetID: newDerivedPseudoNode(TheInternetID, TheInternetMajor, m)}
+	}
+	id := MakePseudoNodeID(TheInternetID, names[0])
+	node := newDerivedPseudoNode(id, names[0], m)
+	node.LabelMinor = TheInternetMajor
+	return RenderableNodes{id: node}
+}
+
+// isInternetNode is true of The Internet pseudo node, and of the pseudo
+// nodes for the external groups and names it's broken down into.
+func isInternetNode(n RenderableNode) bool {
+	return n.ID == TheInternetID || strings.HasPrefix(n.ID, MakePseudoNodeID(TheInternetID)+":")
+}
+
 // MapHostIdentity maps a host topology node to a host renderable node. As it
 // is only ever run on host topology nodes, we expect that certain keys are
 // present.
@@ -289,7 +338,7 @@ func MapEndpoint2IP(m RenderableNode, local report.Networks) RenderableNodes {
 		return RenderableNodes{}
 	}
 	if ip := net.ParseIP(addr); ip != nil && !local.Contains(ip) {
-		return RenderableNodes{TheInternetID: newDerivedPseudoNode(TheInternetID, TheInternetMajor, m)}
+		return theInternetNodes(m)
 	}
 
 	// We don't always know what port a container is listening on, and
@@ -312,6 +361,10 @@ var portMappingMatch = regexp.MustCompile(`([0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}\.
 // the endpoint topology.
 func MapContainer2IP(m RenderableNode, _ report.Networks) RenderableNodes {
 	result := RenderableNodes{}
+	if isDeletedContainer(m) {
+		// Its addresses may already belong to another container
+		return result
+	}
 	if addrs, ok := m.Sets[docker.ContainerIPsWithScopes]; ok {
 		for _, addr := range addrs {
 			scope, addr, ok := report.ParseAddressNodeID(addr)
@@ -350,8 +403,8 @@ func MapIP2Container(n RenderableNode, _ report.Networks) RenderableNodes {
 		return RenderableNodes{}
 	}
 
-	// Propogate the internet pseudo node.
-	if n.ID == TheInternetID {
+	// Propogate the internet pseudo nodes.
+	if isInternetNode(n) {
 		return RenderableNodes{n.ID: n}
 	}
 
@@ -403,8 +456,8 @@ func MapEndpoint2Process(n RenderableNode, _ report.Networks) RenderableNodes {
 // It does not have enough info to do that, and the resulting graph
 // must be merged with a container graph to get that info.
 func MapProcess2Container(n RenderableNode, _ report.Networks) RenderableNodes {
-	// Propogate the internet pseudo node
-	if n.ID == TheInternetID {
+	// Propogate the internet pseudo nodes
+	if isInternetNode(n) {
 		return RenderableNodes{n.ID: n}
 	}
 
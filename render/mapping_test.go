@@ -3,6 +3,7 @@ package render_test
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/endpoint"
@@ -29,6 +30,52 @@ func TestMapEndpointIdentity(t *testing.T) {
 	}
 }
 
+func TestRenderNetNamespaceAddresses(t *testing.T) {
+	rpt := report.MakeReport()
+	for _, netns := range []string{"1", "2"} {
+		rpt.Endpoint.AddNode(report.Networks(nil).NetNamespaceEndpointNodeID("foo", netns, "127.0.0.1", "80"), report.MakeNodeWith(map[string]string{
+			report.HostNodeID:  report.MakeHostNodeID("foo"),
+			endpoint.Addr:      "127.0.0.1",
+			endpoint.Port:      "80",
+			endpoint.Procspied: "true",
+		}))
+		rpt.Address.AddNode(report.Networks(nil).NetNamespaceAddressNodeID("foo", netns, "127.0.0.1"), report.MakeNodeWith(map[string]string{
+			report.HostNodeID: report.MakeHostNodeID("foo"),
+			endpoint.Addr:     "127.0.0.1",
+		}))
+	}
+
+	// The same loopback address in each namespace is a different node.
+	for _, tc := range []struct {
+		renderer render.Renderer
+		wantIDs  []string
+	}{
+		{render.EndpointRenderer, []string{
+			render.MakeEndpointID(report.MakeNetNamespaceScope("foo", "1"), "127.0.0.1", "80"),
+			render.MakeEndpointID(report.MakeNetNamespaceScope("foo", "2"), "127.0.0.1", "80"),
+		}},
+		{render.AddressRenderer, []string{
+			render.MakeAddressID(report.MakeNetNamespaceScope("foo", "1"), "127.0.0.1"),
+			render.MakeAddressID(report.MakeNetNamespaceScope("foo", "2"), "127.0.0.1"),
+		}},
+	} {
+		have := tc.renderer.Render(rpt)
+		if len(have) != len(tc.wantIDs) {
+			t.Errorf("want %v, have %v", tc.wantIDs, have)
+		}
+		for _, id := range tc.wantIDs {
+			node, ok := have[id]
+			if !ok {
+				t.Errorf("want %s, have %v", id, have)
+				continue
+			}
+			if node.LabelMinor != "foo" {
+				t.Errorf("%s: want minor label foo, have %q", id, node.LabelMinor)
+			}
+		}
+	}
+}
+
 func TestMapProcessIdentity(t *testing.T) {
 	for _, input := range []testcase{
 		{nrn(report.MakeNode()), false},
@@ -42,6 +89,8 @@ func TestMapContainerIdentity(t *testing.T) {
 	for _, input := range []testcase{
 		{nrn(report.MakeNode()), false},
 		{nrn(report.MakeNodeWith(map[string]string{docker.ContainerID: "a1b2c3"})), true},
+		{nrn(report.MakeNodeWith(map[string]string{docker.ContainerID: "a1b2c3"}).WithLatest(docker.ContainerState, time.Now(), docker.StateStopped)), true},
+		{nrn(report.MakeNodeWith(map[string]string{docker.ContainerID: "a1b2c3"}).WithLatest(docker.ContainerState, time.Now(), docker.StateDeleted)), false},
 	} {
 		testMap(t, render.MapContainerIdentity, input)
 	}
@@ -106,3 +155,35 @@ func testMap(t *testing.T, f render.MapFunc, input testcase) {
 		t.Errorf("%v: want %v, have %v", input.md, input.ok, have)
 	}
 }
+
+func TestMapTheInternetBySnoopedDNSNames(t *testing.T) {
+	var (
+		named = nrn(report.MakeNodeWith(map[string]string{
+			endpoint.Addr: "8.8.8.8", endpoint.Port: "443", endpoint.Procspied: "true",
+		}).WithSet(endpoint.SnoopedDNSNames, report.MakeStringSet("api.stripe.com", "www.stripe.com")))
+		unnamed = nrn(report.MakeNodeWith(map[string]string{
+			endpoint.Addr: "8.8.4.4", endpoint.Port: "443", endpoint.Procspied: "true",
+		}))
+		localNetworks = report.ParseNetworks("1.2.0.0/16")
+		wantID        = render.MakePseudoNodeID(render.TheInternetID, "api.stripe.com")
+	)
+
+	have := render.MapEndpointIdentity(named, localNetworks)
+	node, ok := have[wantID]
+	if !ok || len(have) != 1 {
+		t.Fatalf("want %s, have %v", wantID, have)
+	}
+	if node.LabelMajor != "api.stripe.com" || node.LabelMinor != render.TheInternetMajor || !node.Pseudo {
+		t.Errorf("unexpected node %v", node)
+	}
+	if _, ok := render.MapEndpointIdentity(unnamed, localNetworks)[render.TheInternetID]; !ok {
+		t.Errorf("want %s", render.TheInternetID)
+	}
+
+	// The named nodes make it to the container view, like The Internet does
+	for _, f := range []render.MapFunc{render.MapEndpoint2Process, render.MapProcess2Container} {
+		if _, ok := f(node, localNetworks)[wantID]; !ok {
+			t.Errorf("want %s propagated", wantID)
+		}
+	}
+}
@@ -106,5 +106,29 @@ func (m Map) render(rpt report.Report) (RenderableNodes, map[string]report.IDLis
 		output[outNodeID] = outNode
 	}
 
+	// Rewrite Edges for new node IDs, summing the edges which map to the same
+	// pair of output nodes (e.g. the connections between the processes of
+	// two containers).
+	edges := map[string]report.EdgeMetadatas{} // output node ID -> output Edges
+	for _, inRenderable := range input {
+		for inDst, emd := range inRenderable.Edges {
+			for _, outSrc := range mapped[inRenderable.ID] {
+				for _, outDst := range mapped[inDst] {
+					if edges[outSrc] == nil {
+						edges[outSrc] = report.EdgeMetadatas{}
+					}
+					edges[outSrc][outDst] = edges[outSrc][outDst].Flatten(emd)
+				}
+			}
+		}
+	}
+	for outNodeID, outNode := range output {
+		outNode.Edges = edges[outNodeID]
+		if outNode.Edges == nil {
+			outNode.Edges = report.EdgeMetadatas{}
+		}
+		output[outNodeID] = outNode
+	}
+
 	return output, mapped
 }
@@ -2,7 +2,9 @@ package render_test
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
@@ -97,4 +99,32 @@ func TestMapRender3(t *testing.T) {
 	}
 }
 
+func TestMapRenderEdges(t *testing.T) {
+	// 4. Check we sum the edges between nodes which map to the same nodes
+	var (
+		emd1 = report.EdgeMetadata{}.WithHTTPRequest().WithHTTPResponse(200, 10*time.Millisecond)
+		emd2 = report.EdgeMetadata{}.WithHTTPRequest().WithHTTPResponse(500, time.Second)
+	)
+	mapper := render.Map{
+		MapFunc: func(nodes render.RenderableNode, _ report.Networks) render.RenderableNodes {
+			id := strings.TrimRight(nodes.ID, "0123456789")
+			return render.RenderableNodes{id: render.NewRenderableNode(id)}
+		},
+		Renderer: mockRenderer{RenderableNodes: render.RenderableNodes{
+			"foo1": render.NewRenderableNode("foo1").WithNode(report.MakeNode().WithEdge("bar1", emd1)),
+			"foo2": render.NewRenderableNode("foo2").WithNode(report.MakeNode().WithEdge("bar2", emd2)),
+			"bar1": render.NewRenderableNode("bar1"),
+			"bar2": render.NewRenderableNode("bar2"),
+		}},
+	}
+	want := report.EdgeMetadatas{"bar": emd1.Flatten(emd2)}
+	have := mapper.Render(report.MakeReport())
+	if !reflect.DeepEqual(want, have["foo"].Edges) {
+		t.Error(test.Diff(want, have["foo"].Edges))
+	}
+	if want, have := (report.EdgeMetadatas{}), have["bar"].Edges; !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
 func newu64(value uint64) *uint64 { return &value }
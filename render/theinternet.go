@@ -10,7 +10,8 @@ import (
 // LocalNetworks returns a superset of the networks (think: CIDRs) that are
 // "local" from the perspective of each host represented in the report. It's
 // used to determine which nodes in the report are "remote", i.e. outside of
-// our infrastructure.
+// our infrastructure. Each host's scoped networks (e.g. its Docker bridge)
+// count as local, too.
 func LocalNetworks(r report.Report) report.Networks {
 	var (
 		result   = report.Networks{}
@@ -18,15 +19,17 @@ func LocalNetworks(r report.Report) report.Networks {
 	)
 
 	for _, md := range r.Host.Nodes {
-		for _, s := range md.Sets[host.LocalNetworks] {
-			_, ipNet, err := net.ParseCIDR(s)
-			if err != nil {
-				continue
-			}
-			_, ok := networks[ipNet.String()]
-			if !ok {
-				result = append(result, ipNet)
-				networks[ipNet.String()] = struct{}{}
+		for _, key := range []string{host.LocalNetworks, host.ScopedNetworks} {
+			for _, s := range md.Sets[key] {
+				_, ipNet, err := net.ParseCIDR(s)
+				if err != nil {
+					continue
+				}
+				_, ok := networks[ipNet.String()]
+				if !ok {
+					result = append(result, ipNet)
+					networks[ipNet.String()] = struct{}{}
+				}
 			}
 		}
 	}
@@ -77,14 +77,14 @@ var ProcessNameRenderer = Map{
 // ContainerRenderer is a Renderer which produces a renderable container
 // graph by merging the process graph and the container topology.
 // NB We only want processes in container _or_ processes with network connections
-// but we need to be careful to ensure we only include each edge once, by only
+// (or listening for them) but we need to be careful to ensure we only include each edge once, by only
 // including the ProcessRenderer once.
 var ContainerRenderer = MakeReduce(
 	Filter{
 		FilterFunc: func(n RenderableNode) bool {
 			_, inContainer := n.Node.Metadata[docker.ContainerID]
 			_, isConnected := n.Node.Metadata[IsConnected]
-			return inContainer || isConnected
+			return inContainer || isConnected || isListening(n)
 		},
 		Renderer: ColorConnected(Map{
 			MapFunc:  MapProcess2Container,
@@ -3,6 +3,9 @@ package report
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/weaveworks/scope/common/mtime"
@@ -13,9 +16,93 @@ type Controls map[string]Control
 
 // A Control basically describes an RPC
 type Control struct {
-	ID    string `json:"id"`
-	Human string `json:"human"`
-	Icon  string `json:"icon"` // from https://fortawesome.github.io/Font-Awesome/cheatsheet/ please
+	ID     string         `json:"id"`
+	Human  string         `json:"human"`
+	Icon   string         `json:"icon"`             // from https://fortawesome.github.io/Font-Awesome/cheatsheet/ please
+	Params []ControlParam `json:"params,omitempty"` // arguments of the RPC, if any
+}
+
+// The types of ControlParams.
+const (
+	StringParam = "string"
+	IntParam    = "int"
+	BoolParam   = "bool"
+	EnumParam   = "enum" // one of the Options
+)
+
+// ControlParam describes an argument of a control. Arguments are passed as
+// strings, which must parse as the type of the param. Optional params with a
+// Default take it when they're not passed.
+type ControlParam struct {
+	Name     string   `json:"name"`
+	Human    string   `json:"human"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Default  string   `json:"default,omitempty"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// ValidateArgs checks the arguments of a request for the control: the
+// required ones must be there, they must all parse as the types of their
+// params, and there mustn't be any others.
+func (c Control) ValidateArgs(args map[string]string) error {
+	params := map[string]ControlParam{}
+	for _, p := range c.Params {
+		params[p.Name] = p
+		if _, ok := args[p.Name]; p.Required && !ok {
+			return fmt.Errorf("%s: missing argument %q", c.ID, p.Name)
+		}
+	}
+	for name, value := range args {
+		p, ok := params[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown argument %q", c.ID, name)
+		}
+		if err := p.validate(value); err != nil {
+			return fmt.Errorf("%s: argument %q: %v", c.ID, name, err)
+		}
+	}
+	return nil
+}
+
+// WithDefaults returns a copy of the arguments, with the defaults of the
+// params which weren't passed.
+func (c Control) WithDefaults(args map[string]string) map[string]string {
+	result := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		if p.Default != "" {
+			result[p.Name] = p.Default
+		}
+	}
+	for name, value := range args {
+		result[name] = value
+	}
+	return result
+}
+
+func (p ControlParam) validate(value string) error {
+	var err error
+	switch p.Type {
+	case StringParam:
+		return nil
+	case IntParam:
+		_, err = strconv.Atoi(value)
+	case BoolParam:
+		_, err = strconv.ParseBool(value)
+	case EnumParam:
+		for _, option := range p.Options {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(p.Options, ", "))
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not of type %s", value, p.Type)
+	}
+	return nil
 }
 
 // Merge merges other with cs, returning a fresh Controls.
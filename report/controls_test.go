@@ -0,0 +1,58 @@
+package report_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestControlValidateArgs(t *testing.T) {
+	control := report.Control{
+		ID: "kill",
+		Params: []report.ControlParam{
+			{Name: "signal", Type: report.StringParam, Required: true},
+			{Name: "timeout", Type: report.IntParam},
+			{Name: "force", Type: report.BoolParam},
+			{Name: "mode", Type: report.EnumParam, Options: []string{"soft", "hard"}},
+		},
+	}
+	for _, tc := range []struct {
+		args map[string]string
+		ok   bool
+	}{
+		{map[string]string{"signal": "TERM"}, true},
+		{map[string]string{"signal": "TERM", "timeout": "10", "force": "true"}, true},
+		{nil, false},
+		{map[string]string{"signal": "TERM", "timeout": "ten"}, false},
+		{map[string]string{"signal": "TERM", "force": "maybe"}, false},
+		{map[string]string{"signal": "TERM", "foo": "bar"}, false},
+		{map[string]string{"signal": "TERM", "mode": "hard"}, true},
+		{map[string]string{"signal": "TERM", "mode": "medium"}, false},
+	} {
+		if err := control.ValidateArgs(tc.args); (err == nil) != tc.ok {
+			t.Errorf("%v: want ok=%v, have %v", tc.args, tc.ok, err)
+		}
+	}
+}
+
+func TestControlWithDefaults(t *testing.T) {
+	control := report.Control{
+		ID: "exec",
+		Params: []report.ControlParam{
+			{Name: "command", Type: report.StringParam, Default: "/bin/sh"},
+			{Name: "user", Type: report.StringParam},
+		},
+	}
+	for _, tc := range []struct {
+		args, want map[string]string
+	}{
+		{nil, map[string]string{"command": "/bin/sh"}},
+		{map[string]string{"user": "root"}, map[string]string{"command": "/bin/sh", "user": "root"}},
+		{map[string]string{"command": "top"}, map[string]string{"command": "top"}},
+	} {
+		if have := control.WithDefaults(tc.args); !reflect.DeepEqual(tc.want, have) {
+			t.Errorf("%v: want %v, have %v", tc.args, tc.want, have)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"reflect"
+)
+
+// Delta describes how to turn one report from a probe into the next. Probes
+// publish full reports periodically, and deltas in between, such that
+// unchanged nodes don't need to be sent (or decoded) again.
+type Delta struct {
+	// Seq is the sequence number of the report this delta produces, and
+	// BaseSeq that of the report it applies to. Full deltas have no base,
+	// and replace whatever report they are applied to.
+	Seq, BaseSeq uint64
+	Full         bool
+
+	// Report holds the nodes which were added or changed, in full, and the
+	// topology controls. Its Sampling, Window and Shortcut fields replace
+	// those of the report the delta is applied to.
+	Report Report
+
+	// Removed holds the IDs of the nodes which were removed, keyed by
+	// topology name.
+	Removed map[string]IDList
+
+	// Metrics holds the metrics of the nodes for which nothing else
+	// changed, keyed by topology name and node ID. They replace the
+	// metrics of those nodes. Metrics change with every report, so this
+	// saves sending the rest of those nodes again.
+	Metrics map[string]map[string]Metrics
+}
+
+// MakeFullDelta makes a full delta, which turns any report into r.
+func MakeFullDelta(seq uint64, r Report) Delta {
+	return Delta{
+		Seq:     seq,
+		Full:    true,
+		Report:  r,
+		Removed: map[string]IDList{},
+		Metrics: map[string]map[string]Metrics{},
+	}
+}
+
+// Diff returns a delta which turns r into next when applied to it. Nodes are
+// considered changed unless they are deeply equal, ignoring their metrics;
+// nodes for which only the metrics changed only have their metrics sent.
+// Sequence numbers are left to the caller.
+func (r Report) Diff(next Report) Delta {
+	d := Delta{
+		Report:  MakeReport(),
+		Removed: map[string]IDList{},
+		Metrics: map[string]map[string]Metrics{},
+	}
+	d.Report.Sampling = next.Sampling
+	d.Report.Window = next.Window
+	d.Report.Shortcut = next.Shortcut
+
+	var (
+		prevs   = r.topologiesByName()
+		nexts   = next.topologiesByName()
+		changes = d.Report.topologiesByName()
+	)
+	for name, t := range nexts {
+		prev, changed := prevs[name], changes[name]
+		for id, node := range t.Nodes {
+			old, ok := prev.Nodes[id]
+			switch {
+			case !ok || !reflect.DeepEqual(withoutMetrics(old), withoutMetrics(node)):
+				changed.Nodes[id] = node
+			case !reflect.DeepEqual(old.Metrics, node.Metrics):
+				if d.Metrics[name] == nil {
+					d.Metrics[name] = map[string]Metrics{}
+				}
+				d.Metrics[name][id] = node.Metrics
+			}
+		}
+		for id := range prev.Nodes {
+			if _, ok := t.Nodes[id]; !ok {
+				d.Removed[name] = d.Removed[name].Add(id)
+			}
+		}
+		changed.Controls = t.Controls.Copy()
+	}
+	return d
+}
+
+// Apply applies a delta to the report, returning the result. The original is
+// not modified.
+func (r Report) Apply(d Delta) Report {
+	if d.Full {
+		return d.Report
+	}
+
+	result := r
+	var (
+		results = result.topologiesByName()
+		changes = d.Report.topologiesByName()
+	)
+	for name, t := range results {
+		changed := changes[name]
+		nodes := make(Nodes, len(t.Nodes)+len(changed.Nodes))
+		for id, node := range t.Nodes {
+			nodes[id] = node
+		}
+		for id, node := range changed.Nodes {
+			nodes[id] = node
+		}
+		for id, metrics := range d.Metrics[name] {
+			if node, ok := nodes[id]; ok {
+				node.Metrics = metrics
+				nodes[id] = node
+			}
+		}
+		for _, id := range d.Removed[name] {
+			delete(nodes, id)
+		}
+		*t = Topology{
+			Nodes:    nodes,
+			Controls: t.Controls.Merge(changed.Controls),
+		}
+	}
+	result.Sampling = d.Report.Sampling
+	result.Window = d.Report.Window
+	result.Shortcut = d.Report.Shortcut
+	return result
+}
+
+func withoutMetrics(n Node) Node {
+	n.Metrics = nil
+	return n
+}
@@ -0,0 +1,99 @@
+package report_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestDeltaDiffApply(t *testing.T) {
+	prev := report.MakeReport()
+	prev.Endpoint.AddNode("a", report.MakeNodeWith(map[string]string{"foo": "bar"}))
+	prev.Endpoint.AddNode("b", report.MakeNodeWith(map[string]string{"foo": "baz"}))
+	prev.Host.AddNode("c", report.MakeNodeWith(map[string]string{"os": "linux"}))
+
+	next := report.MakeReport()
+	next.Endpoint.AddNode("a", report.MakeNodeWith(map[string]string{"foo": "bar"}))
+	next.Endpoint.AddNode("d", report.MakeNodeWith(map[string]string{"foo": "qux"}))
+	next.Host.AddNode("c", report.MakeNodeWith(map[string]string{"os": "linux", "load": "1"}))
+	next.Sampling = report.Sampling{Count: 1, Total: 2}
+
+	delta := prev.Diff(next)
+	if want, have := 1, len(delta.Report.Endpoint.Nodes); want != have {
+		t.Errorf("want %d changed endpoints, have %d", want, have)
+	}
+	if want, have := 1, len(delta.Report.Host.Nodes); want != have {
+		t.Errorf("want %d changed hosts, have %d", want, have)
+	}
+	if want, have := (map[string]report.IDList{"endpoint": report.MakeIDList("b")}), delta.Removed; !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+
+	if have := prev.Apply(delta); !reflect.DeepEqual(next, have) {
+		t.Errorf("diff: %s", test.Diff(next, have))
+	}
+	if _, ok := prev.Endpoint.Nodes["b"]; !ok {
+		t.Error("Apply modified the original report")
+	}
+}
+
+func TestDeltaDiffMetrics(t *testing.T) {
+	var (
+		t1, t2 = time.Unix(1, 0), time.Unix(2, 0)
+		node   = report.MakeNodeWith(map[string]string{"foo": "bar"})
+	)
+	prev := report.MakeReport()
+	prev.Host.AddNode("a", node.WithMetric("load1", report.MakeMetric().Add(t1, 0.1)))
+	next := report.MakeReport()
+	next.Host.AddNode("a", node.WithMetric("load1", report.MakeMetric().Add(t1, 0.1).Add(t2, 0.2)))
+
+	delta := prev.Diff(next)
+	if want, have := 0, len(delta.Report.Host.Nodes); want != have {
+		t.Errorf("want %d changed hosts, have %d", want, have)
+	}
+	if want, have := next.Host.Nodes["a"].Metrics, delta.Metrics["host"]["a"]; !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+	if have := prev.Apply(delta); !reflect.DeepEqual(next, have) {
+		t.Errorf("diff: %s", test.Diff(next, have))
+	}
+	if want, have := 1, prev.Host.Nodes["a"].Metrics["load1"].Len(); want != have {
+		t.Errorf("Apply modified the original report: want %d samples, have %d", want, have)
+	}
+}
+
+func TestDeltaApplyFull(t *testing.T) {
+	prev := report.MakeReport()
+	prev.Endpoint.AddNode("a", report.MakeNode())
+	next := report.MakeReport()
+	next.Endpoint.AddNode("b", report.MakeNode())
+
+	if have := prev.Apply(report.MakeFullDelta(1, next)); !reflect.DeepEqual(next, have) {
+		t.Errorf("diff: %s", test.Diff(next, have))
+	}
+}
+
+func TestDeltaProtoMarshalling(t *testing.T) {
+	want := report.MakeReport().Diff(report.MakeReport())
+	want.Seq, want.BaseSeq = 2, 1
+	want.Report.Endpoint.AddNode("a", report.MakeNodeWith(map[string]string{"foo": "bar"}))
+	want.Removed["host"] = report.MakeIDList("b", "c")
+	want.Metrics["host"] = map[string]report.Metrics{
+		"d": {"load1": report.MakeMetric().Add(time.Unix(1, 0), 0.1)},
+	}
+
+	b, err := want.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have report.Delta
+	if err := have.UnmarshalProto(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+}
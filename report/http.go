@@ -0,0 +1,116 @@
+package report
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HTTPLatencyBuckets are the upper bounds of the buckets HTTP response
+// latencies are counted in on edges. There's one more bucket, for anything
+// slower. Counts in buckets can be summed, unlike percentiles, so they roll
+// up as edges do.
+var HTTPLatencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// HTTPStatusClass returns the class of the status code, e.g. "4xx" for 404.
+func HTTPStatusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// WithHTTPRequest returns a copy of the EdgeMetadata, with one more HTTP
+// request.
+func (e EdgeMetadata) WithHTTPRequest() EdgeMetadata {
+	one := uint64(1)
+	return e.Merge(EdgeMetadata{HTTPRequestCount: &one})
+}
+
+// WithHTTPResponse returns a copy of the EdgeMetadata, with one more HTTP
+// response of the status code, which took latency to arrive after its
+// request. A negative latency means it's unknown.
+func (e EdgeMetadata) WithHTTPResponse(code int, latency time.Duration) EdgeMetadata {
+	response := EdgeMetadata{
+		HTTPStatusClassCounts: map[string]uint64{HTTPStatusClass(code): 1},
+	}
+	if latency >= 0 {
+		response.HTTPLatencyCounts = make([]uint64, len(HTTPLatencyBuckets)+1)
+		response.HTTPLatencyCounts[sort.Search(len(HTTPLatencyBuckets), func(i int) bool {
+			return latency <= HTTPLatencyBuckets[i]
+		})] = 1
+	}
+	return e.Merge(response)
+}
+
+// HTTPLatencyPercentile returns the upper bound of the latency bucket of the
+// p-th percentile (0 < p <= 100) of the HTTP responses on the edge, if any
+// latencies were counted. If the percentile is in the last bucket, the
+// result is the largest bound, as there's no upper one.
+func (e EdgeMetadata) HTTPLatencyPercentile(p float64) (time.Duration, bool) {
+	total := uint64(0)
+	for _, count := range e.HTTPLatencyCounts {
+		total += count
+	}
+	if total == 0 {
+		return 0, false
+	}
+	rank := uint64(float64(total)*p/100 + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	seen := uint64(0)
+	for i, count := range e.HTTPLatencyCounts {
+		seen += count
+		if seen >= rank && i < len(HTTPLatencyBuckets) {
+			return HTTPLatencyBuckets[i], true
+		}
+	}
+	return HTTPLatencyBuckets[len(HTTPLatencyBuckets)-1], true
+}
+
+// sumCounts returns a new map of the sums of the counts in a and b.
+func sumCounts(a, b map[string]uint64) map[string]uint64 {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	result := make(map[string]uint64, len(a)+len(b))
+	for k, v := range a {
+		result[k] += v
+	}
+	for k, v := range b {
+		result[k] += v
+	}
+	return result
+}
+
+// sumBuckets returns a new slice of the sums of the counts in each bucket of
+// a and b.
+func sumBuckets(a, b []uint64) []uint64 {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	result := make([]uint64, n)
+	for i, v := range a {
+		result[i] += v
+	}
+	for i, v := range b {
+		result[i] += v
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package report_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestEdgeMetadataHTTP(t *testing.T) {
+	a := report.EdgeMetadata{}
+	for i := 0; i < 8; i++ {
+		a = a.WithHTTPRequest().WithHTTPResponse(200, 3*time.Millisecond)
+	}
+	b := report.EdgeMetadata{}.
+		WithHTTPRequest().WithHTTPResponse(500, 80*time.Millisecond).
+		WithHTTPRequest().WithHTTPResponse(404, 20*time.Second)
+
+	// Requests on different edges roll up
+	have := a.Flatten(b)
+	if want := uint64(10); have.HTTPRequestCount == nil || *have.HTTPRequestCount != want {
+		t.Errorf("want %d requests, have %v", want, have.HTTPRequestCount)
+	}
+	if want := map[string]uint64{"2xx": 8, "4xx": 1, "5xx": 1}; !reflect.DeepEqual(want, have.HTTPStatusClassCounts) {
+		t.Errorf("want %v, have %v", want, have.HTTPStatusClassCounts)
+	}
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 5 * time.Millisecond},
+		{90, 100 * time.Millisecond},
+		{99, 10 * time.Second}, // slower than the slowest bucket
+	} {
+		if latency, ok := have.HTTPLatencyPercentile(tc.p); !ok || latency != tc.want {
+			t.Errorf("p%v: want %v, have %v", tc.p, tc.want, latency)
+		}
+	}
+
+	// The inputs aren't modified
+	if want := map[string]uint64{"2xx": 8}; !reflect.DeepEqual(want, a.HTTPStatusClassCounts) {
+		t.Errorf("want %v, have %v", want, a.HTTPStatusClassCounts)
+	}
+	if _, ok := (report.EdgeMetadata{}).HTTPLatencyPercentile(50); ok {
+		t.Error("want no latency without responses")
+	}
+}
@@ -24,6 +24,10 @@ const (
 	// EdgeDelim separates two node IDs when they need to exist in the same key.
 	// Concretely, it separates node IDs in keys that represent edges.
 	EdgeDelim = "|"
+
+	// netNamespaceDelim separates the host ID and network namespace in the
+	// scope of addresses local to a network namespace.
+	netNamespaceDelim = "/netns/"
 )
 
 var (
@@ -61,21 +65,63 @@ func MakeEndpointNodeID(hostID, address, port string) string {
 }
 
 // MakeAddressNodeID produces an address node ID from its composite parts.
+// Loopback and link-local addresses are scoped by hostID, as they only mean
+// something on that host.
 func MakeAddressNodeID(hostID, address string) string {
-	var scope string
+	return Networks(nil).AddressNodeID(hostID, address)
+}
 
-	// Loopback addresses and addresses explicity marked as
-	// local get scoped by hostID
-	addressIP := net.ParseIP(address)
-	if addressIP != nil && LocalNetworks.Contains(addressIP) {
-		scope = hostID
-	} else if isLoopback(address) {
+// EndpointNodeID is like MakeEndpointNodeID, but also scopes addresses in
+// the networks by hostID.
+func (n Networks) EndpointNodeID(hostID, address, port string) string {
+	return n.AddressNodeID(hostID, address) + ScopeDelim + port
+}
+
+// AddressNodeID is like MakeAddressNodeID, but also scopes addresses in the
+// networks by hostID. The networks are those which are local to the host,
+// such as the Docker bridge, where the same addresses are used on every
+// host.
+func (n Networks) AddressNodeID(hostID, address string) string {
+	var scope string
+	if ip := net.ParseIP(address); ip != nil && (isHostScoped(ip) || n.Contains(ip)) {
 		scope = hostID
 	}
-
 	return scope + ScopeDelim + address
 }
 
+// NetNamespaceAddressNodeID is like AddressNodeID, for an address seen in the
+// network namespace netns on the host. Loopback and link-local addresses
+// overlap between namespaces, so they are scoped by the namespace as well as
+// the host. An empty netns is the host's own namespace.
+func (n Networks) NetNamespaceAddressNodeID(hostID, netns, address string) string {
+	if ip := net.ParseIP(address); netns != "" && ip != nil && isHostScoped(ip) {
+		return MakeScopedAddressNodeID(MakeNetNamespaceScope(hostID, netns), address)
+	}
+	return n.AddressNodeID(hostID, address)
+}
+
+// NetNamespaceEndpointNodeID is like EndpointNodeID, for an address seen in
+// the network namespace netns on the host.
+func (n Networks) NetNamespaceEndpointNodeID(hostID, netns, address, port string) string {
+	return n.NetNamespaceAddressNodeID(hostID, netns, address) + ScopeDelim + port
+}
+
+// MakeNetNamespaceScope produces the scope of the addresses which are local
+// to a network namespace on a host.
+func MakeNetNamespaceScope(hostID, netns string) string {
+	return hostID + netNamespaceDelim + netns
+}
+
+// ParseNetNamespaceScope produces the host ID and network namespace from a
+// scope made by MakeNetNamespaceScope.
+func ParseNetNamespaceScope(scope string) (hostID, netns string, ok bool) {
+	i := strings.LastIndex(scope, netNamespaceDelim)
+	if i < 0 {
+		return "", "", false
+	}
+	return scope[:i], scope[i+len(netNamespaceDelim):], true
+}
+
 // MakeScopedEndpointNodeID is like MakeEndpointNodeID, but it always
 // prefixes the ID witha scope.
 func MakeScopedEndpointNodeID(hostID, address, port string) string {
@@ -167,8 +213,3 @@ func ExtractHostID(m Node) string {
 	hostid, _, _ := ParseNodeID(m.Metadata[HostNodeID])
 	return hostid
 }
-
-func isLoopback(address string) bool {
-	ip := net.ParseIP(address)
-	return ip != nil && ip.IsLoopback()
-}
@@ -187,6 +187,30 @@ func (m Metric) Merge(other Metric) Metric {
 	}
 }
 
+// Sum adds the samples of other to those of m, e.g. to total the metrics of a
+// group of containers. Samples are summed when their timestamps are equal;
+// those of either metric at other times are kept as they are.
+func (m Metric) Sum(other Metric) Metric {
+	values := map[int64]float64{}
+	for curr := m.Samples; curr != nil && !curr.IsNil(); curr = curr.Tail() {
+		s := curr.Head().(Sample)
+		values[s.Timestamp.UnixNano()] = s.Value
+	}
+
+	result := m
+	for curr := other.Samples; curr != nil && !curr.IsNil(); curr = curr.Tail() {
+		s := curr.Head().(Sample)
+		result = result.Add(s.Timestamp, values[s.Timestamp.UnixNano()]+s.Value)
+	}
+	return Metric{
+		Samples: result.Samples,
+		Max:     math.Max(result.Max, other.Max),
+		Min:     math.Min(result.Min, other.Min),
+		First:   first(result.First, other.First),
+		Last:    last(result.Last, other.Last),
+	}
+}
+
 // Div returns a new copy of the metric, with each value divided by n.
 func (m Metric) Div(n float64) Metric {
 	curr, acc := m.Samples, ps.NewList()
@@ -172,6 +172,34 @@ func TestMetricMerge(t *testing.T) {
 	}
 }
 
+func TestMetricSum(t *testing.T) {
+	t1 := time.Now()
+	t2 := time.Now().Add(1 * time.Minute)
+	t3 := time.Now().Add(2 * time.Minute)
+
+	metric1 := report.MakeMetric().
+		Add(t1, 1).
+		Add(t2, 2)
+
+	metric2 := report.MakeMetric().
+		Add(t2, 3).
+		Add(t3, 4)
+
+	want := report.MakeMetric().
+		Add(t1, 1).
+		Add(t2, 5).
+		Add(t3, 4)
+	have := metric1.Sum(metric2)
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+
+	// Summing into an empty metric gives the same metric
+	if have := (report.Metric{}).Sum(metric1); !reflect.DeepEqual(metric1, have) {
+		t.Errorf("diff: %s", test.Diff(metric1, have))
+	}
+}
+
 func TestMetricCopy(t *testing.T) {
 	want := report.MakeMetric()
 	have := want.Copy()
@@ -2,7 +2,6 @@ package report
 
 import (
 	"net"
-	"strings"
 )
 
 // Networks represent a set of subnets
@@ -14,12 +13,22 @@ type Interface interface {
 }
 
 // Variables exposed for testing.
-// TODO this design is broken, make it consistent with probe networks.
 var (
-	LocalNetworks       = Networks{}
 	InterfaceByNameStub = func(name string) (Interface, error) { return net.InterfaceByName(name) }
 )
 
+// ParseNetworks parses the passed CIDRs into Networks, skipping any which
+// don't parse.
+func ParseNetworks(cidrs ...string) Networks {
+	result := Networks{}
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			result = append(result, ipNet)
+		}
+	}
+	return result
+}
+
 // Contains returns true if IP is in Networks.
 func (n Networks) Contains(ip net.IP) bool {
 	for _, net := range n {
@@ -30,8 +39,20 @@ func (n Networks) Contains(ip net.IP) bool {
 	return false
 }
 
-// LocalAddresses returns a list of the local IP addresses.
-func LocalAddresses() ([]net.IP, error) {
+// Strings returns the networks in CIDR notation.
+func (n Networks) Strings() []string {
+	result := make([]string, 0, len(n))
+	for _, ipNet := range n {
+		result = append(result, ipNet.String())
+	}
+	return result
+}
+
+// LocalAddresses returns a list of the local IP addresses, i.e. those of the
+// interfaces which are up, other than loopback and link-local addresses
+// (which are only meaningful on the host itself), and addresses in the
+// passed scoped networks (e.g. those of the Docker bridge).
+func LocalAddresses(scoped Networks) ([]net.IP, error) {
 	result := []net.IP{}
 
 	infs, err := net.Interfaces()
@@ -40,9 +61,7 @@ func LocalAddresses() ([]net.IP, error) {
 	}
 
 	for _, inf := range infs {
-		if strings.HasPrefix(inf.Name, "veth") ||
-			strings.HasPrefix(inf.Name, "docker") ||
-			strings.HasPrefix(inf.Name, "lo") {
+		if inf.Flags&net.FlagUp == 0 || inf.Flags&net.FlagLoopback != 0 {
 			continue
 		}
 
@@ -53,7 +72,7 @@ func LocalAddresses() ([]net.IP, error) {
 
 		for _, addr := range addrs {
 			ipnet, ok := addr.(*net.IPNet)
-			if !ok {
+			if !ok || isHostScoped(ipnet.IP) || scoped.Contains(ipnet.IP) {
 				continue
 			}
 
@@ -64,31 +83,39 @@ func LocalAddresses() ([]net.IP, error) {
 	return result, nil
 }
 
-// AddLocalBridge records the subnet address associated with the bridge name
-// supplied, such that MakeAddressNodeID will scope addresses in this subnet
-// as local.
-func AddLocalBridge(name string) error {
+// InterfaceNetworks returns the subnets of the addresses of the named
+// interface, e.g. a bridge whose addresses are local to the host, so that
+// AddressNodeID will scope addresses in them.
+func InterfaceNetworks(name string) (Networks, error) {
 	inf, err := InterfaceByNameStub(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	addrs, err := inf.Addrs()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	result := Networks{}
 	for _, addr := range addrs {
 		_, network, err := net.ParseCIDR(addr.String())
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if network == nil {
 			continue
 		}
 
-		LocalNetworks = append(LocalNetworks, network)
+		result = append(result, network)
 	}
 
-	return nil
+	return result, nil
+}
+
+// isHostScoped is true for addresses which only mean something on the host
+// itself: loopback addresses (including IPv4-mapped ones) and link-local
+// ones, which can be the same on every host (e.g. fe80::1).
+func isHostScoped(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast()
 }
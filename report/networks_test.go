@@ -50,23 +50,82 @@ func (m mockAddr) String() string {
 	return string(m)
 }
 
-func TestAddLocal(t *testing.T) {
+func TestInterfaceNetworks(t *testing.T) {
 	oldInterfaceByNameStub := report.InterfaceByNameStub
 	defer func() { report.InterfaceByNameStub = oldInterfaceByNameStub }()
 
 	report.InterfaceByNameStub = func(name string) (report.Interface, error) {
-		return mockInterface{[]net.Addr{mockAddr("52.53.54.55/16")}}, nil
+		return mockInterface{[]net.Addr{
+			mockAddr("52.53.54.55/16"),
+			mockAddr("fd00:dead:beef::1/64"),
+		}}, nil
 	}
 
-	err := report.AddLocalBridge("foo")
+	have, err := report.InterfaceNetworks("foo")
 	if err != nil {
-		t.Errorf("%v", err)
+		t.Fatal(err)
 	}
 
-	want := report.Networks([]*net.IPNet{mustParseCIDR("52.53.54.55/16")})
-	have := report.LocalNetworks
+	want := report.ParseNetworks("52.53.0.0/16", "fd00:dead:beef::/64")
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
 
+func TestParseNetworks(t *testing.T) {
+	have := report.ParseNetworks("10.0.0.0/8", "garbage", "2001:db8::/32").Strings()
+	want := []string{"10.0.0.0/8", "2001:db8::/32"}
 	if !reflect.DeepEqual(want, have) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
 }
+
+func TestAddressNodeIDScoping(t *testing.T) {
+	scoped := report.ParseNetworks("172.17.0.0/16", "fd00:dead:beef::/64")
+	for address, isScoped := range map[string]bool{
+		"127.0.0.1":          true,
+		"::1":                true,
+		"::ffff:127.0.0.1":   true,
+		"169.254.1.2":        true,
+		"fe80::1":            true,
+		"172.17.0.2":         true,
+		"fd00:dead:beef::2":  true,
+		"fd00:1234::2":       false,
+		"2001:db8::1":        false,
+		"10.0.0.1":           false,
+		"::ffff:192.168.1.1": false,
+	} {
+		want := report.MakeAddressNodeID("", address)
+		if isScoped {
+			want = report.MakeScopedAddressNodeID("host", address)
+		}
+		if have := scoped.AddressNodeID("host", address); want != have {
+			t.Errorf("%s: want %q, have %q", address, want, have)
+		}
+	}
+}
+
+func TestNetNamespaceAddressNodeID(t *testing.T) {
+	scoped := report.ParseNetworks("172.17.0.0/16")
+	netnsScope := report.MakeNetNamespaceScope("host", "4026532001")
+	for _, tc := range []struct {
+		netns, address, want string
+	}{
+		{"", "127.0.0.1", report.MakeScopedAddressNodeID("host", "127.0.0.1")},
+		{"4026532001", "127.0.0.1", report.MakeScopedAddressNodeID(netnsScope, "127.0.0.1")},
+		{"4026532001", "fe80::1", report.MakeScopedAddressNodeID(netnsScope, "fe80::1")},
+		{"4026532001", "172.17.0.2", report.MakeScopedAddressNodeID("host", "172.17.0.2")},
+		{"4026532001", "10.0.0.1", report.MakeAddressNodeID("", "10.0.0.1")},
+	} {
+		if have := scoped.NetNamespaceAddressNodeID("host", tc.netns, tc.address); tc.want != have {
+			t.Errorf("%s in %q: want %q, have %q", tc.address, tc.netns, tc.want, have)
+		}
+	}
+
+	if hostID, netns, ok := report.ParseNetNamespaceScope(netnsScope); !ok || hostID != "host" || netns != "4026532001" {
+		t.Errorf("parsing %q: have %q, %q, %v", netnsScope, hostID, netns, ok)
+	}
+	if _, _, ok := report.ParseNetNamespaceScope("host"); ok {
+		t.Error("parsed a host ID as a namespace scope")
+	}
+}
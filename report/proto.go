@@ -0,0 +1,500 @@
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mndrix/ps"
+)
+
+const (
+	// ProtoContentType is the Content-Type of reports encoded with
+	// MarshalProto.
+	ProtoContentType = "application/x-protobuf"
+
+	// DeltaProtoName is the name of the Delta message in report.proto.
+	DeltaProtoName = "report.Delta"
+
+	// DeltaContentType is the Content-Type of deltas encoded with
+	// MarshalProto.
+	DeltaContentType = ProtoContentType + "; proto=" + DeltaProtoName
+
+	// ProtoVersion is the version of the schema in report.proto.
+	ProtoVersion = 1
+)
+
+// The types below mirror the messages in report.proto, and are only used for
+// (un)marshalling. Keep them in sync with the schema.
+
+type protoReport struct {
+	Version    uint32                    `protobuf:"varint,1,opt,name=version"`
+	Topologies map[string]*protoTopology `protobuf:"bytes,2,rep,name=topologies" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Sampling   *protoSampling            `protobuf:"bytes,3,opt,name=sampling"`
+	Window     int64                     `protobuf:"varint,4,opt,name=window"`
+	Shortcut   bool                      `protobuf:"varint,5,opt,name=shortcut"`
+}
+
+func (m *protoReport) Reset()         { *m = protoReport{} }
+func (m *protoReport) String() string { return proto.CompactTextString(m) }
+func (*protoReport) ProtoMessage()    {}
+
+type protoDelta struct {
+	Version uint32                        `protobuf:"varint,1,opt,name=version"`
+	Seq     uint64                        `protobuf:"varint,2,opt,name=seq"`
+	BaseSeq uint64                        `protobuf:"varint,3,opt,name=base_seq"`
+	Full    bool                          `protobuf:"varint,4,opt,name=full"`
+	Report  *protoReport                  `protobuf:"bytes,5,opt,name=report"`
+	Removed map[string]*protoStringSet    `protobuf:"bytes,6,rep,name=removed" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Metrics map[string]*protoNodesMetrics `protobuf:"bytes,7,rep,name=metrics" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *protoDelta) Reset()         { *m = protoDelta{} }
+func (m *protoDelta) String() string { return proto.CompactTextString(m) }
+func (*protoDelta) ProtoMessage()    {}
+
+type protoNodesMetrics struct {
+	Nodes map[string]*protoNodeMetrics `protobuf:"bytes,1,rep,name=nodes" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *protoNodesMetrics) Reset()         { *m = protoNodesMetrics{} }
+func (m *protoNodesMetrics) String() string { return proto.CompactTextString(m) }
+func (*protoNodesMetrics) ProtoMessage()    {}
+
+type protoNodeMetrics struct {
+	Metrics map[string]*protoMetric `protobuf:"bytes,1,rep,name=metrics" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *protoNodeMetrics) Reset()         { *m = protoNodeMetrics{} }
+func (m *protoNodeMetrics) String() string { return proto.CompactTextString(m) }
+func (*protoNodeMetrics) ProtoMessage()    {}
+
+type protoSampling struct {
+	Count uint64 `protobuf:"varint,1,opt,name=count"`
+	Total uint64 `protobuf:"varint,2,opt,name=total"`
+}
+
+func (m *protoSampling) Reset()         { *m = protoSampling{} }
+func (m *protoSampling) String() string { return proto.CompactTextString(m) }
+func (*protoSampling) ProtoMessage()    {}
+
+type protoTopology struct {
+	Nodes    map[string]*protoNode    `protobuf:"bytes,1,rep,name=nodes" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Controls map[string]*protoControl `protobuf:"bytes,2,rep,name=controls" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *protoTopology) Reset()         { *m = protoTopology{} }
+func (m *protoTopology) String() string { return proto.CompactTextString(m) }
+func (*protoTopology) ProtoMessage()    {}
+
+type protoControl struct {
+	ID     string               `protobuf:"bytes,1,opt,name=id"`
+	Human  string               `protobuf:"bytes,2,opt,name=human"`
+	Icon   string               `protobuf:"bytes,3,opt,name=icon"`
+	Params []*protoControlParam `protobuf:"bytes,4,rep,name=params"`
+}
+
+func (m *protoControl) Reset()         { *m = protoControl{} }
+func (m *protoControl) String() string { return proto.CompactTextString(m) }
+func (*protoControl) ProtoMessage()    {}
+
+type protoControlParam struct {
+	Name     string   `protobuf:"bytes,1,opt,name=name"`
+	Human    string   `protobuf:"bytes,2,opt,name=human"`
+	Type     string   `protobuf:"bytes,3,opt,name=type"`
+	Required bool     `protobuf:"varint,4,opt,name=required"`
+	Default  string   `protobuf:"bytes,5,opt,name=default"`
+	Options  []string `protobuf:"bytes,6,rep,name=options"`
+}
+
+func (m *protoControlParam) Reset()         { *m = protoControlParam{} }
+func (m *protoControlParam) String() string { return proto.CompactTextString(m) }
+func (*protoControlParam) ProtoMessage()    {}
+
+type protoNode struct {
+	Metadata  map[string]string             `protobuf:"bytes,1,rep,name=metadata" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Counters  map[string]int64              `protobuf:"bytes,2,rep,name=counters" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Sets      map[string]*protoStringSet    `protobuf:"bytes,3,rep,name=sets" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Adjacency []string                      `protobuf:"bytes,4,rep,name=adjacency"`
+	Edges     map[string]*protoEdgeMetadata `protobuf:"bytes,5,rep,name=edges" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Controls  *protoNodeControls            `protobuf:"bytes,6,opt,name=controls"`
+	Latest    map[string]*protoLatestEntry  `protobuf:"bytes,7,rep,name=latest" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Metrics   map[string]*protoMetric       `protobuf:"bytes,8,rep,name=metrics" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *protoNode) Reset()         { *m = protoNode{} }
+func (m *protoNode) String() string { return proto.CompactTextString(m) }
+func (*protoNode) ProtoMessage()    {}
+
+type protoStringSet struct {
+	Values []string `protobuf:"bytes,1,rep,name=values"`
+}
+
+func (m *protoStringSet) Reset()         { *m = protoStringSet{} }
+func (m *protoStringSet) String() string { return proto.CompactTextString(m) }
+func (*protoStringSet) ProtoMessage()    {}
+
+type protoEdgeMetadata struct {
+	EgressPacketCount  *uint64 `protobuf:"varint,1,opt,name=egress_packet_count"`
+	IngressPacketCount *uint64 `protobuf:"varint,2,opt,name=ingress_packet_count"`
+	EgressByteCount    *uint64 `protobuf:"varint,3,opt,name=egress_byte_count"`
+	IngressByteCount   *uint64 `protobuf:"varint,4,opt,name=ingress_byte_count"`
+	MaxConnCountTCP    *uint64 `protobuf:"varint,5,opt,name=max_conn_count_tcp"`
+	MaxConnCountUDP    *uint64 `protobuf:"varint,6,opt,name=max_conn_count_udp"`
+
+	HTTPRequestCount      *uint64           `protobuf:"varint,7,opt,name=http_request_count"`
+	HTTPStatusClassCounts map[string]uint64 `protobuf:"bytes,8,rep,name=http_status_class_counts" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	HTTPLatencyCounts     []uint64          `protobuf:"varint,9,rep,packed,name=http_latency_counts"`
+}
+
+func (m *protoEdgeMetadata) Reset()         { *m = protoEdgeMetadata{} }
+func (m *protoEdgeMetadata) String() string { return proto.CompactTextString(m) }
+func (*protoEdgeMetadata) ProtoMessage()    {}
+
+type protoNodeControls struct {
+	Timestamp int64    `protobuf:"varint,1,opt,name=timestamp"`
+	Controls  []string `protobuf:"bytes,2,rep,name=controls"`
+}
+
+func (m *protoNodeControls) Reset()         { *m = protoNodeControls{} }
+func (m *protoNodeControls) String() string { return proto.CompactTextString(m) }
+func (*protoNodeControls) ProtoMessage()    {}
+
+type protoLatestEntry struct {
+	Timestamp int64  `protobuf:"varint,1,opt,name=timestamp"`
+	Value     string `protobuf:"bytes,2,opt,name=value"`
+}
+
+func (m *protoLatestEntry) Reset()         { *m = protoLatestEntry{} }
+func (m *protoLatestEntry) String() string { return proto.CompactTextString(m) }
+func (*protoLatestEntry) ProtoMessage()    {}
+
+type protoMetric struct {
+	Samples []*protoSample `protobuf:"bytes,1,rep,name=samples"`
+	Min     float64        `protobuf:"fixed64,2,opt,name=min"`
+	Max     float64        `protobuf:"fixed64,3,opt,name=max"`
+	First   int64          `protobuf:"varint,4,opt,name=first"`
+	Last    int64          `protobuf:"varint,5,opt,name=last"`
+}
+
+func (m *protoMetric) Reset()         { *m = protoMetric{} }
+func (m *protoMetric) String() string { return proto.CompactTextString(m) }
+func (*protoMetric) ProtoMessage()    {}
+
+type protoSample struct {
+	Timestamp int64   `protobuf:"varint,1,opt,name=timestamp"`
+	Value     float64 `protobuf:"fixed64,2,opt,name=value"`
+}
+
+func (m *protoSample) Reset()         { *m = protoSample{} }
+func (m *protoSample) String() string { return proto.CompactTextString(m) }
+func (*protoSample) ProtoMessage()    {}
+
+// MarshalProto encodes the report as a protobuf Report message, as described
+// by report.proto.
+func (r Report) MarshalProto() ([]byte, error) {
+	return proto.Marshal(r.toProto())
+}
+
+// UnmarshalProto decodes a protobuf Report message into the report.
+func (r *Report) UnmarshalProto(input []byte) error {
+	in := protoReport{}
+	if err := proto.Unmarshal(input, &in); err != nil {
+		return err
+	}
+	if in.Version != ProtoVersion {
+		return fmt.Errorf("unsupported report version %d", in.Version)
+	}
+	*r = in.fromProto()
+	return nil
+}
+
+// MarshalProto encodes the delta as a protobuf Delta message, as described by
+// report.proto.
+func (d Delta) MarshalProto() ([]byte, error) {
+	out := &protoDelta{
+		Version: ProtoVersion,
+		Seq:     d.Seq,
+		BaseSeq: d.BaseSeq,
+		Full:    d.Full,
+		Report:  d.Report.toProto(),
+		Removed: make(map[string]*protoStringSet, len(d.Removed)),
+		Metrics: make(map[string]*protoNodesMetrics, len(d.Metrics)),
+	}
+	for name, ids := range d.Removed {
+		out.Removed[name] = &protoStringSet{Values: []string(ids)}
+	}
+	for name, nodes := range d.Metrics {
+		pn := &protoNodesMetrics{Nodes: make(map[string]*protoNodeMetrics, len(nodes))}
+		for id, metrics := range nodes {
+			pm := &protoNodeMetrics{Metrics: make(map[string]*protoMetric, len(metrics))}
+			for k, m := range metrics {
+				pm.Metrics[k] = m.toProto()
+			}
+			pn.Nodes[id] = pm
+		}
+		out.Metrics[name] = pn
+	}
+	return proto.Marshal(out)
+}
+
+// UnmarshalProto decodes a protobuf Delta message into the delta.
+func (d *Delta) UnmarshalProto(input []byte) error {
+	in := protoDelta{}
+	if err := proto.Unmarshal(input, &in); err != nil {
+		return err
+	}
+	if in.Version != ProtoVersion {
+		return fmt.Errorf("unsupported delta version %d", in.Version)
+	}
+	*d = Delta{
+		Seq:     in.Seq,
+		BaseSeq: in.BaseSeq,
+		Full:    in.Full,
+		Report:  MakeReport(),
+		Removed: make(map[string]IDList, len(in.Removed)),
+		Metrics: make(map[string]map[string]Metrics, len(in.Metrics)),
+	}
+	if in.Report != nil {
+		d.Report = in.Report.fromProto()
+	}
+	for name, ids := range in.Removed {
+		d.Removed[name] = MakeIDList(ids.Values...)
+	}
+	for name, pn := range in.Metrics {
+		nodes := make(map[string]Metrics, len(pn.Nodes))
+		for id, pm := range pn.Nodes {
+			metrics := make(Metrics, len(pm.Metrics))
+			for k, m := range pm.Metrics {
+				metrics[k] = m.fromProto()
+			}
+			nodes[id] = metrics
+		}
+		d.Metrics[name] = nodes
+	}
+	return nil
+}
+
+func protoTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromProtoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func (r Report) toProto() *protoReport {
+	out := &protoReport{
+		Version:    ProtoVersion,
+		Topologies: map[string]*protoTopology{},
+		Sampling: &protoSampling{
+			Count: r.Sampling.Count,
+			Total: r.Sampling.Total,
+		},
+		Window:   int64(r.Window),
+		Shortcut: r.Shortcut,
+	}
+	for name, t := range r.topologiesByName() {
+		out.Topologies[name] = t.toProto()
+	}
+	return out
+}
+
+func (in *protoReport) fromProto() Report {
+	r := MakeReport()
+	for name, t := range r.topologiesByName() {
+		if pt, ok := in.Topologies[name]; ok {
+			*t = pt.fromProto()
+		}
+	}
+	if in.Sampling != nil {
+		r.Sampling = Sampling{Count: in.Sampling.Count, Total: in.Sampling.Total}
+	}
+	r.Window = time.Duration(in.Window)
+	r.Shortcut = in.Shortcut
+	return r
+}
+
+func (t Topology) toProto() *protoTopology {
+	out := &protoTopology{
+		Nodes:    make(map[string]*protoNode, len(t.Nodes)),
+		Controls: make(map[string]*protoControl, len(t.Controls)),
+	}
+	for id, n := range t.Nodes {
+		out.Nodes[id] = n.toProto()
+	}
+	for id, c := range t.Controls {
+		out.Controls[id] = c.toProto()
+	}
+	return out
+}
+
+func (in *protoTopology) fromProto() Topology {
+	t := MakeTopology()
+	for id, n := range in.Nodes {
+		t.Nodes[id] = n.fromProto()
+	}
+	for id, c := range in.Controls {
+		t.Controls[id] = c.fromProto()
+	}
+	return t
+}
+
+func (c Control) toProto() *protoControl {
+	out := &protoControl{ID: c.ID, Human: c.Human, Icon: c.Icon}
+	for _, p := range c.Params {
+		out.Params = append(out.Params, &protoControlParam{
+			Name:     p.Name,
+			Human:    p.Human,
+			Type:     p.Type,
+			Required: p.Required,
+			Default:  p.Default,
+			Options:  p.Options,
+		})
+	}
+	return out
+}
+
+func (in *protoControl) fromProto() Control {
+	c := Control{ID: in.ID, Human: in.Human, Icon: in.Icon}
+	for _, p := range in.Params {
+		c.Params = append(c.Params, ControlParam{
+			Name:     p.Name,
+			Human:    p.Human,
+			Type:     p.Type,
+			Required: p.Required,
+			Default:  p.Default,
+			Options:  p.Options,
+		})
+	}
+	return c
+}
+
+func (n Node) toProto() *protoNode {
+	out := &protoNode{
+		Metadata:  map[string]string(n.Metadata),
+		Counters:  make(map[string]int64, len(n.Counters)),
+		Sets:      make(map[string]*protoStringSet, len(n.Sets)),
+		Adjacency: []string(n.Adjacency),
+		Edges:     make(map[string]*protoEdgeMetadata, len(n.Edges)),
+		Controls: &protoNodeControls{
+			Timestamp: protoTime(n.Controls.Timestamp),
+			Controls:  []string(n.Controls.Controls),
+		},
+		Latest:  map[string]*protoLatestEntry{},
+		Metrics: make(map[string]*protoMetric, len(n.Metrics)),
+	}
+	for k, v := range n.Counters {
+		out.Counters[k] = int64(v)
+	}
+	for k, v := range n.Sets {
+		out.Sets[k] = &protoStringSet{Values: []string(v)}
+	}
+	for dst, md := range n.Edges {
+		out.Edges[dst] = &protoEdgeMetadata{
+			EgressPacketCount:  md.EgressPacketCount,
+			IngressPacketCount: md.IngressPacketCount,
+			EgressByteCount:    md.EgressByteCount,
+			IngressByteCount:   md.IngressByteCount,
+			MaxConnCountTCP:    md.MaxConnCountTCP,
+			MaxConnCountUDP:    md.MaxConnCountUDP,
+
+			HTTPRequestCount:      md.HTTPRequestCount,
+			HTTPStatusClassCounts: md.HTTPStatusClassCounts,
+			HTTPLatencyCounts:     md.HTTPLatencyCounts,
+		}
+	}
+	if n.Latest.Map != nil {
+		n.Latest.ForEach(func(key string, val interface{}) {
+			e := val.(LatestEntry)
+			out.Latest[key] = &protoLatestEntry{Timestamp: protoTime(e.Timestamp), Value: e.Value}
+		})
+	}
+	for k, m := range n.Metrics {
+		out.Metrics[k] = m.toProto()
+	}
+	return out
+}
+
+func (in *protoNode) fromProto() Node {
+	n := MakeNode()
+	for k, v := range in.Metadata {
+		n.Metadata[k] = v
+	}
+	for k, v := range in.Counters {
+		n.Counters[k] = int(v)
+	}
+	for k, v := range in.Sets {
+		n.Sets[k] = MakeStringSet(v.Values...)
+	}
+	n.Adjacency = MakeIDList(in.Adjacency...)
+	for dst, md := range in.Edges {
+		n.Edges[dst] = EdgeMetadata{
+			EgressPacketCount:  md.EgressPacketCount,
+			IngressPacketCount: md.IngressPacketCount,
+			EgressByteCount:    md.EgressByteCount,
+			IngressByteCount:   md.IngressByteCount,
+			MaxConnCountTCP:    md.MaxConnCountTCP,
+			MaxConnCountUDP:    md.MaxConnCountUDP,
+
+			HTTPRequestCount:      md.HTTPRequestCount,
+			HTTPStatusClassCounts: md.HTTPStatusClassCounts,
+			HTTPLatencyCounts:     md.HTTPLatencyCounts,
+		}
+	}
+	if in.Controls != nil {
+		n.Controls = NodeControls{
+			Timestamp: fromProtoTime(in.Controls.Timestamp),
+			Controls:  MakeStringSet(in.Controls.Controls...),
+		}
+	}
+	for k, e := range in.Latest {
+		n.Latest = n.Latest.Set(k, fromProtoTime(e.Timestamp), e.Value)
+	}
+	for k, m := range in.Metrics {
+		n.Metrics[k] = m.fromProto()
+	}
+	return n
+}
+
+func (m Metric) toProto() *protoMetric {
+	out := &protoMetric{
+		Samples: []*protoSample{},
+		Min:     m.Min,
+		Max:     m.Max,
+		First:   protoTime(m.First),
+		Last:    protoTime(m.Last),
+	}
+	// On the wire, samples are sorted oldest to newest, the opposite order to
+	// how we store them internally.
+	if m.Samples != nil {
+		m.Samples.Reverse().ForEach(func(s interface{}) {
+			sample := s.(Sample)
+			out.Samples = append(out.Samples, &protoSample{
+				Timestamp: protoTime(sample.Timestamp),
+				Value:     sample.Value,
+			})
+		})
+	}
+	return out
+}
+
+func (in *protoMetric) fromProto() Metric {
+	samples := ps.NewList()
+	for _, s := range in.Samples {
+		samples = samples.Cons(Sample{Timestamp: fromProtoTime(s.Timestamp), Value: s.Value})
+	}
+	return Metric{
+		Samples: samples,
+		Min:     in.Min,
+		Max:     in.Max,
+		First:   fromProtoTime(in.First),
+		Last:    fromProtoTime(in.Last),
+	}
+}
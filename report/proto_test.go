@@ -0,0 +1,73 @@
+package report_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestReportProtoMarshalling(t *testing.T) {
+	var (
+		ts          = time.Unix(1450000000, 123456789)
+		packets     = uint64(1)
+		bytes       = uint64(1024)
+		connections = uint64(0)
+		metric      = report.MakeMetric().Add(ts, 0.5).Add(ts.Add(time.Second), 1.5)
+	)
+
+	want := report.MakeReport()
+	want.Endpoint.AddNode("a", report.MakeNodeWith(map[string]string{"b": "c"}).
+		WithAdjacent("d").
+		WithEdge("d", report.EdgeMetadata{
+			EgressPacketCount: &packets,
+			EgressByteCount:   &bytes,
+			MaxConnCountTCP:   &connections,
+		}.WithHTTPRequest().WithHTTPResponse(503, 30*time.Millisecond)))
+	want.Container.AddNode("e", report.MakeNode().
+		WithCounters(map[string]int{"f": 2}).
+		WithSet("g", report.MakeStringSet("h", "i")).
+		WithLatest("j", ts, "k").
+		WithMetric("l", metric))
+	want.Container.Controls.AddControl(report.Control{ID: "m", Human: "n", Icon: "o", Params: []report.ControlParam{
+		{Name: "q", Human: "r", Type: report.IntParam, Required: true},
+		{Name: "s", Human: "t", Type: report.EnumParam, Default: "u", Options: []string{"u", "v"}},
+	}})
+	node := report.MakeNode()
+	node.Controls = report.NodeControls{Timestamp: ts, Controls: report.MakeStringSet("m")}
+	want.Container.AddNode("p", node)
+	want.Sampling = report.Sampling{Count: 3, Total: 4}
+	want.Window = 15 * time.Second
+	want.Shortcut = true
+
+	b, err := want.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := report.MakeReport()
+	if err := have.UnmarshalProto(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("diff: %s", test.Diff(want, have))
+	}
+}
+
+func TestReportProtoVersion(t *testing.T) {
+	b, err := report.MakeReport().MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Field 1 (version) is a varint, so it's encoded as tag 0x08 then the
+	// value.
+	if len(b) < 2 || b[0] != 0x08 || b[1] != report.ProtoVersion {
+		t.Fatalf("unexpected encoding: %x", b)
+	}
+	b[1] = report.ProtoVersion + 1
+	rpt := report.MakeReport()
+	if err := rpt.UnmarshalProto(b); err == nil {
+		t.Error("expected an error decoding an unknown version")
+	}
+}
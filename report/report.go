@@ -136,6 +136,22 @@ func (r Report) Topologies() []Topology {
 	}
 }
 
+// topologiesByName maps the names of the topologies in r, as used on the
+// wire, to the topologies themselves.
+func (r *Report) topologiesByName() map[string]*Topology {
+	return map[string]*Topology{
+		"endpoint":        &r.Endpoint,
+		"address":         &r.Address,
+		"process":         &r.Process,
+		"container":       &r.Container,
+		"pod":             &r.Pod,
+		"service":         &r.Service,
+		"container_image": &r.ContainerImage,
+		"host":            &r.Host,
+		"overlay":         &r.Overlay,
+	}
+}
+
 // Validate checks the report for various inconsistencies.
 func (r Report) Validate() error {
 	var errs []string
@@ -403,6 +403,12 @@ type EdgeMetadata struct {
 	EgressByteCount    *uint64 `json:"egress_byte_count,omitempty"`  // Transport layer
 	IngressByteCount   *uint64 `json:"ingress_byte_count,omitempty"` // Transport layer
 	MaxConnCountTCP    *uint64 `json:"max_conn_count_tcp,omitempty"`
+	MaxConnCountUDP    *uint64 `json:"max_conn_count_udp,omitempty"`
+
+	// HTTP requests seen on the edge, from client to server. See http.go.
+	HTTPRequestCount      *uint64           `json:"http_request_count,omitempty"`
+	HTTPStatusClassCounts map[string]uint64 `json:"http_status_class_counts,omitempty"` // e.g. "5xx": 3
+	HTTPLatencyCounts     []uint64          `json:"http_latency_counts,omitempty"`      // responses per HTTPLatencyBuckets bucket
 }
 
 // Copy returns a value copy of the EdgeMetadata.
@@ -413,10 +419,16 @@ func (e EdgeMetadata) Copy() EdgeMetadata {
 		EgressByteCount:    cpu64ptr(e.EgressByteCount),
 		IngressByteCount:   cpu64ptr(e.IngressByteCount),
 		MaxConnCountTCP:    cpu64ptr(e.MaxConnCountTCP),
+		MaxConnCountUDP:    cpu64ptr(e.MaxConnCountUDP),
+
+		HTTPRequestCount:      cpu64ptr(e.HTTPRequestCount),
+		HTTPStatusClassCounts: sumCounts(nil, e.HTTPStatusClassCounts),
+		HTTPLatencyCounts:     sumBuckets(nil, e.HTTPLatencyCounts),
 	}
 }
 
-// Reversed returns a value copy of the EdgeMetadata, with the direction reversed.
+// Reversed returns a value copy of the EdgeMetadata, with the direction
+// reversed. The HTTP requests are still those of the same client and server.
 func (e EdgeMetadata) Reversed() EdgeMetadata {
 	return EdgeMetadata{
 		EgressPacketCount:  cpu64ptr(e.IngressPacketCount),
@@ -424,6 +436,11 @@ func (e EdgeMetadata) Reversed() EdgeMetadata {
 		EgressByteCount:    cpu64ptr(e.IngressByteCount),
 		IngressByteCount:   cpu64ptr(e.EgressByteCount),
 		MaxConnCountTCP:    cpu64ptr(e.MaxConnCountTCP),
+		MaxConnCountUDP:    cpu64ptr(e.MaxConnCountUDP),
+
+		HTTPRequestCount:      cpu64ptr(e.HTTPRequestCount),
+		HTTPStatusClassCounts: sumCounts(nil, e.HTTPStatusClassCounts),
+		HTTPLatencyCounts:     sumBuckets(nil, e.HTTPLatencyCounts),
 	}
 }
 
@@ -445,6 +462,10 @@ func (e EdgeMetadata) Merge(other EdgeMetadata) EdgeMetadata {
 	cp.EgressByteCount = merge(cp.EgressByteCount, other.EgressByteCount, sum)
 	cp.IngressByteCount = merge(cp.IngressByteCount, other.IngressByteCount, sum)
 	cp.MaxConnCountTCP = merge(cp.MaxConnCountTCP, other.MaxConnCountTCP, max)
+	cp.MaxConnCountUDP = merge(cp.MaxConnCountUDP, other.MaxConnCountUDP, max)
+	cp.HTTPRequestCount = merge(cp.HTTPRequestCount, other.HTTPRequestCount, sum)
+	cp.HTTPStatusClassCounts = sumCounts(cp.HTTPStatusClassCounts, other.HTTPStatusClassCounts)
+	cp.HTTPLatencyCounts = sumBuckets(cp.HTTPLatencyCounts, other.HTTPLatencyCounts)
 	return cp
 }
 
@@ -460,6 +481,10 @@ func (e EdgeMetadata) Flatten(other EdgeMetadata) EdgeMetadata {
 	// Note that summing of two maximums doesn't always give us the true
 	// maximum. But it's a best effort.
 	cp.MaxConnCountTCP = merge(cp.MaxConnCountTCP, other.MaxConnCountTCP, sum)
+	cp.MaxConnCountUDP = merge(cp.MaxConnCountUDP, other.MaxConnCountUDP, sum)
+	cp.HTTPRequestCount = merge(cp.HTTPRequestCount, other.HTTPRequestCount, sum)
+	cp.HTTPStatusClassCounts = sumCounts(cp.HTTPStatusClassCounts, other.HTTPStatusClassCounts)
+	cp.HTTPLatencyCounts = sumBuckets(cp.HTTPLatencyCounts, other.HTTPLatencyCounts)
 	return cp
 }
 
@@ -2,6 +2,7 @@ package xfer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -13,18 +14,39 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/weaveworks/scope/common/sanitize"
+	"github.com/weaveworks/scope/report"
 )
 
 const (
-	initialBackoff = 1 * time.Second
-	maxBackoff     = 60 * time.Second
+	initialBackoff  = 1 * time.Second
+	maxBackoff      = 60 * time.Second
+	publishQueueLen = 10 // reports waiting to be published, before we drop them
 )
 
+// ErrUnauthorized is returned when the app rejects the probe's token. There
+// is no point retrying requests which fail with it: the client stops talking
+// to the app, until the probe is restarted with a token the app accepts.
+var ErrUnauthorized = errors.New("app rejected probe token (401 Unauthorized)")
+
 // Details are some generic details that can be fetched from /api
 type Details struct {
 	ID       string `json:"id"`
 	Version  string `json:"version"`
 	Hostname string `json:"hostname"`
+
+	// ReportContentTypes are the encodings the app accepts for reports.
+	// Older apps don't advertise any, and only accept gob.
+	ReportContentTypes []string `json:"report_content_types,omitempty"`
+}
+
+// acceptsDeltas is true if the app said it accepts report deltas.
+func (d Details) acceptsDeltas() bool {
+	for _, contentType := range d.ReportContentTypes {
+		if contentType == report.DeltaContentType {
+			return true
+		}
+	}
+	return false
 }
 
 // AppClient is a client to an app for dealing with controls.
@@ -34,6 +56,8 @@ type AppClient interface {
 	PipeConnection(string, Pipe)
 	PipeClose(string) error
 	Publish(r io.Reader) error
+	PublishWithFallback(delta io.Reader, full func() (io.Reader, error)) error
+	NeedsFullReport() bool
 	Stop()
 }
 
@@ -41,10 +65,11 @@ type AppClient interface {
 type appClient struct {
 	ProbeConfig
 
-	quit   chan struct{}
-	mtx    sync.Mutex
-	target string
-	client http.Client
+	quit         chan struct{}
+	unauthorized chan struct{}
+	mtx          sync.Mutex
+	target       string
+	client       http.Client
 
 	// Track all the background goroutines, ensure they all stop
 	backgroundWait sync.WaitGroup
@@ -53,8 +78,11 @@ type appClient struct {
 	conns map[string]*websocket.Conn
 
 	// For publish
-	publishLoop sync.Once
-	readers     chan io.Reader
+	publishLoop     sync.Once
+	readers         chan publication
+	needsFullReport bool
+	legacy          bool // the app only accepts full, gob-encoded reports
+	acceptsDeltas   bool // the app said it accepts deltas
 
 	// For controls
 	control ControlHandler
@@ -68,14 +96,15 @@ func NewAppClient(pc ProbeConfig, hostname, target string, control ControlHandle
 	}
 
 	return &appClient{
-		ProbeConfig: pc,
-		quit:        make(chan struct{}),
-		target:      target,
+		ProbeConfig:  pc,
+		quit:         make(chan struct{}),
+		unauthorized: make(chan struct{}),
+		target:       target,
 		client: http.Client{
 			Transport: httpTransport,
 		},
 		conns:   map[string]*websocket.Conn{},
-		readers: make(chan io.Reader),
+		readers: make(chan publication, publishQueueLen),
 		control: control,
 	}, nil
 }
@@ -89,10 +118,36 @@ func (c *appClient) hasQuit() bool {
 	}
 }
 
+// rejected stops the client's loops, once the app has rejected its token.
+func (c *appClient) rejected() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	select {
+	case <-c.unauthorized:
+		return
+	default:
+	}
+	log.Printf("ERROR: %s: %v; not talking to it again. Check the probe's -token flag", c.target, ErrUnauthorized)
+	close(c.unauthorized)
+	for id, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, id)
+	}
+}
+
+func (c *appClient) isRejected() bool {
+	select {
+	case <-c.unauthorized:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *appClient) registerConn(id string, conn *websocket.Conn) bool {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
-	if c.hasQuit() {
+	if c.hasQuit() || c.isRejected() {
 		conn.Close()
 		return false
 	}
@@ -151,7 +206,18 @@ func (c *appClient) Details() (Details, error) {
 		return result, err
 	}
 	defer resp.Body.Close()
-	return result, json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.rejected()
+		return result, ErrUnauthorized
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, err
+	}
+	c.mtx.Lock()
+	c.acceptsDeltas = result.acceptsDeltas()
+	c.legacy = !c.acceptsDeltas
+	c.mtx.Unlock()
+	return result, nil
 }
 
 func (c *appClient) doWithBackoff(msg string, f func() (bool, error)) {
@@ -162,7 +228,7 @@ func (c *appClient) doWithBackoff(msg string, f func() (bool, error)) {
 
 	backoff := initialBackoff
 
-	for {
+	for !c.isRejected() {
 		done, err := f()
 		if done {
 			return
@@ -171,12 +237,18 @@ func (c *appClient) doWithBackoff(msg string, f func() (bool, error)) {
 			backoff = initialBackoff
 			continue
 		}
+		if err == ErrUnauthorized {
+			c.rejected()
+			return
+		}
 
 		log.Printf("Error doing %s for %s, backing off %s: %v", msg, c.target, backoff, err)
 		select {
 		case <-time.After(backoff):
 		case <-c.quit:
 			return
+		case <-c.unauthorized:
+			return
 		}
 		backoff *= 2
 		if backoff > maxBackoff {
@@ -191,7 +263,10 @@ func (c *appClient) controlConnection() (bool, error) {
 	c.ProbeConfig.authorizeHeaders(headers)
 	// TODO(twilkie) need to update sanitize to work with wss
 	url := sanitize.URL("ws://", 0, "/api/control/ws")(c.target)
-	conn, _, err := dialer.Dial(url, headers)
+	conn, resp, err := dialer.Dial(url, headers)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return false, ErrUnauthorized
+	}
 	if err != nil {
 		return false, err
 	}
@@ -224,22 +299,108 @@ func (c *appClient) ControlConnection() {
 	}()
 }
 
-func (c *appClient) publish(r io.Reader) error {
+// A publication is a report delta, and optionally a way to get the whole
+// report in the legacy encoding, should the app not accept deltas.
+type publication struct {
+	delta io.Reader
+	full  func() (io.Reader, error)
+}
+
+func (c *appClient) post(r io.Reader, contentType string) (int, error) {
 	url := sanitize.URL("", 0, "/api/report")(c.target)
 	req, err := c.ProbeConfig.authorizedRequest("POST", url, r)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	req.Header.Set("Content-Encoding", "gzip")
-	// req.Header.Set("Content-Type", "application/binary") // TODO: we should use http.DetectContentType(..) on the gob'ed
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf(resp.Status)
+	if resp.StatusCode == http.StatusUnauthorized {
+		return resp.StatusCode, ErrUnauthorized
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *appClient) publish(r io.Reader) error {
+	return c.publishPublication(publication{delta: r})
+}
+
+func (c *appClient) publishPublication(p publication) error {
+	c.mtx.Lock()
+	legacy := c.legacy
+	c.mtx.Unlock()
+	if legacy && p.full != nil {
+		return c.publishFull(p.full)
+	}
+
+	status, err := c.post(p.delta, report.DeltaContentType)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		// The app doesn't have the report our delta is based on.
+		log.Printf("App %s needs a full report", c.target)
+		c.mtx.Lock()
+		c.needsFullReport = true
+		c.mtx.Unlock()
+		return nil
+	case http.StatusUnsupportedMediaType:
+		if p.full == nil {
+			break
+		}
+		// The app doesn't understand deltas; send it whole reports from now on.
+		log.Printf("App %s doesn't accept report deltas, falling back to gob", c.target)
+		c.mtx.Lock()
+		c.legacy = true
+		c.mtx.Unlock()
+		return c.publishFull(p.full)
+	case http.StatusBadRequest:
+		if p.full == nil || !c.mayBeLegacy() {
+			break
+		}
+		// Older apps answer deltas with a 400, failing to decode them as gob,
+		// but current apps answer a bad delta with one too. Only Details
+		// switches to gob for good, if the app says that's all it takes;
+		// otherwise just this report falls back.
+		return c.publishFull(p.full)
+	}
+	return fmt.Errorf("%d %s", status, http.StatusText(status))
+}
+
+// mayBeLegacy is false if the app says it accepts deltas. It asks the app, as
+// we may not have been able to when the client was created.
+func (c *appClient) mayBeLegacy() bool {
+	c.mtx.Lock()
+	acceptsDeltas := c.acceptsDeltas
+	c.mtx.Unlock()
+	if acceptsDeltas {
+		return false
+	}
+	details, err := c.Details()
+	return err != nil || !details.acceptsDeltas()
+}
+
+func (c *appClient) publishFull(full func() (io.Reader, error)) error {
+	r, err := full()
+	if err != nil {
+		return err
+	}
+	status, err := c.post(r, "")
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%d %s", status, http.StatusText(status))
 	}
 	return nil
 }
@@ -249,26 +410,48 @@ func (c *appClient) startPublishing() {
 		log.Printf("Publish loop for %s starting", c.target)
 		defer log.Printf("Publish loop for %s exiting", c.target)
 		c.doWithBackoff("publish", func() (bool, error) {
-			r := <-c.readers
-			if r == nil {
+			p, ok := <-c.readers
+			if !ok {
 				return true, nil
 			}
-			return false, c.publish(r)
+			return false, c.publishPublication(p)
 		})
 	}()
 }
 
 // Publish implements Publisher
 func (c *appClient) Publish(r io.Reader) error {
+	return c.PublishWithFallback(r, nil)
+}
+
+// PublishWithFallback implements FallbackPublisher. Reports are queued, as
+// each delta builds on the one before. Should the app fall too far behind, we
+// drop the report, and ask for a full one next, as the deltas which follow
+// the dropped one couldn't be applied.
+func (c *appClient) PublishWithFallback(delta io.Reader, full func() (io.Reader, error)) error {
 	// Lazily start the background publishing loop.
 	c.publishLoop.Do(c.startPublishing)
 	select {
-	case c.readers <- r:
+	case c.readers <- publication{delta: delta, full: full}:
 	default:
+		log.Printf("Too many reports waiting to be published to %s; dropping one, and sending a full report next", c.target)
+		c.mtx.Lock()
+		c.needsFullReport = true
+		c.mtx.Unlock()
 	}
 	return nil
 }
 
+// NeedsFullReport implements ResyncPublisher. It only returns true once for
+// each time the app asks for a full report.
+func (c *appClient) NeedsFullReport() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	needsFullReport := c.needsFullReport
+	c.needsFullReport = false
+	return needsFullReport
+}
+
 func (c *appClient) pipeConnection(id string, pipe Pipe) (bool, error) {
 	dialer := websocket.Dialer{}
 	headers := http.Header{}
@@ -281,6 +464,12 @@ func (c *appClient) pipeConnection(id string, pipe Pipe) (bool, error) {
 		pipe.Close()
 		return true, nil
 	}
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		// Nobody can be at the other end of the pipe by the time we'd retry
+		log.Printf("Closing pipe %s: %v", id, ErrUnauthorized)
+		pipe.Close()
+		return true, nil
+	}
 	if err != nil {
 		return false, err
 	}
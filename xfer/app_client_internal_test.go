@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -50,10 +52,24 @@ func dummyServer(t *testing.T, expectedToken, expectedID string, expectedReport
 			defer reader.Close()
 		}
 
-		if err := gob.NewDecoder(reader).Decode(&have); err != nil {
+		if have := r.Header.Get("Content-Type"); have != report.DeltaContentType {
+			t.Errorf("want %q, have %q", report.DeltaContentType, have)
+		}
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
 			t.Error(err)
 			return
 		}
+		var delta report.Delta
+		if err := delta.UnmarshalProto(buf); err != nil {
+			t.Error(err)
+			return
+		}
+		if !delta.Full {
+			t.Error("expected a full report")
+			return
+		}
+		have = delta.Report
 		if !reflect.DeepEqual(expectedReport, have) {
 			t.Error(test.Diff(expectedReport, have))
 			return
@@ -132,12 +148,10 @@ func TestAppClientDetails(t *testing.T) {
 	}
 	defer p.Stop()
 
-	// First few reports might be dropped as the client is spinning up.
+	// Reports aren't dropped while the client is spinning up.
 	rp := NewReportPublisher(p)
-	for i := 0; i < 3; i++ {
-		if err := rp.Publish(rpt); err != nil {
-			t.Error(err)
-		}
+	if err := rp.Publish(rpt); err != nil {
+		t.Error(err)
 	}
 
 	select {
@@ -188,3 +202,205 @@ func TestAppClientPublish(t *testing.T) {
 		return
 	}
 }
+
+func TestAppClientUnauthorized(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewAppClient(ProbeConfig{Token: "bad"}, u.Host, s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	if _, err := p.Details(); err != ErrUnauthorized {
+		t.Errorf("want %v, have %v", ErrUnauthorized, err)
+	}
+	if err := p.(*appClient).publish(strings.NewReader("")); err != ErrUnauthorized {
+		t.Errorf("want %v, have %v", ErrUnauthorized, err)
+	}
+
+	// The client gives up at once, rather than backing off.
+	calls := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.(*appClient).doWithBackoff("test", func() (bool, error) {
+			calls++
+			return false, ErrUnauthorized
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("client kept retrying")
+	}
+	if calls > 1 {
+		t.Errorf("want at most 1 attempt, have %d", calls)
+	}
+}
+
+func TestAppClientFallback(t *testing.T) {
+	var (
+		rpt       = report.MakeReport()
+		mtx       sync.Mutex
+		deltas    int
+		fullPosts int
+	)
+
+	// An app which predates deltas, and tries to decode everything as gob.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if r.URL.Path == "/api" {
+			json.NewEncoder(w).Encode(Details{ID: "old"})
+			return
+		}
+		if r.Header.Get("Content-Type") != "" {
+			deltas++
+			http.Error(w, "gob: bad data", http.StatusBadRequest)
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var have report.Report
+		if err := gob.NewDecoder(reader).Decode(&have); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !reflect.DeepEqual(rpt, have) {
+			t.Error(test.Diff(rpt, have))
+		}
+		fullPosts++
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewAppClient(ProbeConfig{}, u.Host, s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	for i := 0; i < 2; i++ {
+		if err := p.(*appClient).publishPublication(publication{
+			delta: strings.NewReader("delta"),
+			full:  legacyEncoding(rpt),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if deltas != 1 {
+		t.Errorf("want 1 delta posted, have %d", deltas)
+	}
+	if fullPosts != 2 {
+		t.Errorf("want 2 full reports posted, have %d", fullPosts)
+	}
+}
+
+func TestAppClientBadDelta(t *testing.T) {
+	var (
+		mtx       sync.Mutex
+		deltas    int
+		fullPosts int
+	)
+
+	// A current app, which rejects our (bad) deltas.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		switch {
+		case r.URL.Path == "/api":
+			json.NewEncoder(w).Encode(Details{
+				ID:                 "current",
+				ReportContentTypes: []string{report.DeltaContentType, "application/x-gob"},
+			})
+		case r.Header.Get("Content-Type") == report.DeltaContentType:
+			deltas++
+			http.Error(w, "bad delta", http.StatusBadRequest)
+		default:
+			fullPosts++
+		}
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewAppClient(ProbeConfig{}, u.Host, s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	for i := 0; i < 2; i++ {
+		if err := p.(*appClient).publishPublication(publication{
+			delta: strings.NewReader("delta"),
+			full:  legacyEncoding(report.MakeReport()),
+		}); err == nil {
+			t.Error("want an error for a bad delta")
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if deltas != 2 || fullPosts != 0 {
+		t.Errorf("want 2 deltas and no full reports posted, have %d and %d", deltas, fullPosts)
+	}
+}
+
+func TestAppClientPublishQueue(t *testing.T) {
+	// An app which is stuck on the first report.
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewAppClient(ProbeConfig{}, u.Host, s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	defer close(release)
+
+	// Reports queue up behind it, until there are too many, when we need to
+	// send a full report, as the delta we drop is lost.
+	for i := 0; i < publishQueueLen; i++ {
+		if err := p.Publish(strings.NewReader("delta")); err != nil {
+			t.Fatal(err)
+		}
+		if p.NeedsFullReport() {
+			t.Fatalf("want no full report after %d reports", i+1)
+		}
+	}
+	test.Poll(t, 100*time.Millisecond, true, func() interface{} {
+		if err := p.Publish(strings.NewReader("delta")); err != nil {
+			t.Fatal(err)
+		}
+		return p.NeedsFullReport()
+	})
+}
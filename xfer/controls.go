@@ -13,6 +13,7 @@ type Request struct {
 	AppID   string
 	NodeID  string
 	Control string
+	Args    map[string]string // as described by the Params of the report.Control
 }
 
 // Response is the Probe -> App -> UI message type for the control RPCs.
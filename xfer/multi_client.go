@@ -26,6 +26,9 @@ type multiClient struct {
 	clients map[string]AppClient     // holds map from app id -> client
 	ids     map[string]report.IDList // holds map from hostname -> app ids
 	quit    chan struct{}
+
+	// Endpoints which rejected our token. We don't talk to them again.
+	unauthorized map[string]struct{}
 }
 
 type clientTuple struct {
@@ -41,6 +44,8 @@ type MultiAppClient interface {
 	PipeClose(appID, pipeID string) error
 	Stop()
 	Publish(io.Reader) error
+	PublishWithFallback(io.Reader, func() (io.Reader, error)) error
+	NeedsFullReport() bool
 }
 
 // NewMultiAppClient creates a new MultiAppClient.
@@ -52,6 +57,8 @@ func NewMultiAppClient(clientFactory ClientFactory) MultiAppClient {
 		clients: map[string]AppClient{},
 		ids:     map[string]report.IDList{},
 		quit:    make(chan struct{}),
+
+		unauthorized: map[string]struct{}{},
 	}
 }
 
@@ -62,6 +69,11 @@ func (c *multiClient) Set(hostname string, endpoints []string) {
 	clients := make(chan clientTuple, len(endpoints))
 	for _, endpoint := range endpoints {
 		go func(endpoint string) {
+			// However we give up on the endpoint, Set mustn't wait for it.
+			defer wg.Done()
+			if c.isUnauthorized(endpoint) {
+				return
+			}
 			c.sema.acquire()
 			defer c.sema.release()
 
@@ -72,12 +84,18 @@ func (c *multiClient) Set(hostname string, endpoints []string) {
 			}
 
 			details, err := client.Details()
-			if err != nil {
+			if err == ErrUnauthorized {
+				// The client has logged it.
+				c.mtx.Lock()
+				c.unauthorized[endpoint] = struct{}{}
+				c.mtx.Unlock()
+				client.Stop()
+				return
+			} else if err != nil {
 				log.Printf("Error fetching app details: %v", err)
 			}
 
 			clients <- clientTuple{details, client}
-			wg.Done()
 		}(endpoint)
 	}
 
@@ -112,6 +130,13 @@ func (c *multiClient) Set(hostname string, endpoints []string) {
 	}
 }
 
+func (c *multiClient) isUnauthorized(endpoint string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	_, ok := c.unauthorized[endpoint]
+	return ok
+}
+
 func (c *multiClient) withClient(appID string, f func(AppClient) error) error {
 	c.mtx.Lock()
 	client, ok := c.clients[appID]
@@ -151,6 +176,11 @@ func (c *multiClient) Stop() {
 // reader, and recreate new readers for each publisher. Note that it will
 // publish to one endpoint for each unique ID. Failed publishes don't count.
 func (c *multiClient) Publish(r io.Reader) error {
+	return c.PublishWithFallback(r, nil)
+}
+
+// PublishWithFallback implements FallbackPublisher, like Publish.
+func (c *multiClient) PublishWithFallback(r io.Reader, full func() (io.Reader, error)) error {
 	buf, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
@@ -160,7 +190,7 @@ func (c *multiClient) Publish(r io.Reader) error {
 	defer c.mtx.Unlock()
 	errs := []string{}
 	for _, c := range c.clients {
-		if err := c.Publish(bytes.NewReader(buf)); err != nil {
+		if err := c.PublishWithFallback(bytes.NewReader(buf), full); err != nil {
 			errs = append(errs, err.Error())
 		}
 	}
@@ -170,6 +200,20 @@ func (c *multiClient) Publish(r io.Reader) error {
 	return nil
 }
 
+// NeedsFullReport implements ResyncPublisher, returning true if any of the
+// underlying apps need a full report.
+func (c *multiClient) NeedsFullReport() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	needsFullReport := false
+	for _, c := range c.clients {
+		if c.NeedsFullReport() {
+			needsFullReport = true
+		}
+	}
+	return needsFullReport
+}
+
 type semaphore chan struct{}
 
 func newSemaphore(n int) semaphore {
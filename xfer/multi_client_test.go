@@ -2,9 +2,11 @@ package xfer_test
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/xfer"
 )
@@ -14,10 +16,13 @@ type mockClient struct {
 	count   int
 	stopped int
 	publish int
+	details int
+	err     error
 }
 
 func (c *mockClient) Details() (xfer.Details, error) {
-	return xfer.Details{ID: c.id}, nil
+	c.details++
+	return xfer.Details{ID: c.id}, c.err
 }
 
 func (c *mockClient) ControlConnection() {
@@ -33,8 +38,13 @@ func (c *mockClient) Publish(io.Reader) error {
 	return nil
 }
 
+func (c *mockClient) PublishWithFallback(r io.Reader, _ func() (io.Reader, error)) error {
+	return c.Publish(r)
+}
+
 func (c *mockClient) PipeConnection(_ string, _ xfer.Pipe) {}
 func (c *mockClient) PipeClose(_ string) error             { return nil }
+func (c *mockClient) NeedsFullReport() bool                { return false }
 
 var (
 	a1      = &mockClient{id: "1"} // hostname a, app id 1
@@ -105,3 +115,42 @@ func TestMultiClientPublish(t *testing.T) {
 		}
 	}
 }
+
+func TestMultiClientUnauthorized(t *testing.T) {
+	rejected := &mockClient{id: "", err: xfer.ErrUnauthorized}
+	mp := xfer.NewMultiAppClient(func(hostname, target string) (xfer.AppClient, error) {
+		return rejected, nil
+	})
+	defer mp.Stop()
+
+	// We don't ask an app which rejected our token again.
+	mp.Set("a", []string{"a1"})
+	mp.Set("a", []string{"a1"})
+	if rejected.details != 1 || rejected.count != 0 || rejected.stopped != 1 {
+		t.Errorf("want 1 details, 0 connections and 1 stop, have %d, %d and %d", rejected.details, rejected.count, rejected.stopped)
+	}
+	if err := mp.Publish(&bytes.Buffer{}); err != nil {
+		t.Error(err)
+	}
+	if rejected.publish != 0 {
+		t.Errorf("want no publications, have %d", rejected.publish)
+	}
+}
+
+func TestMultiClientFactoryError(t *testing.T) {
+	mp := xfer.NewMultiAppClient(func(hostname, target string) (xfer.AppClient, error) {
+		return nil, fmt.Errorf("no client for %s", target)
+	})
+	defer mp.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		mp.Set("a", []string{"a1", "a2"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Set hung on endpoints it couldn't make clients for")
+	}
+}
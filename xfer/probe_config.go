@@ -3,10 +3,10 @@ package xfer
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 
 	"github.com/certifi/gocertifi"
 )
@@ -15,6 +15,10 @@ import (
 // ID is currently set to the a random string on probe startup.
 const ScopeProbeIDHeader = "X-Scope-Probe-ID"
 
+// authorizationPrefix is what precedes the probe's token in the
+// Authorization header.
+const authorizationPrefix = "Scope-Probe token="
+
 var certPool *x509.CertPool
 
 func init() {
@@ -33,10 +37,20 @@ type ProbeConfig struct {
 }
 
 func (pc ProbeConfig) authorizeHeaders(headers http.Header) {
-	headers.Set("Authorization", fmt.Sprintf("Scope-Probe token=%s", pc.Token))
+	headers.Set("Authorization", authorizationPrefix+pc.Token)
 	headers.Set(ScopeProbeIDHeader, pc.ProbeID)
 }
 
+// ProbeToken returns the token a probe put in the Authorization header of
+// the request, if any.
+func ProbeToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, authorizationPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(auth, authorizationPrefix), true
+}
+
 func (pc ProbeConfig) authorizedRequest(method string, urlStr string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, urlStr, body)
 	if err == nil {
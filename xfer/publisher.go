@@ -8,3 +8,18 @@ type Publisher interface {
 	Publish(io.Reader) error
 	Stop()
 }
+
+// A ResyncPublisher is a Publisher which can tell when its destination has
+// lost track of the deltas we publish, and needs a full report.
+type ResyncPublisher interface {
+	Publisher
+	NeedsFullReport() bool
+}
+
+// A FallbackPublisher is a Publisher to destinations which may not
+// understand the deltas we publish (e.g. older apps), to which it can
+// publish the whole report, in the legacy encoding, instead.
+type FallbackPublisher interface {
+	Publisher
+	PublishWithFallback(delta io.Reader, full func() (io.Reader, error)) error
+}
@@ -4,31 +4,120 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/gob"
+	"io"
+	"sync"
 
 	"github.com/weaveworks/scope/report"
 )
 
+// fullReportEvery is how often (in publishes) we send a full report, even
+// if the app hasn't asked for one.
+const fullReportEvery = 10
+
 // A ReportPublisher uses a buffer pool to serialise reports, which it
 // then passes to a publisher
 type ReportPublisher struct {
 	publisher Publisher
+
+	mtx       sync.Mutex
+	seq       uint64
+	last      report.Report
+	sinceFull int
 }
 
 // NewReportPublisher creates a new report publisher
 func NewReportPublisher(publisher Publisher) *ReportPublisher {
 	return &ReportPublisher{
 		publisher: publisher,
+		last:      report.MakeReport(),
 	}
 }
 
-// Publish serialises and compresses a report, then passes it to a publisher
+// Publish serialises and compresses a report, then passes it to a publisher.
+// Reports are sent as deltas against the previous report, with a full report
+// every so often, or when the publisher says its destination needs one.
+// Shortcut reports are assumed to be partial, and are added to the previous
+// report rather than replacing it.
 func (p *ReportPublisher) Publish(r report.Report) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	needsFull := p.seq == 0 || p.sinceFull >= fullReportEvery
+	if rp, ok := p.publisher.(ResyncPublisher); ok && rp.NeedsFullReport() {
+		needsFull = true
+	}
+
+	var (
+		seq   = p.seq + 1
+		delta report.Delta
+	)
+	switch {
+	case needsFull && r.Shortcut:
+		delta = report.MakeFullDelta(seq, p.last.Apply(report.Delta{Report: r}))
+	case needsFull:
+		delta = report.MakeFullDelta(seq, r)
+	case r.Shortcut:
+		delta = report.Delta{Report: r}
+	default:
+		delta = p.last.Diff(r)
+	}
+	if !delta.Full {
+		delta.Seq, delta.BaseSeq = seq, p.seq
+	}
+
+	b, err := delta.MarshalProto()
+	if err != nil {
+		return err
+	}
 	buf := &bytes.Buffer{}
 	gzwriter := gzip.NewWriter(buf)
-	if err := gob.NewEncoder(gzwriter).Encode(r); err != nil {
+	if _, err := gzwriter.Write(b); err != nil {
 		return err
 	}
 	gzwriter.Close() // otherwise the content won't get flushed to the output stream
 
-	return p.publisher.Publish(buf)
+	if fp, ok := p.publisher.(FallbackPublisher); ok {
+		err = fp.PublishWithFallback(buf, legacyEncoding(r))
+	} else {
+		err = p.publisher.Publish(buf)
+	}
+	if err != nil {
+		return err
+	}
+	p.seq = delta.Seq
+	p.last = p.last.Apply(delta)
+	if delta.Full {
+		p.sinceFull = 0
+	} else {
+		p.sinceFull++
+	}
+	return nil
+}
+
+// legacyEncoding returns a function which gob-encodes and compresses the
+// report, as apps which don't understand deltas expect. It only does so the
+// first time it is called, as it may be called for each of several apps.
+func legacyEncoding(r report.Report) func() (io.Reader, error) {
+	var (
+		once sync.Once
+		buf  []byte
+		err  error
+	)
+	return func() (io.Reader, error) {
+		once.Do(func() {
+			b := &bytes.Buffer{}
+			gzwriter := gzip.NewWriter(b)
+			if err = gob.NewEncoder(gzwriter).Encode(r); err != nil {
+				return
+			}
+			if err = gzwriter.Close(); err != nil {
+				return
+			}
+			buf = b.Bytes()
+		})
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(buf), nil
+	}
 }